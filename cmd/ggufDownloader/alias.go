@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// validAliasName reports whether name can serve as a file alias: it must not read as a
+// path or a model reference, so neither can be mistaken for it
+func validAliasName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\: @`) {
+		return fmt.Errorf("invalid alias %q (use letters, digits, dots, dashes and underscores)", name)
+	}
+	return nil
+}
+
+// resolveFile turns a file alias into the path it stands for. An existing file or
+// anything written as a path is taken as it is, so an alias never hides a real file.
+func resolveFile(arg string) string {
+	if _, err := os.Stat(arg); err == nil || validAliasName(arg) != nil {
+		return arg
+	}
+	entries, err := loadLedger()
+	if err != nil {
+		return arg
+	}
+	for _, e := range entries {
+		if e.Alias == arg {
+			return e.Path
+		}
+	}
+	return arg
+}
+
+var aliasCommand = &Command{
+	Name:        "alias",
+	Usage:       "[list] | set NAME FILE | rm NAME",
+	Summary:     "Give a downloaded file a short name that verify, check, inspect, meta and repair accept",
+	PassThrough: true,
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		return func(args []string) error {
+			if len(args) == 0 {
+				return listAliases()
+			}
+			switch args[0] {
+			case "list":
+				return listAliases()
+			case "set":
+				if len(args) != 3 {
+					return errors.New("usage: alias set NAME FILE")
+				}
+				return setAlias(args[1], args[2])
+			case "rm":
+				if len(args) != 2 {
+					return errors.New("usage: alias rm NAME")
+				}
+				return setAlias(args[1], "")
+			default:
+				return fmt.Errorf("unknown alias command %q (use list, set or rm)", args[0])
+			}
+		}
+	},
+}
+
+// setAlias points name at the ledger entry of file, taking it from any file that had it;
+// an empty file removes the alias
+func setAlias(name, file string) error {
+	if err := validAliasName(name); err != nil {
+		return err
+	}
+	var abs string
+	if file != "" {
+		var err error
+		if abs, err = filepath.Abs(file); err != nil {
+			return err
+		}
+	}
+
+	err := updateLedger(func(entries []LedgerEntry) ([]LedgerEntry, error) {
+		found, had := false, false
+		for i := range entries {
+			if entries[i].Alias == name {
+				entries[i].Alias, had = "", true
+			}
+			if abs != "" && entries[i].Path == abs {
+				entries[i].Alias, found = name, true
+			}
+		}
+		switch {
+		case abs != "" && !found:
+			return nil, fmt.Errorf("%s is not in the ledger; only downloaded files can have an alias", file)
+		case abs == "" && !had:
+			return nil, fmt.Errorf("no alias %s", name)
+		}
+		return entries, nil
+	})
+	if err != nil {
+		return err
+	}
+	if abs == "" {
+		fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] Removed alias %s", name))
+	} else {
+		fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] %s now stands for %s", name, abs))
+	}
+	return nil
+}
+
+// listAliases prints every alias with the file and model it stands for
+func listAliases() error {
+	entries, err := loadLedger()
+	if err != nil {
+		return err
+	}
+	var aliased []LedgerEntry
+	for _, e := range entries {
+		if e.Alias != "" {
+			aliased = append(aliased, e)
+		}
+	}
+	if len(aliased) == 0 {
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] No aliases; add one with alias set NAME FILE"))
+		return nil
+	}
+	sort.Slice(aliased, func(i, j int) bool { return aliased[i].Alias < aliased[j].Alias })
+	for _, e := range aliased {
+		fmt.Printf("%s %s (%s:%s)\n", color.GreenString("%-20s", e.Alias), e.Path, e.Model, e.Params)
+	}
+	return nil
+}
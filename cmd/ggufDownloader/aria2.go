@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/fatih/color"
+
+	"ggufDownloader/pkg/ollamareg"
+)
+
+// InputEntry is one download in an aria2-style input file
+type InputEntry struct {
+	// URIs are mirrors of the same file, tried in order
+	URIs     []string
+	Out      string
+	Dir      string
+	Checksum string
+	Continue bool
+	Line     int
+}
+
+// blobDigestPattern finds the digest in a registry blob URL
+var blobDigestPattern = regexp.MustCompile(`/blobs/([a-z0-9]+:[0-9a-f]+)$`)
+
+// aria2Checksums maps aria2's checksum types to digest algorithms
+var aria2Checksums = map[string]string{
+	"sha-256": "sha256",
+	"sha-384": "sha384",
+	"sha-512": "sha512",
+}
+
+// parseInputFile reads an aria2 input file: tab-separated mirror URIs on one line,
+// followed by indented option lines such as "  out=NAME" and "  checksum=sha-256=HEX"
+func parseInputFile(r io.Reader) ([]*InputEntry, error) {
+	var entries []*InputEntry
+	var current *InputEntry
+
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if raw[0] != ' ' && raw[0] != '\t' {
+			current = &InputEntry{URIs: strings.Split(line, "\t"), Line: lineNo}
+			entries = append(entries, current)
+			continue
+		}
+
+		if current == nil {
+			return nil, fmt.Errorf("line %d: option before any URI", lineNo)
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key=value", lineNo)
+		}
+		switch key {
+		case "out":
+			current.Out = value
+		case "dir":
+			current.Dir = value
+		case "checksum":
+			typ, hex, _ := strings.Cut(value, "=")
+			algo, ok := aria2Checksums[typ]
+			if !ok {
+				return nil, fmt.Errorf("line %d: unsupported checksum type %q (use sha-256, sha-384 or sha-512)", lineNo, typ)
+			}
+			current.Checksum = algo + ":" + strings.ToLower(hex)
+			if !isDigest(current.Checksum) {
+				return nil, fmt.Errorf("line %d: invalid %s checksum %q", lineNo, typ, hex)
+			}
+		case "continue":
+			current.Continue = value == "true"
+		default:
+			// Other aria2 options tune aria2 itself and have no equivalent here
+			fmt.Fprintln(os.Stderr, color.YellowString("[WARN] line %d: ignoring aria2 option %q", lineNo, key))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// outputFile returns where the entry is saved
+func (e *InputEntry) outputFile() string {
+	name := e.Out
+	if name == "" {
+		name = "download.gguf"
+		if u, err := url.Parse(e.URIs[0]); err == nil && path.Base(u.Path) != "/" && path.Base(u.Path) != "." {
+			name = path.Base(u.Path)
+		}
+	}
+	return filepath.Join(e.Dir, name)
+}
+
+// expectedDigest is the checksum option, or the digest named by a registry blob URL
+func (e *InputEntry) expectedDigest() string {
+	if e.Checksum != "" {
+		return e.Checksum
+	}
+	for _, uri := range e.URIs {
+		if m := blobDigestPattern.FindStringSubmatch(uri); m != nil && isDigest(m[1]) {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// download fetches the entry from the first mirror that works and verifies it
+func (e *InputEntry) download(ctx context.Context) (string, error) {
+	filename := e.outputFile()
+	if e.Dir != "" {
+		if err := os.MkdirAll(e.Dir, 0o755); err != nil {
+			return "", err
+		}
+	}
+
+	var lastErr error
+	for _, uri := range e.URIs {
+		var offset int64
+		if info, err := os.Stat(filename); err == nil && e.Continue {
+			offset = info.Size()
+		}
+		if lastErr = downloadFile(ctx, uri, filename, nil, offset); lastErr == nil {
+			break
+		}
+		var interference *ollamareg.InterferenceError
+		if errors.As(lastErr, &interference) {
+			// Every mirror is behind the same proxy, so trying the others will not help
+			break
+		}
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] %s: %s", uri, lastErr))
+	}
+	if lastErr != nil {
+		return "", lastErr
+	}
+
+	if want := e.expectedDigest(); want != "" {
+		if err := verifyFile(filename, want); err != nil {
+			return "", err
+		}
+	}
+	return filename, nil
+}
+
+// runInputFile downloads every entry of an aria2 input file
+func runInputFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	entries, err := parseInputFile(f)
+	if err != nil {
+		return fmt.Errorf("invalid input file %s: %w", path, err)
+	}
+
+	ctx := context.Background()
+	failed := 0
+	for _, e := range entries {
+		filename, err := e.download(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("[ERROR] line %d: %s", e.Line, err))
+			failed++
+			continue
+		}
+		fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] Download completed: %s", filename))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d download(s) failed", failed, len(entries))
+	}
+	return nil
+}
+
+var inputCommand = &Command{
+	Name:    "input",
+	Usage:   "FILE",
+	Summary: "Download the URLs listed in an aria2-style input file",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		return func(args []string) error {
+			if len(args) != 1 {
+				return errors.New("usage: input FILE")
+			}
+			return runInputFile(args[0])
+		}
+	},
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// defaultBatchGroup holds items listed before any group header
+const defaultBatchGroup = "default"
+
+// BatchItem is a single model reference in a batch file
+type BatchItem struct {
+	Model  string
+	Params string
+	Line   int
+}
+
+// BatchGroup is a named set of items that downloads after the groups it depends on
+type BatchGroup struct {
+	Name  string
+	After []string
+	Items []BatchItem
+}
+
+// parseBatch reads a batch file. Items are "model:tag" lines; "[name] after=a,b"
+// starts a group that only downloads once groups a and b have succeeded. A model
+// listed again is downloaded once, where it first appears, since two pulls of one tag
+// would write the same file.
+func parseBatch(r io.Reader) ([]*BatchGroup, error) {
+	var groups []*BatchGroup
+	byName := make(map[string]*BatchGroup)
+	listed := make(map[string]int)
+	current := &BatchGroup{Name: defaultBatchGroup}
+
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			end := strings.IndexByte(line, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated group header", lineNo)
+			}
+			name := strings.TrimSpace(line[1:end])
+			if name == "" {
+				return nil, fmt.Errorf("line %d: empty group name", lineNo)
+			}
+			if _, dup := byName[name]; dup {
+				return nil, fmt.Errorf("line %d: group %q defined twice", lineNo, name)
+			}
+			current = &BatchGroup{Name: name}
+			for _, opt := range strings.Fields(line[end+1:]) {
+				key, value, _ := strings.Cut(opt, "=")
+				if key != "after" {
+					return nil, fmt.Errorf("line %d: unknown group option %q", lineNo, key)
+				}
+				for _, dep := range strings.Split(value, ",") {
+					if dep = strings.TrimSpace(dep); dep != "" {
+						current.After = append(current.After, dep)
+					}
+				}
+			}
+			byName[name] = current
+			groups = append(groups, current)
+			continue
+		}
+
+		model, params, err := parseModelRef(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		if first, dup := listed[model+":"+params]; dup {
+			fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Line %d: %s:%s is already listed on line %d; downloading it once", lineNo, model, params, first))
+			continue
+		}
+		listed[model+":"+params] = lineNo
+		if current.Name == defaultBatchGroup && byName[defaultBatchGroup] == nil {
+			byName[defaultBatchGroup] = current
+			groups = append(groups, current)
+		}
+		current.Items = append(current.Items, BatchItem{Model: model, Params: params, Line: lineNo})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return orderBatchGroups(groups, byName)
+}
+
+// orderBatchGroups sorts groups so every group follows its dependencies, keeping file order otherwise
+func orderBatchGroups(groups []*BatchGroup, byName map[string]*BatchGroup) ([]*BatchGroup, error) {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int)
+	var ordered []*BatchGroup
+
+	var visit func(g *BatchGroup, path []string) error
+	visit = func(g *BatchGroup, path []string) error {
+		switch state[g.Name] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle: %s", strings.Join(append(path, g.Name), " -> "))
+		}
+		state[g.Name] = visiting
+		for _, dep := range g.After {
+			depGroup, ok := byName[dep]
+			if !ok {
+				return fmt.Errorf("group %q depends on unknown group %q", g.Name, dep)
+			}
+			if err := visit(depGroup, append(path, g.Name)); err != nil {
+				return err
+			}
+		}
+		state[g.Name] = done
+		ordered = append(ordered, g)
+		return nil
+	}
+
+	for _, g := range groups {
+		if err := visit(g, nil); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// runBatchGroups downloads every group in order, skipping groups whose dependencies
+// failed, and returns the outcome of every item. Up to jobs items of a group download
+// at once; a group still waits for the whole of the groups before it.
+func runBatchGroups(ctx context.Context, groups []*BatchGroup, opts PullOptions, jobs int) []batchResult {
+	groupOK := make(map[string]bool)
+	// Several items may fail at once
+	var repeated atomic.Bool
+	var results []batchResult
+
+	for _, g := range groups {
+		var blocked []string
+		for _, dep := range g.After {
+			if !groupOK[dep] {
+				blocked = append(blocked, dep)
+			}
+		}
+		if len(blocked) > 0 {
+			fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Skipping group %s: dependency %s did not complete", g.Name, strings.Join(blocked, ", ")))
+			for _, item := range g.Items {
+				results = append(results, newBatchResult(g, item, batchSkipped, "",
+					fmt.Errorf("dependency %s did not complete", strings.Join(blocked, ", "))))
+			}
+			continue
+		}
+
+		fmt.Fprintln(os.Stderr, color.CyanString("\n=== Group %s (%d items) ===", g.Name, len(g.Items)))
+		groupResults := make([]batchResult, len(g.Items))
+		items := make(chan int, len(g.Items))
+		for i := range g.Items {
+			items <- i
+		}
+		close(items)
+		var wg sync.WaitGroup
+		for w := 0; w < min(max(jobs, 1), len(g.Items)); w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range items {
+					item := g.Items[i]
+					start := time.Now()
+					output, err := pullModel(ctx, item.Model, item.Params, opts)
+					if recordPullOutcome(item.Model, item.Params, err) {
+						repeated.Store(true)
+					}
+					var res batchResult
+					if err != nil {
+						fmt.Fprintln(os.Stderr, color.RedString("[ERROR] %s:%s: %s", item.Model, item.Params, err))
+						res = newBatchResult(g, item, batchFailed, "", err)
+					} else {
+						reportPulled(output, opts)
+						res = newBatchResult(g, item, batchDownloaded, output, nil)
+					}
+					res.Seconds = time.Since(start).Seconds()
+					groupResults[i] = res
+				}
+			}()
+		}
+		wg.Wait()
+
+		ok := true
+		for _, res := range groupResults {
+			ok = ok && res.Result == batchDownloaded
+		}
+		results = append(results, groupResults...)
+		groupOK[g.Name] = ok
+	}
+	if repeated.Load() {
+		offerDiagnostics("A batch download")
+	}
+	return results
+}
+
+var batchCommand = &Command{
+	Name:    "batch",
+	Usage:   "FILE",
+	Summary: "Download every model listed in a batch file, honoring group dependencies",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		pull := addPullFlags(fs)
+		report := fs.String("report", "", "Write a summary of every item to PATH.json and PATH.txt")
+		jobs := fs.Int("jobs", 1, "Number of items of a group to download at once; bound their connections with -max-connections")
+		return func(args []string) error {
+			if len(args) != 1 {
+				return errors.New("usage: batch [-report PATH] [-jobs N] FILE")
+			}
+			return runBatch(args[0], pull.options(), *report, *jobs)
+		}
+	},
+}
+
+// runBatch downloads every model listed in a batch file, jobs at a time, writing a
+// summary to report.json and report.txt when report is set
+func runBatch(path string, opts PullOptions, report string, jobs int) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	groups, err := parseBatch(f)
+	if err != nil {
+		return fmt.Errorf("invalid batch file %s: %w", path, err)
+	}
+
+	if jobs > 1 && !plainOutput {
+		// Bars redrawn by several downloads at once would overwrite each other
+		plainOutput, rendererMode = true, "plain"
+	}
+	started := time.Now()
+	results := runBatchGroups(interruptContext(), groups, opts, jobs)
+	if report != "" {
+		r := newBatchReport(path, started, results)
+		if err := r.write(report); err != nil {
+			fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Could not write the batch report: %s", err))
+		} else {
+			fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Batch report written to %s.json and %s.txt", report, report))
+		}
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Result != batchDownloaded {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d batch item(s) failed or were skipped", failed)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Outcomes of a batch item
+const (
+	batchDownloaded = "downloaded"
+	batchFailed     = "failed"
+	batchSkipped    = "skipped"
+)
+
+// batchResult is the outcome of one batch item
+type batchResult struct {
+	Group   string  `json:"group"`
+	Line    int     `json:"line"`
+	Model   string  `json:"model"`
+	Tag     string  `json:"tag"`
+	Result  string  `json:"result"`
+	Path    string  `json:"path,omitempty"`
+	Size    int64   `json:"size,omitempty"`
+	Seconds float64 `json:"seconds"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// newBatchResult records an item's outcome, taking its size from the file on disk
+func newBatchResult(g *BatchGroup, item BatchItem, result, path string, err error) batchResult {
+	r := batchResult{Group: g.Name, Line: item.Line, Model: item.Model, Tag: item.Params, Result: result, Path: path}
+	if path != "" {
+		if abs, err := filepath.Abs(path); err == nil {
+			r.Path = abs
+		}
+		if info, err := os.Stat(path); err == nil {
+			r.Size = info.Size()
+		}
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
+
+// batchReport summarizes a batch run for CI artifacts
+type batchReport struct {
+	File       string        `json:"file"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at"`
+	Downloaded int           `json:"downloaded"`
+	Failed     int           `json:"failed"`
+	Skipped    int           `json:"skipped"`
+	Bytes      int64         `json:"bytes"`
+	Items      []batchResult `json:"items"`
+}
+
+// newBatchReport totals the results of a run that started at started
+func newBatchReport(file string, started time.Time, results []batchResult) *batchReport {
+	r := &batchReport{File: file, StartedAt: started, FinishedAt: time.Now(), Items: results}
+	if r.Items == nil {
+		r.Items = []batchResult{}
+	}
+	for _, res := range results {
+		switch res.Result {
+		case batchDownloaded:
+			r.Downloaded++
+			r.Bytes += res.Size
+		case batchFailed:
+			r.Failed++
+		case batchSkipped:
+			r.Skipped++
+		}
+	}
+	return r
+}
+
+// write saves the report as path.json and path.txt; a .json or .txt extension on path is ignored
+func (r *batchReport) write(path string) error {
+	path = strings.TrimSuffix(strings.TrimSuffix(path, ".json"), ".txt")
+	if err := writeJSONFile(path+".json", r); err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Batch %s\n", r.File)
+	fmt.Fprintf(&b, "Started %s, took %s\n", r.StartedAt.Format(time.RFC3339), r.FinishedAt.Sub(r.StartedAt).Round(time.Second))
+	fmt.Fprintf(&b, "%d downloaded (%s), %d failed, %d skipped\n\n", r.Downloaded, formatBytes(r.Bytes), r.Failed, r.Skipped)
+	fmt.Fprintf(&b, "%-10s %-12s %-40s %10s %8s  %s\n", "RESULT", "GROUP", "MODEL", "SIZE", "TIME", "DETAILS")
+	for _, res := range r.Items {
+		size, details := "-", res.Error
+		if res.Result == batchDownloaded {
+			size, details = formatBytes(res.Size), res.Path
+		}
+		fmt.Fprintf(&b, "%-10s %-12s %-40s %10s %7.1fs  %s\n", res.Result, res.Group, res.Model+":"+res.Tag, size, res.Seconds, details)
+	}
+	return os.WriteFile(path+".txt", []byte(b.String()), 0o644)
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// blobCacheDir returns the content-addressed blob store shared by every project
+func blobCacheDir() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "blobs"), nil
+}
+
+// blobCachePath returns where a blob with the given "algo:hex" digest is stored
+func blobCachePath(digest string) (string, error) {
+	dir, err := blobCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, strings.Replace(digest, ":", "-", 1)), nil
+}
+
+// cacheBlob adds a downloaded file to the blob store by hard-linking or cloning it, so
+// the cache costs no extra space; files on another filesystem are simply not cached
+func cacheBlob(path, digest string) error {
+	cachePath, err := blobCachePath(digest)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(cachePath); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0o755); err != nil {
+		return err
+	}
+	if err := reflinkFile(path, cachePath); err == nil {
+		return nil
+	}
+	return os.Link(path, cachePath)
+}
+
+// Ways to place a cached blob on another filesystem, where it cannot be hard-linked
+var crossDevicePolicies = []string{"ask", "copy", "symlink"}
+
+// materializeBlob places a cached blob at dst, reporting false if the blob is not cached.
+// A reflink or a hard link costs no space; when neither is possible the blob would be
+// copied, which crossDevice ("ask", "copy" or "symlink") decides about.
+func materializeBlob(digest, dst, crossDevice string) (bool, error) {
+	cachePath, err := blobCachePath(digest)
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Stat(cachePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	os.Remove(dst)
+	// A clone is preferred: it is as instant and free as a link, but editing the output
+	// in place cannot corrupt the cache or the other projects' copies
+	if err := reflinkFile(cachePath, dst); err == nil {
+		fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Cloned the cached blob (copy-on-write, no extra space)"))
+		return true, nil
+	}
+	if err := os.Link(cachePath, dst); err == nil {
+		return true, nil
+	}
+
+	switch chooseCrossDevice(cachePath, dst, info.Size(), crossDevice) {
+	case "symlink":
+		fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Linking %s to the blob cache", dst))
+		return true, os.Symlink(cachePath, dst)
+	case "copy":
+		return true, copyFile(cachePath, dst)
+	default:
+		return true, errors.New("cancelled; the blob cache and the output are on different filesystems")
+	}
+}
+
+// chooseCrossDevice decides how to place a blob the cache cannot link: the policy given,
+// or on a terminal the user's answer after seeing how much space a copy would take
+func chooseCrossDevice(cachePath, dst string, size int64, policy string) string {
+	if policy != "ask" && policy != "" {
+		return policy
+	}
+	if !interactive() {
+		return "copy"
+	}
+	free, freeErr := diskFree(filepath.Dir(dst))
+
+	fmt.Fprintln(os.Stderr, color.YellowString("[WARN] The blob cache (%s) and %s are on different filesystems, so the cached blob cannot be linked.", filepath.Dir(cachePath), dst))
+	space := fmt.Sprintf("Copying it takes another %s", formatBytes(size))
+	if freeErr == nil {
+		space += fmt.Sprintf(" on the output disk (%s free)", formatBytes(free))
+		if free < size {
+			space += color.RedString("; that is not enough")
+		}
+	}
+	fmt.Fprintln(os.Stderr, color.YellowString("%s. A symlink takes no space but breaks if the cache is cleared.", space))
+	fmt.Fprint(os.Stderr, color.YellowString("[c]opy, [s]ymlink to the cache, or [a]bort? [c/s/A] "))
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "c", "copy":
+		return "copy"
+	case "s", "symlink":
+		return "symlink"
+	}
+	return "abort"
+}
+
+// copyFile copies src to dst, replacing dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
+
+// cachedBlobs lists the blobs in the store
+func cachedBlobs() ([]os.FileInfo, string, error) {
+	dir, err := blobCacheDir()
+	if err != nil {
+		return nil, "", err
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, dir, nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	var blobs []os.FileInfo
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil && info.Mode().IsRegular() {
+			blobs = append(blobs, info)
+		}
+	}
+	return blobs, dir, nil
+}
+
+// blobDigest converts a blob store filename back into its "algo:hex" digest
+func blobDigest(name string) string {
+	return strings.Replace(name, "-", ":", 1)
+}
+
+// pruneBlobCache removes blobs that no ledger in any project references
+func pruneBlobCache() error {
+	entries, err := allLedgerEntries()
+	if err != nil {
+		return err
+	}
+	referenced := make(map[string]bool)
+	for _, e := range entries {
+		if _, err := os.Stat(e.Path); err == nil {
+			referenced[e.Digest] = true
+		}
+	}
+
+	blobs, dir, err := cachedBlobs()
+	if err != nil {
+		return err
+	}
+	var removed int
+	var freed int64
+	for _, b := range blobs {
+		if referenced[blobDigest(b.Name())] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, b.Name())); err != nil {
+			return err
+		}
+		removed++
+		freed += b.Size()
+	}
+	fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] Removed %d unreferenced blob(s), %s", removed, formatBytes(freed)))
+	return nil
+}
+
+var cacheCommand = &Command{
+	Name:    "cache",
+	Usage:   "list | prune | dir | clear-metadata",
+	Summary: "Inspect or prune the shared blob cache and metadata store",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		return func(args []string) error {
+			if len(args) != 1 {
+				return errors.New("usage: cache list | cache prune | cache dir | cache clear-metadata")
+			}
+
+			switch args[0] {
+			case "dir":
+				dir, err := blobCacheDir()
+				if err != nil {
+					return err
+				}
+				fmt.Println(dir)
+				return nil
+			case "list":
+				blobs, _, err := cachedBlobs()
+				if err != nil {
+					return err
+				}
+				if len(blobs) == 0 {
+					fmt.Fprintln(os.Stderr, color.YellowString("[WARN] The blob cache is empty."))
+					return nil
+				}
+				var total int64
+				for _, b := range blobs {
+					total += b.Size()
+					fmt.Printf("%s  %s\n", color.YellowString("%10s", formatBytes(b.Size())), blobDigest(b.Name()))
+				}
+				fmt.Fprintln(os.Stderr, color.CyanString("[INFO] %d blob(s), %s", len(blobs), formatBytes(total)))
+				return nil
+			case "prune":
+				return pruneBlobCache()
+			case "clear-metadata":
+				if err := clearMetadata(); err != nil {
+					return err
+				}
+				fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] Cleared stored manifests, tags, configs and GGUF headers"))
+				return nil
+			default:
+				return fmt.Errorf("unknown cache command %q (use list, prune, dir or clear-metadata)", args[0])
+			}
+		}
+	},
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+	"golang.org/x/term"
+)
+
+// errBrowseCanceled reports that the user left the search without choosing a model
+var errBrowseCanceled = errors.New("search canceled")
+
+// browser is the state of an interactive catalog search
+type browser struct {
+	models   []ModelInfo
+	query    []rune
+	results  []scoredModel
+	selected int
+	offset   int
+}
+
+// filter ranks the catalog against the query; an empty query lists it in catalog order
+func (b *browser) filter() {
+	terms := strings.Fields(strings.ToLower(string(b.query)))
+	if len(terms) == 0 {
+		b.results = b.results[:0]
+		for _, m := range b.models {
+			b.results = append(b.results, scoredModel{m, 0})
+		}
+	} else {
+		b.results = rankModels(b.models, terms)
+	}
+	b.selected, b.offset = 0, 0
+}
+
+// move shifts the selection by delta, keeping it within the results
+func (b *browser) move(delta int) {
+	b.selected = max(0, min(b.selected+delta, len(b.results)-1))
+}
+
+// render draws the search box and as many results as fit in width × height
+func (b *browser) render(w io.Writer, width, height int) {
+	rows := max(height-3, 1)
+	if b.selected < b.offset {
+		b.offset = b.selected
+	} else if b.selected >= b.offset+rows {
+		b.offset = b.selected - rows + 1
+	}
+
+	var out strings.Builder
+	out.WriteString("\x1b[H\x1b[2J")
+	fmt.Fprintf(&out, "%s %s\r\n", color.CyanString("Search:"), string(b.query))
+	fmt.Fprintf(&out, "%s\r\n", color.CyanString("%d of %d models  ↑/↓ select  Enter choose  Esc quit", len(b.results), len(b.models)))
+	for i := b.offset; i < min(b.offset+rows, len(b.results)); i++ {
+		m := b.results[i].model
+		line := fmt.Sprintf("%-28s %-28s %s", m.Name, strings.Join(m.Capabilities, ", "), m.Description)
+		line = truncateRunes(line, width-1)
+		if i == b.selected {
+			fmt.Fprintf(&out, "\x1b[7m%s\x1b[0m\r\n", line)
+		} else {
+			fmt.Fprintf(&out, "%s\r\n", line)
+		}
+	}
+	// Leave the cursor in the search box
+	fmt.Fprintf(&out, "\x1b[1;%dH", utf8.RuneCountInString("Search: ")+len(b.query)+1)
+	io.WriteString(w, out.String())
+}
+
+// truncateRunes cuts s to at most n characters
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:max(n, 0)])
+}
+
+// browseCatalog searches the catalog as the user types and prints the chosen model's
+// name on stdout, so it can feed a pull. The screen is drawn on stderr.
+func browseCatalog(query string, refresh bool) error {
+	in, out := int(os.Stdin.Fd()), int(os.Stderr.Fd())
+	if !interactive() || !term.IsTerminal(out) {
+		return errors.New("find -i needs a terminal; use find QUERY in scripts")
+	}
+	if rendererMode != "tui" {
+		return fmt.Errorf("find -i draws a full-screen view, which -renderer %s rules out; use find QUERY", rendererMode)
+	}
+	cache, err := searchableCatalog(refresh)
+	if err != nil {
+		return err
+	}
+
+	b := &browser{models: cache.Models, query: []rune(query)}
+	b.filter()
+	name, err := b.choose(in, out)
+	if err != nil {
+		return err
+	}
+	fmt.Println(name)
+	return nil
+}
+
+// choose runs the search on the terminal until a model is chosen
+func (b *browser) choose(in, out int) (string, error) {
+	state, err := term.MakeRaw(in)
+	if err != nil {
+		return "", err
+	}
+	// The alternate screen keeps the user's scrollback intact
+	fmt.Fprint(os.Stderr, "\x1b[?1049h")
+	defer func() {
+		fmt.Fprint(os.Stderr, "\x1b[?1049l")
+		term.Restore(in, state)
+	}()
+
+	buf := make([]byte, 64)
+	for {
+		width, height, err := term.GetSize(out)
+		if err != nil || width <= 0 || height <= 0 {
+			width, height = 80, 24
+		}
+		b.render(os.Stderr, width, height)
+
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return "", err
+		}
+		key := buf[:n]
+		switch {
+		case string(key) == "\x1b[A", string(key) == "\x1bOA", n == 1 && key[0] == 0x10: // Up, Ctrl-P
+			b.move(-1)
+		case string(key) == "\x1b[B", string(key) == "\x1bOB", n == 1 && key[0] == 0x0e: // Down, Ctrl-N
+			b.move(1)
+		case string(key) == "\x1b[5~": // Page Up
+			b.move(-(height - 3))
+		case string(key) == "\x1b[6~": // Page Down
+			b.move(height - 3)
+		case n == 1 && (key[0] == 0x1b || key[0] == 0x03 || key[0] == 0x04): // Esc, Ctrl-C, Ctrl-D
+			return "", errBrowseCanceled
+		case n == 1 && (key[0] == '\r' || key[0] == '\n'):
+			if len(b.results) > 0 {
+				return b.results[b.selected].model.Name, nil
+			}
+		case n == 1 && (key[0] == 0x7f || key[0] == 0x08): // Backspace
+			if len(b.query) > 0 {
+				b.query = b.query[:len(b.query)-1]
+				b.filter()
+			}
+		case n == 1 && key[0] == 0x15: // Ctrl-U
+			b.query = b.query[:0]
+			b.filter()
+		case key[0] >= 0x20 && key[0] != 0x7f:
+			// Typed or pasted text; other escape sequences are ignored
+			b.query = append(b.query, []rune(string(key))...)
+			b.filter()
+		}
+	}
+}
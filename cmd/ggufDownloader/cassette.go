@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxCassetteBody bounds the part of a response body a cassette keeps; manifests, pages
+// and GGUF headers fit, while a blob is cut off and replays as a download that fails
+const maxCassetteBody = 16 << 20
+
+// cassetteInteraction is one HTTP exchange as a cassette stores it, one per line
+type cassetteInteraction struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Range  string      `json:"range,omitempty"`
+	Status int         `json:"status,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+	// Truncated marks a body that was longer than maxCassetteBody or not read to the end
+	Truncated bool   `json:"truncated,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// cassetteKey identifies the request an interaction answers
+func cassetteKey(method, url, rng string) string {
+	return method + " " + url + " " + rng
+}
+
+// signatureParams are the query parameters of signed URLs that grant access, compared
+// in lower case; a cassette keeps their names but not their values
+var signatureParams = []string{
+	"x-amz-signature", "x-amz-credential", "x-amz-security-token",
+	"x-goog-signature", "x-goog-credential",
+	"signature", "sig", "policy", "key-pair-id", "token", "access_token",
+}
+
+// cassetteURL writes u for a cassette: without credentials and with the values of
+// signature parameters replaced, but otherwise with its query, so requests that differ
+// only in their query still get their own answers. Replayed redirects lead to URLs
+// already written this way, which map to themselves.
+func cassetteURL(u *url.URL) string {
+	c := *u
+	c.User = nil
+	if c.RawQuery == "" {
+		return c.String()
+	}
+	query := c.Query()
+	for name := range query {
+		if slices.Contains(signatureParams, strings.ToLower(name)) {
+			query[name] = []string{"REDACTED"}
+		}
+	}
+	c.RawQuery = query.Encode()
+	return c.String()
+}
+
+// cassetteHeader copies a response header for a cassette, without cookies and with
+// the signatures of redirect targets removed the same way request URLs are
+func cassetteHeader(h http.Header) http.Header {
+	out := h.Clone()
+	out.Del("Set-Cookie")
+	if loc, err := url.Parse(out.Get("Location")); err == nil && loc.RawQuery != "" {
+		out.Set("Location", cassetteURL(loc))
+	}
+	return out
+}
+
+// recordingTransport writes every exchange to a cassette file as its body is closed
+type recordingTransport struct {
+	next http.RoundTripper
+	mu   sync.Mutex
+	file *os.File
+}
+
+func newRecordingTransport(next http.RoundTripper, path string) (*recordingTransport, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot record to %s: %w", path, err)
+	}
+	return &recordingTransport{next: next, file: file}, nil
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := cassetteInteraction{Method: req.Method, URL: cassetteURL(req.URL), Range: req.Header.Get("Range")}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		rec.Error = redact(err.Error())
+		t.write(rec)
+		return nil, err
+	}
+	rec.Status, rec.Header = resp.StatusCode, cassetteHeader(resp.Header)
+	resp.Body = &recordingBody{ReadCloser: resp.Body, t: t, rec: rec}
+	return resp, nil
+}
+
+// write appends an interaction; a line that cannot be written is dropped, since the
+// cassette must not be the reason a download fails
+func (t *recordingTransport) write(rec cassetteInteraction) {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.file.Write(append(line, '\n'))
+}
+
+// recordingBody keeps what is read of a response body and records the exchange on close
+type recordingBody struct {
+	io.ReadCloser
+	t    *recordingTransport
+	rec  cassetteInteraction
+	eof  bool
+	once sync.Once
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if room := maxCassetteBody - len(b.rec.Body); n > room {
+		b.rec.Body = append(b.rec.Body, p[:room]...)
+		b.rec.Truncated = true
+	} else {
+		b.rec.Body = append(b.rec.Body, p[:n]...)
+	}
+	if err == io.EOF {
+		b.eof = true
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	b.once.Do(func() {
+		if !b.eof {
+			b.rec.Truncated = true
+		}
+		// The page of a redirect repeats its signed target and is never used
+		if b.rec.Header.Get("Location") != "" {
+			b.rec.Body, b.rec.Truncated = nil, false
+		}
+		b.t.write(b.rec)
+	})
+	return b.ReadCloser.Close()
+}
+
+// replayTransport answers requests from a cassette instead of the network. Requests
+// repeated in the recording get their answers in recorded order, and the last answer
+// again after that.
+type replayTransport struct {
+	path     string
+	mu       sync.Mutex
+	recorded map[string][]cassetteInteraction
+}
+
+func newReplayTransport(path string) (*replayTransport, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot replay %s: %w", path, err)
+	}
+	defer file.Close()
+	t := &replayTransport{path: path, recorded: make(map[string][]cassetteInteraction)}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 2*maxCassetteBody)
+	for line := 1; scanner.Scan(); line++ {
+		var rec cassetteInteraction
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("invalid cassette %s, line %d: %w", path, line, err)
+		}
+		key := cassetteKey(rec.Method, rec.URL, rec.Range)
+		t.recorded[key] = append(t.recorded[key], rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot replay %s: %w", path, err)
+	}
+	return t, nil
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	key := cassetteKey(req.Method, cassetteURL(req.URL), req.Header.Get("Range"))
+	t.mu.Lock()
+	queue := t.recorded[key]
+	if len(queue) > 1 {
+		t.recorded[key] = queue[1:]
+	}
+	t.mu.Unlock()
+	if len(queue) == 0 {
+		return nil, fmt.Errorf("%s has no recorded response to %s %s", t.path, req.Method, cassetteURL(req.URL))
+	}
+	rec := queue[0]
+	if rec.Error != "" {
+		return nil, errors.New(rec.Error)
+	}
+
+	var body io.Reader = bytes.NewReader(rec.Body)
+	if rec.Truncated {
+		body = io.MultiReader(body, errorReader{fmt.Errorf("%s holds only the first %s of this response", t.path, formatBytes(int64(len(rec.Body))))})
+	}
+	length := int64(-1)
+	if n, err := strconv.ParseInt(rec.Header.Get("Content-Length"), 10, 64); err == nil {
+		length = n
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rec.Status, http.StatusText(rec.Status)),
+		StatusCode:    rec.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rec.Header.Clone(),
+		Body:          io.NopCloser(body),
+		ContentLength: length,
+		Request:       req,
+	}, nil
+}
+
+// errorReader fails every read with err
+type errorReader struct {
+	err error
+}
+
+func (r errorReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
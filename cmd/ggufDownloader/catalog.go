@@ -0,0 +1,405 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// catalogCache is the on-disk snapshot of the last successful model listing
+type catalogCache struct {
+	FetchedAt time.Time   `json:"fetched_at"`
+	Models    []ModelInfo `json:"models"`
+}
+
+// catalogPath returns the location of the cached catalog
+func catalogPath() (string, error) {
+	dir, err := projectDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "catalog.json"), nil
+}
+
+// saveCatalog caches a freshly fetched model listing
+func saveCatalog(models []ModelInfo) error {
+	path, err := catalogPath()
+	if err != nil {
+		return err
+	}
+	return writeJSONFile(path, catalogCache{FetchedAt: time.Now(), Models: models})
+}
+
+// catalogWriter streams models into the catalog cache without holding the listing in memory
+type catalogWriter struct {
+	f    *os.File
+	path string
+	n    int
+}
+
+// newCatalogWriter starts writing a fresh catalog cache
+func newCatalogWriter() (*catalogWriter, error) {
+	path, err := catalogPath()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return nil, err
+	}
+	fetchedAt, _ := json.Marshal(time.Now())
+	if _, err := fmt.Fprintf(f, "{\"fetched_at\": %s, \"models\": [", fetchedAt); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &catalogWriter{f: f, path: path}, nil
+}
+
+// add appends a model to the catalog
+func (w *catalogWriter) add(m ModelInfo) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if w.n > 0 {
+		data = append([]byte(",\n"), data...)
+	} else {
+		data = append([]byte("\n"), data...)
+	}
+	w.n++
+	_, err = w.f.Write(data)
+	return err
+}
+
+// close finishes the catalog and replaces the previous cache with it
+func (w *catalogWriter) close() error {
+	if _, err := w.f.WriteString("\n]}\n"); err != nil {
+		w.abort()
+		return err
+	}
+	if err := w.f.Close(); err != nil {
+		os.Remove(w.f.Name())
+		return err
+	}
+	return os.Rename(w.f.Name(), w.path)
+}
+
+// abort discards a partially written catalog, keeping the previous cache
+func (w *catalogWriter) abort() {
+	w.f.Close()
+	os.Remove(w.f.Name())
+}
+
+// scanCatalog streams the cached catalog to fn, reporting when it was fetched and
+// whether a cache exists at all
+func scanCatalog(fn func(ModelInfo)) (time.Time, bool, error) {
+	var fetchedAt time.Time
+	path, err := catalogPath()
+	if err != nil {
+		return fetchedAt, false, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fetchedAt, false, nil
+	}
+	if err != nil {
+		return fetchedAt, false, err
+	}
+	defer f.Close()
+
+	invalid := func(err error) (time.Time, bool, error) {
+		return fetchedAt, true, fmt.Errorf("invalid catalog cache %s: %w", path, err)
+	}
+	dec := json.NewDecoder(f)
+	if _, err := dec.Token(); err != nil {
+		return invalid(err)
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return invalid(err)
+		}
+		switch key {
+		case "fetched_at":
+			err = dec.Decode(&fetchedAt)
+		case "models":
+			if _, err = dec.Token(); err != nil {
+				return invalid(err)
+			}
+			for dec.More() {
+				var m ModelInfo
+				if err := dec.Decode(&m); err != nil {
+					return invalid(err)
+				}
+				fn(m)
+			}
+			_, err = dec.Token()
+		default:
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return invalid(err)
+		}
+	}
+	return fetchedAt, true, nil
+}
+
+// loadCatalog reads the cached catalog, returning nil if nothing has been cached yet
+func loadCatalog() (*catalogCache, error) {
+	path, err := catalogPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cache catalogCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, fmt.Errorf("invalid catalog cache %s: %w", path, err)
+	}
+	return &cache, nil
+}
+
+// fuzzyScore rates how well text matches a single lowercase query term, 0 meaning no match
+func fuzzyScore(term, text string) int {
+	text = strings.ToLower(text)
+	switch {
+	case text == "":
+		return 0
+	case text == term:
+		return 100
+	case strings.HasPrefix(text, term):
+		return 80
+	case strings.Contains(text, term):
+		return 60
+	}
+
+	// Subsequence match: every character of term appears in order, penalized by gaps
+	gaps, pos := 0, 0
+	for _, r := range term {
+		i := strings.IndexRune(text[pos:], r)
+		if i < 0 {
+			return 0
+		}
+		gaps += i
+		pos += i + len(string(r))
+	}
+	if score := 40 - gaps*2; score > 10 {
+		return score
+	}
+	return 10
+}
+
+// scoreModel rates a model against every query term; all terms must match somewhere
+func scoreModel(model ModelInfo, terms []string) int {
+	total := 0
+	for _, term := range terms {
+		best := fuzzyScore(term, model.Name) * 3
+		for _, c := range model.Capabilities {
+			if s := fuzzyScore(term, c) * 2; s > best {
+				best = s
+			}
+		}
+		// Descriptions are long prose, so only whole-substring matches count
+		if strings.Contains(strings.ToLower(model.Description), term) && best < 60 {
+			best = 60
+		}
+		if best == 0 {
+			return 0
+		}
+		total += best
+	}
+	return total
+}
+
+var findCommand = &Command{
+	Name:    "find",
+	Usage:   "QUERY",
+	Summary: "Fuzzy-search the cached catalog offline",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		limit := fs.Int("limit", 20, "Maximum number of results to show")
+		refresh := fs.Bool("refresh", false, "Refresh the cached catalog from ollama.com before searching")
+		browse := fs.Bool("i", false, "Search interactively, filtering as you type; prints the chosen model on stdout")
+		return func(args []string) error {
+			if *browse {
+				return browseCatalog(strings.Join(args, " "), *refresh)
+			}
+			return findModels(args, *limit, *refresh)
+		}
+	},
+}
+
+// findModels searches the cached catalog locally, fetching it only if nothing is cached
+func findModels(query []string, limit int, refresh bool) error {
+	if len(query) == 0 {
+		return errors.New("usage: find [-limit N] [-refresh] QUERY")
+	}
+
+	if limit < 1 {
+		return errors.New("-limit must be at least 1")
+	}
+
+	terms := strings.Fields(strings.ToLower(strings.Join(query, " ")))
+	if lowMemory {
+		return findModelsStreaming(query, terms, limit, refresh)
+	}
+
+	cache, err := searchableCatalog(refresh)
+	if err != nil {
+		return err
+	}
+	results := rankModels(cache.Models, terms)
+
+	fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Searching catalog cached %s (%d models)", formatAge(cache.FetchedAt), len(cache.Models)))
+	if len(results) == 0 {
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] No models match %q", strings.Join(query, " ")))
+		return nil
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	models := make([]ModelInfo, len(results))
+	for i, r := range results {
+		models[i] = r.model
+	}
+	printModelsTable(models, true)
+	return nil
+}
+
+// searchableCatalog returns the cached catalog, fetching it when nothing is cached or
+// refresh is set, and falling back to the built-in snapshot when that fails
+func searchableCatalog(refresh bool) (*catalogCache, error) {
+	cache, err := loadCatalog()
+	if err != nil {
+		return nil, err
+	}
+	if cache != nil && !refresh {
+		return cache, nil
+	}
+	models, err := fetchAvailableModels("")
+	switch {
+	case err == nil:
+		if err := saveCatalog(models); err != nil {
+			fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Could not cache catalog: %s", err))
+		}
+		return &catalogCache{FetchedAt: time.Now(), Models: models}, nil
+	case cache == nil:
+		snapshot, snapErr := embeddedCatalog()
+		if snapErr != nil {
+			return nil, fmt.Errorf("failed to fetch the catalog: %w; %w", err, snapErr)
+		}
+		cache = snapshot
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Could not fetch the catalog (%s); searching the built-in snapshot of %s", err, cache.FetchedAt.Format("2006-01-02")))
+		return cache, nil
+	}
+	return nil, fmt.Errorf("failed to refresh the catalog: %w", err)
+}
+
+// rankModels returns the models matching every term, best first
+func rankModels(models []ModelInfo, terms []string) []scoredModel {
+	var results []scoredModel
+	for _, m := range models {
+		if score := scoreModel(m, terms); score > 0 {
+			results = append(results, scoredModel{m, score})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].score > results[j].score
+	})
+	return results
+}
+
+// scoredModel is a catalog entry with its match score
+type scoredModel struct {
+	model ModelInfo
+	score int
+}
+
+// findModelsStreaming searches the catalog without loading it, keeping only the best limit matches
+func findModelsStreaming(query, terms []string, limit int, refresh bool) error {
+	var best []scoredModel
+	total := 0
+	consider := func(m ModelInfo) {
+		total++
+		score := scoreModel(m, terms)
+		if score == 0 || (len(best) == limit && score <= best[len(best)-1].score) {
+			return
+		}
+		i := sort.Search(len(best), func(i int) bool { return best[i].score < score })
+		if len(best) < limit {
+			best = append(best, scoredModel{})
+		}
+		copy(best[i+1:], best[i:])
+		best[i] = scoredModel{m, score}
+	}
+
+	fetchedAt, found, err := scanCatalog(consider)
+	if err != nil {
+		return err
+	}
+	if !found || refresh {
+		best, total = best[:0], 0
+		w, err := newCatalogWriter()
+		if err != nil {
+			return err
+		}
+		err = scanAvailableModels("", func(m ModelInfo) error {
+			consider(m)
+			return w.add(m)
+		})
+		switch {
+		case err == nil:
+			if err := w.close(); err != nil {
+				fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Could not cache catalog: %s", err))
+			}
+			fetchedAt = time.Now()
+		case found:
+			w.abort()
+			return fmt.Errorf("failed to refresh the catalog: %w", err)
+		default:
+			w.abort()
+			best, total = best[:0], 0
+			snapshot, snapErr := embeddedCatalog()
+			if snapErr != nil {
+				return fmt.Errorf("failed to fetch the catalog: %w; %w", err, snapErr)
+			}
+			fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Could not fetch the catalog (%s); searching the built-in snapshot of %s", err, snapshot.FetchedAt.Format("2006-01-02")))
+			for _, m := range snapshot.Models {
+				consider(m)
+			}
+			fetchedAt = snapshot.FetchedAt
+		}
+	}
+
+	fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Searching catalog cached %s (%d models)", formatAge(fetchedAt), total))
+	if len(best) == 0 {
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] No models match %q", strings.Join(query, " ")))
+		return nil
+	}
+	models := make([]ModelInfo, len(best))
+	for i, r := range best {
+		models[i] = r.model
+	}
+	printModelsTable(models, true)
+	return nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// localCopyTime returns when a local model was downloaded, from its sidecar or its modification time
+func localCopyTime(path string, info os.FileInfo) time.Time {
+	var sidecar Sidecar
+	if data, err := os.ReadFile(sidecarPath(path)); err == nil && json.Unmarshal(data, &sidecar) == nil && !sidecar.DownloadedAt.IsZero() {
+		return sidecar.DownloadedAt
+	}
+	return info.ModTime()
+}
+
+// printComparison prints one row of the local/registry comparison, highlighting differences
+func printComparison(label, local, remote string) {
+	mark := color.GreenString("=")
+	if local != remote {
+		mark = color.YellowString("≠")
+	}
+	fmt.Printf("  %-14s%s %-40s %s\n", label, mark, local, remote)
+}
+
+// checkModel compares a local GGUF file with what the registry currently serves for
+// model:tag, or with what Hugging Face publishes when the ledger says the file came from there
+func checkModel(ref, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if e, err := ledgerEntry(path); err == nil && e != nil && e.Source == sourceHF && sameLedgerRef(ref, e) {
+		return checkHFModel(ctx, e.URL, path, info)
+	}
+
+	modelName, tag, err := parseModelRef(ref)
+	if err != nil {
+		return err
+	}
+	manifest, err := fetchManifest(ctx, modelName, tag)
+	if isNotFound(err) {
+		fmt.Fprintln(os.Stderr, color.RedString("[WARN] %s:%s no longer exists upstream; it was removed or renamed, so keep %s", modelName, tag, path))
+	}
+	if err != nil {
+		return err
+	}
+	layer := manifest.ModelLayer()
+	if layer == nil {
+		return errors.New("model digest not found in manifest")
+	}
+
+	fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Hashing %s...", path))
+	digest, err := fileDigestAs(path, digestAlgorithm(layer.Digest))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Println(color.CyanString("  %-14s  %-40s %s", "", "LOCAL", "REGISTRY "+modelName+":"+tag))
+	printComparison("Digest", shortDigest(digest), shortDigest(layer.Digest))
+	printComparison("Size", formatBytes(info.Size()), formatBytes(layer.Size))
+
+	if config, err := fetchModelConfig(ctx, modelName, manifest); err == nil {
+		if meta, err := openGGUFMetadata(path, func(key string) bool { return key == "general.architecture" }); err == nil {
+			printComparison("Architecture", meta.String("general.architecture"), config.Family())
+		}
+	}
+	age := localCopyTime(path, info)
+	fmt.Printf("  %-14s  %s\n", "Local copy", formatAge(age))
+
+	fmt.Println()
+	if digest == layer.Digest {
+		fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] %s is identical to %s:%s", path, modelName, tag))
+		return nil
+	}
+	fmt.Fprintln(os.Stderr, color.YellowString("[WARN] %s differs from %s:%s; the registry copy has changed or this is a different build", path, modelName, tag))
+	fmt.Fprintln(os.Stderr, color.WhiteString("  Update with: ggufDownloader pull %s:%s", modelName, tag))
+	return nil
+}
+
+// sameLedgerRef reports whether ref names the model:tag a ledger entry was pulled as
+func sameLedgerRef(ref string, e *LedgerEntry) bool {
+	if ref == e.Model+":"+e.Params {
+		return true
+	}
+	modelName, tag, err := parseModelRef(ref)
+	return err == nil && modelName == e.Model && tag == e.Params
+}
+
+// checkHFModel compares a local GGUF file with the Hugging Face file it was downloaded from
+func checkHFModel(ctx context.Context, url, path string, info os.FileInfo) error {
+	repo, name, ok := parseHFURL(url)
+	if !ok {
+		return fmt.Errorf("%q is not a Hugging Face file URL", url)
+	}
+	files, err := listHFGGUFs(ctx, repo)
+	if err != nil {
+		return err
+	}
+	var file *hfFile
+	for _, f := range files {
+		if f.Name == name {
+			file = f
+		}
+	}
+	if file == nil {
+		fmt.Fprintln(os.Stderr, color.RedString("[WARN] %s is no longer in %s; it was removed or renamed, so keep %s", name, repo, path))
+		return fmt.Errorf("%s has no GGUF file %q", repo, name)
+	}
+	if file.Digest() == "" {
+		return fmt.Errorf("Hugging Face publishes no digest for %s, so it cannot be compared", url)
+	}
+
+	fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Hashing %s...", path))
+	digest, err := fileDigestAs(path, digestAlgorithm(file.Digest()))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Println(color.CyanString("  %-14s  %-40s %s", "", "LOCAL", "HUGGING FACE "+repo+"/"+name))
+	printComparison("Digest", shortDigest(digest), shortDigest(file.Digest()))
+	printComparison("Size", formatBytes(info.Size()), formatBytes(file.Size))
+	fmt.Printf("  %-14s  %s\n", "Local copy", formatAge(localCopyTime(path, info)))
+
+	fmt.Println()
+	if digest == file.Digest() {
+		fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] %s is identical to %s", path, url))
+		return nil
+	}
+	fmt.Fprintln(os.Stderr, color.YellowString("[WARN] %s differs from %s; the file was updated on Hugging Face", path, url))
+	fmt.Fprintln(os.Stderr, color.WhiteString("  Update with: ggufDownloader -source hf -model %s -file %s", repo, name))
+	return nil
+}
+
+// shortDigest abbreviates a digest for display
+func shortDigest(digest string) string {
+	if len(digest) > 19 {
+		return digest[:19] + "..."
+	}
+	return digest
+}
+
+var checkCommand = &Command{
+	Name:    "check",
+	Usage:   "MODEL:TAG FILE",
+	Summary: "Compare a local GGUF file with the registry's current version",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		return func(args []string) error {
+			if len(args) != 2 {
+				return errors.New("usage: check MODEL:TAG FILE")
+			}
+			return checkModel(args[0], resolveFile(args[1]))
+		}
+	},
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"ggufDownloader/pkg/ollamareg"
+)
+
+// checksumList holds the digests a directory is expected to contain
+type checksumList struct {
+	// Source is the file the list was read from
+	Source string
+	// Files maps slash-separated paths relative to the directory to their digests
+	Files map[string]string
+	// Digests must each be found in some file, whatever it is called; an Ollama
+	// manifest names its blobs only by digest
+	Digests []string
+}
+
+// bsdChecksumLine matches the BSD format, "SHA256 (file.gguf) = <hex>"
+var bsdChecksumLine = regexp.MustCompile(`^([A-Za-z0-9-]+) \((.+)\) = ([0-9a-fA-F]+)$`)
+
+// gnuChecksumLine matches the GNU coreutils format, "<hex>  file.gguf" or "<hex> *file.gguf"
+var gnuChecksumLine = regexp.MustCompile(`^([0-9a-fA-F]+) [ *](.+)$`)
+
+// hexAlgorithms names the algorithm of a GNU-format digest by its length
+var hexAlgorithms = map[int]string{64: "sha256", 96: "sha384", 128: "sha512"}
+
+// readChecksumList reads a SHA256SUMS-style file (GNU or BSD format; sha384 and sha512
+// too) or an Ollama manifest
+func readChecksumList(file string) (*checksumList, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	list := &checksumList{Source: file, Files: make(map[string]string)}
+
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var manifest Manifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, fmt.Errorf("%s: invalid manifest: %w", file, err)
+		}
+		layer := manifest.ModelLayer()
+		if layer == nil {
+			return nil, fmt.Errorf("%s: the manifest has no model layer", file)
+		}
+		list.Digests = append(list.Digests, layer.Digest)
+		return list, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var algo, name, encoded string
+		if m := bsdChecksumLine.FindStringSubmatch(line); m != nil {
+			algo, name, encoded = strings.ToLower(m[1]), m[2], m[3]
+		} else if m := gnuChecksumLine.FindStringSubmatch(line); m != nil {
+			name, encoded = m[2], m[1]
+			if algo = hexAlgorithms[len(encoded)]; algo == "" {
+				return nil, fmt.Errorf("%s:%d: cannot tell the algorithm of a %d-digit checksum", file, n, len(encoded))
+			}
+		} else {
+			return nil, fmt.Errorf("%s:%d: not a checksum line: %q", file, n, line)
+		}
+		digest := algo + ":" + strings.ToLower(encoded)
+		if _, _, err := ollamareg.ParseDigest(digest); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", file, n, err)
+		}
+		list.Files[path.Clean(filepath.ToSlash(name))] = digest
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(list.Files) == 0 {
+		return nil, fmt.Errorf("%s lists no files", file)
+	}
+	return list, nil
+}
+
+// verifyAgainstList checks every file the list names, and every .gguf file in dir, with
+// a pool of workers. Listed files that do not exist, and manifest digests no file
+// has, are reported missing; .gguf files the list does not cover have no digest.
+func verifyAgainstList(dir string, list *checksumList, workers int) ([]verifyResult, error) {
+	files, err := modelFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	for name := range list.Files {
+		if file := filepath.Join(dir, filepath.FromSlash(name)); !slices.Contains(files, file) {
+			files = append(files, file)
+		}
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	found := make(map[string]bool)
+	results := verifyFiles(files, workers, func(file string) verifyResult {
+		rel, _ := filepath.Rel(absDir, file)
+		want, listed := list.Files[filepath.ToSlash(rel)]
+		if listed {
+			if _, err := os.Stat(file); errors.Is(err, os.ErrNotExist) {
+				return verifyResult{file, "missing", "listed in " + list.Source + " but not found"}
+			}
+		}
+		got, err := fileDigestAs(file, digestAlgorithm(want))
+		if err != nil {
+			return verifyResult{file, "fail", err.Error()}
+		}
+		switch {
+		case listed && got != want:
+			return verifyResult{file, "fail", fmt.Sprintf("expected %s (%s), got %s", want, list.Source, got)}
+		case listed:
+			return verifyResult{file, "pass", got + " (" + list.Source + ")"}
+		case slices.Contains(list.Digests, got):
+			mu.Lock()
+			found[got] = true
+			mu.Unlock()
+			return verifyResult{file, "pass", got + " (manifest " + list.Source + ")"}
+		}
+		return verifyResult{file, "unknown", got + " (not in " + list.Source + ")"}
+	})
+
+	for _, digest := range list.Digests {
+		if !found[digest] {
+			results = append(results, verifyResult{digest, "missing", "no file in " + dir + " has this digest from " + list.Source})
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+
+	"ggufDownloader/pkg/ollamareg"
+)
+
+// localModel is a model file on disk considered for cleanup
+type localModel struct {
+	Name string
+	Path string
+	Size int64
+	// DiskUsage is what deleting the file frees; a sparse partial download occupies
+	// less than its size
+	DiskUsage int64
+	// Partial is set while the file is an unfinished download that can be resumed
+	Partial  bool
+	LastUsed time.Time
+}
+
+// label names the model, marking an unfinished download
+func (m localModel) label() string {
+	if m.Partial {
+		return m.Name + " (partial)"
+	}
+	return m.Name
+}
+
+// collectLocalModels gathers model files from the ledger and from dir, skipping files that no longer exist
+func collectLocalModels(dir string) ([]localModel, error) {
+	entries, err := loadLedger()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var models []localModel
+	add := func(name, path string) {
+		abs, err := filepath.Abs(path)
+		if err != nil || seen[abs] {
+			return
+		}
+		info, err := os.Stat(abs)
+		if err != nil || info.IsDir() {
+			return
+		}
+		seen[abs] = true
+		_, err = os.Stat(ollamareg.ResumeStatePath(abs))
+		m := localModel{Name: name, Path: abs, Size: info.Size(), DiskUsage: fileDiskUsage(abs, info),
+			Partial: err == nil || strings.HasSuffix(abs, ".part"), LastUsed: fileAccessTime(info)}
+		// A model's vision projectors go with it
+		if !strings.HasSuffix(abs, ".part") {
+			for _, p := range projectorsBeside(abs) {
+				if info, err := os.Stat(p); err == nil && !seen[p] {
+					seen[p] = true
+					m.Size += info.Size()
+					m.DiskUsage += fileDiskUsage(p, info)
+				}
+			}
+		}
+		models = append(models, m)
+	}
+
+	for _, e := range entries {
+		add(e.Model+":"+e.Params, e.Path)
+	}
+
+	files, err := modelFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		add(strings.TrimSuffix(filepath.Base(f), ".gguf"), f)
+	}
+	// Interrupted downloads set aside for resuming take space too
+	parts, err := filepath.Glob(filepath.Join(dir, "*.gguf.part"))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range parts {
+		if !isProjector(f) {
+			add(strings.TrimSuffix(filepath.Base(f), ".gguf.part"), f)
+		}
+	}
+	return models, nil
+}
+
+// formatAge renders how long ago t was in the same style as the ollama.com listings
+func formatAge(t time.Time) string {
+	d := time.Since(t)
+	switch {
+	case d < time.Hour:
+		return "just now"
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%d hours ago", int(d.Hours()))
+	case d < 14*24*time.Hour:
+		return fmt.Sprintf("%d days ago", int(d.Hours()/24))
+	case d < 60*24*time.Hour:
+		return fmt.Sprintf("%d weeks ago", int(d.Hours()/(24*7)))
+	default:
+		return fmt.Sprintf("%d months ago", int(d.Hours()/(24*30)))
+	}
+}
+
+var suggestCleanupCommand = &Command{
+	Name:    "suggest-cleanup",
+	Summary: "Recommend local models to delete to free disk space",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		dir := fs.String("dir", ".", "Directory to scan for .gguf files in addition to the ledger")
+		freeArg := fs.String("free", "", "Amount of space to free (e.g., 20G); lists every model when empty")
+		by := fs.String("by", "age", "Order candidates by least recently used (age) or largest (size)")
+		return func(args []string) error {
+			var want int64
+			if *freeArg != "" {
+				var err error
+				if want, err = parseSize(*freeArg); err != nil {
+					return err
+				}
+			}
+			return suggestCleanup(*dir, want, *by)
+		}
+	},
+}
+
+// suggestCleanup recommends model files to delete, oldest or largest first, until want bytes are freed
+func suggestCleanup(dir string, want int64, by string) error {
+	models, err := collectLocalModels(dir)
+	if err != nil {
+		return err
+	}
+
+	switch by {
+	case "age":
+		sort.Slice(models, func(i, j int) bool {
+			if models[i].LastUsed.Equal(models[j].LastUsed) {
+				return models[i].DiskUsage > models[j].DiskUsage
+			}
+			return models[i].LastUsed.Before(models[j].LastUsed)
+		})
+	case "size":
+		sort.Slice(models, func(i, j int) bool {
+			if models[i].DiskUsage == models[j].DiskUsage {
+				return models[i].LastUsed.Before(models[j].LastUsed)
+			}
+			return models[i].DiskUsage > models[j].DiskUsage
+		})
+	default:
+		return fmt.Errorf("unknown ordering %q (use age or size)", by)
+	}
+
+	if free, err := diskFree(dir); err == nil {
+		fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Free space on %s: %s", dir, formatBytes(free)))
+	}
+	if len(models) == 0 {
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] No local models found."))
+		return nil
+	}
+
+	nameWidth := 30
+	for _, m := range models {
+		if n := len(m.label()); n > nameWidth-3 {
+			nameWidth = n + 3
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf(color.CyanString("%-*s%-12s%-12s%-16s%s\n", nameWidth, "MODEL", "SIZE", "ON DISK", "LAST USED", "PATH"))
+	fmt.Println(color.CyanString(strings.Repeat("-", nameWidth+12+12+16+20)))
+
+	// Deleting a file frees the blocks it occupies, not its apparent size
+	var freed int64
+	for _, m := range models {
+		if want > 0 && freed >= want {
+			break
+		}
+		freed += m.DiskUsage
+		fmt.Printf(color.GreenString("%-*s", nameWidth, m.label()))
+		fmt.Printf(color.YellowString("%-12s%-12s", formatBytes(m.Size), formatBytes(m.DiskUsage)))
+		fmt.Printf(color.WhiteString("%-16s%s\n", formatAge(m.LastUsed), m.Path))
+	}
+
+	fmt.Println()
+	if want > 0 && freed < want {
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Deleting every model frees only %s of the requested %s", formatBytes(freed), formatBytes(want)))
+		return nil
+	}
+	fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Deleting the models above frees %s", formatBytes(freed)))
+	return nil
+}
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+
+	"ggufDownloader/pkg/ollamareg"
+)
+
+// Command is a subcommand of the CLI
+type Command struct {
+	Name    string
+	Usage   string
+	Summary string
+	// PassThrough stops flag parsing at the first argument so nested subcommands can parse their own flags
+	PassThrough bool
+	// Setup registers the command's flags and returns the function that runs it with the positional arguments
+	Setup func(fs *flag.FlagSet) func(args []string) error
+}
+
+// commands is the command table, in the order shown by help
+var commands []*Command
+
+func init() {
+	commands = []*Command{
+		listCommand,
+		searchCommand,
+		findCommand,
+		newCommand,
+		feedCommand,
+		tagsCommand,
+		registryCatalogCommand,
+		pullCommand,
+		batchCommand,
+		mirrorCommand,
+		inputCommand,
+		resolveCommand,
+		shareCommand,
+		metaCommand,
+		convertCommand,
+		inspectCommand,
+		verifyCommand,
+		repairCommand,
+		aliasCommand,
+		verifyAllCommand,
+		checkCommand,
+		updatesCommand,
+		indexCommand,
+		cacheCommand,
+		suggestCleanupCommand,
+		statsCommand,
+		licensesCommand,
+		configCommand,
+		serveCommand,
+		tuneCommand,
+		diagnoseCommand,
+		helpCommand,
+	}
+}
+
+// lookupCommand returns the command with the given name, or nil
+func lookupCommand(name string) *Command {
+	for _, cmd := range commands {
+		if cmd.Name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// globalFlags are accepted by every command
+type globalFlags struct {
+	profile  *string
+	minTLS   *string
+	pins     *string
+	plain    *bool
+	renderer *string
+	ipv4Only *bool
+	ipv6Only *bool
+	project  *string
+	crawl    *time.Duration
+	rate     *string
+	lowMem   *bool
+	bg       *bool
+	hashRate *string
+	registry *string
+	fresh    *bool
+	direct   *bool
+	fsync    *bool
+	conns    *int
+	maxConns *int
+	chunk    *string
+	tunnel   *string
+	idle     *time.Duration
+	connect  *time.Duration
+	manifest *time.Duration
+	transfer *time.Duration
+	quiet    *bool
+	privacy  *bool
+	record   *string
+	replay   *string
+}
+
+// addGlobalFlags registers the flags shared by every command
+func addGlobalFlags(fs *flag.FlagSet) *globalFlags {
+	return &globalFlags{
+		profile:  fs.String("profile", "", "Config profile to use for connection settings"),
+		minTLS:   fs.String("min-tls", "", "Minimum TLS version to accept (1.2 or 1.3)"),
+		pins:     fs.String("pin", "", "Comma-separated SPKI pins (sha256/<base64>) for the registry"),
+		privacy:  fs.Bool("privacy", false, "Contact only the registry: no ollama.com searches, Hugging Face lookups or telemetry"),
+		quiet:    fs.Bool("q", false, "Quiet: print only the final error, like wget -q; prompts take their non-interactive answer"),
+		plain:    fs.Bool("plain", false, "Disable colors and print progress as plain lines (for terminals that garble carriage returns); same as -renderer plain"),
+		renderer: fs.String("renderer", "auto", "Output style: plain lines, progress bar, or tui to also allow full-screen views (auto picks from TERM and whether stderr is a terminal)"),
+		ipv4Only: fs.Bool("4", false, "Connect over IPv4 only"),
+		ipv6Only: fs.Bool("6", false, "Connect over IPv6 only"),
+		project:  fs.String("project", activeProject, "Project namespace for downloads, ledger and caches"),
+		crawl:    fs.Duration("crawl-delay", 0, "Minimum delay between requests to ollama.com (default 500ms)"),
+		rate:     fs.String("limit-rate", "", "Maximum download rate per second, shared by every instance on this machine (e.g., 10M)"),
+		lowMem:   fs.Bool("low-memory", false, "Stream listings and catalogs instead of holding them in memory"),
+		bg:       fs.Bool("background", false, "Lower CPU and I/O priority so long transfers do not slow down the machine"),
+		hashRate: fs.String("hash-rate", "", "Maximum hashing speed per second, bounding its CPU use (e.g., 200M)"),
+		registry: fs.String("registry-host", "", "Private OCI registry to use instead of "+DefaultRegistryHost+" (host[:port] or http(s)://host[:port])"),
+		fresh:    fs.Bool("fresh", false, "Fetch manifests and tags and parse GGUF headers again instead of reusing stored metadata"),
+		conns:    fs.Int("connections", 0, "Connections per download (0 chooses from the blob size and round-trip time)"),
+		maxConns: fs.Int("max-connections", 0, "Transfer connections open at once across all downloads, e.g. of batch -jobs (0 for no limit)"),
+		chunk:    fs.String("chunk-size", "", "Size of the ranges a download split over several connections requests (e.g., 64M; empty chooses from the blob size)"),
+		tunnel:   fs.String("ssh-tunnel", "", "Route every request through a SOCKS forward over SSH to this bastion ([user@]host[:port])"),
+		idle:     fs.Duration("idle-timeout", ollamareg.DefaultIdleTimeout, "Re-dial a transfer that receives nothing for this long, switching to IPv4 unless -6 is set (0 waits forever)"),
+		connect:  fs.Duration("connect-timeout", defaultConnectTimeout, "Give up on a connection whose TCP connect and TLS handshake take longer than this"),
+		manifest: fs.Duration("manifest-timeout", defaultManifestTimeout, "Give up resolving a tag to its manifest after this long (0 waits forever)"),
+		transfer: fs.Duration("transfer-timeout", 0, "Give up on a download that has not finished after this long, keeping it to resume (0 for no limit)"),
+		fsync:    fs.Bool("fsync", true, "Sync each download to disk when it finishes or is interrupted, so a crash cannot lose its tail"),
+		record:   fs.String("record", "", "Record every registry and ollama.com exchange to this cassette file, for reproducing a bug"),
+		replay:   fs.String("replay", "", "Answer requests from a cassette written by -record instead of the network"),
+		direct:   fs.Bool("direct-io", false, "Write downloads around the page cache (O_DIRECT on Linux, F_NOCACHE on macOS, write-through on Windows)"),
+	}
+}
+
+// apply configures the console, project and HTTP client from the parsed global flags
+func (g *globalFlags) apply() error {
+	renderer := *g.renderer
+	if *g.plain {
+		renderer = "plain"
+	}
+	if err := setupConsole(renderer); err != nil {
+		return err
+	}
+	if *g.quiet {
+		if err := silenceStatus(); err != nil {
+			return err
+		}
+	}
+	lowMemory = *g.lowMem
+	freshMetadata = *g.fresh
+	directIO = *g.direct
+	fsyncDownloads = *g.fsync
+	downloadConnections = *g.conns
+	setConnectionBudget(*g.maxConns)
+	if downloadChunkSize = 0; *g.chunk != "" {
+		size, err := parseSize(*g.chunk)
+		if err != nil {
+			return fmt.Errorf("-chunk-size: %w", err)
+		}
+		if size < 1<<20 {
+			return errors.New("-chunk-size must be at least 1M")
+		}
+		downloadChunkSize = size
+	}
+	idleTimeout = *g.idle
+	if *g.connect <= 0 {
+		return errors.New("-connect-timeout must be positive")
+	}
+	connectTimeout, manifestTimeout, transferTimeout = *g.connect, *g.manifest, *g.transfer
+
+	if err := setProject(*g.project); err != nil {
+		return err
+	}
+	if err := setupRateLimit(*g.rate); err != nil {
+		return err
+	}
+	if err := setupResourceLimits(*g.bg, *g.hashRate); err != nil {
+		return err
+	}
+
+	if *g.record != "" && *g.replay != "" {
+		return errors.New("-record and -replay are mutually exclusive")
+	}
+	overrides := TransportOptions{MinTLS: *g.minTLS, Registry: *g.registry, SSHTunnel: *g.tunnel, Privacy: *g.privacy, Record: *g.record, Replay: *g.replay}
+	if *g.pins != "" {
+		overrides.Pins = strings.Split(*g.pins, ",")
+	}
+	switch {
+	case *g.ipv4Only && *g.ipv6Only:
+		return errors.New("-4 and -6 are mutually exclusive")
+	case *g.ipv4Only:
+		overrides.Family = "4"
+	case *g.ipv6Only:
+		overrides.Family = "6"
+	}
+	return setupHTTPClient(*g.profile, overrides, CrawlOptions{Delay: *g.crawl})
+}
+
+// pullFlags are accepted by every command that downloads models
+type pullFlags struct {
+	transform  *string
+	registerAs *string
+	ifExists   *string
+	hfFallback *bool
+	runScript  *bool
+	printPath  *bool
+	output     *string
+	cross      *string
+	noVerify   *bool
+	outFile    *string
+	scanHook   *string
+	copyTo     []string
+	naming     NamingPolicy
+	noProj     *bool
+	full       *bool
+	modelfile  *bool
+	install    *bool
+}
+
+// addPullFlags registers the flags controlling how models are downloaded
+func addPullFlags(fs *flag.FlagSet) *pullFlags {
+	p := &pullFlags{
+		transform:  fs.String("transform", "", "Shell command to pipe the blob through while downloading (stdin -> stdout)"),
+		registerAs: fs.String("register-ollama", "", "After downloading, register the model with the local Ollama under this name"),
+		ifExists:   fs.String("if-exists", "overwrite", "When the output file exists: "+strings.Join(existsPolicies, ", ")),
+		hfFallback: fs.Bool("hf-fallback", false, "If the registry cannot serve the blob, offer an equivalent GGUF from Hugging Face"),
+		runScript:  fs.Bool("run-script", false, "Write the suggested llama.cpp run command next to the model as MODEL:TAG.sh"),
+		printPath:  fs.Bool("print-path", false, "Print only the absolute path of each downloaded file on stdout"),
+		cross:      fs.String("cross-device", "ask", "When the cached blob is on another filesystem: "+strings.Join(crossDevicePolicies, ", ")),
+		output:     fs.String("output", "", "Stream the blob to ssh://[user@]host[:port]/path instead of a local file, verified remotely"),
+		noVerify:   fs.Bool("no-verify", false, "Skip checking the downloaded file against its digest"),
+		scanHook:   fs.String("scan-hook", "", "Command that checks each downloaded file, {path} (or {{.Path}}) substituted; a non-zero exit rejects it (default: \"scan_hook\" from the config file)"),
+		full:       fs.Bool("full", false, "Save every layer (weights as model.gguf, template.txt, params.json, license.txt, adapters, config and manifest) in a directory per model"),
+		modelfile:  fs.Bool("modelfile", false, "Write an Ollama Modelfile next to the model (MODEL:TAG.Modelfile) from its template, system and params layers"),
+		install:    fs.Bool("install", false, "Also write the model's blobs and manifest into the local Ollama store (~/.ollama/models or $OLLAMA_MODELS)"),
+		noProj:     fs.Bool("no-projector", false, "Do not download the vision projector (MODEL.mmproj.gguf) of a multimodal model"),
+		outFile:    fs.String("O", "", "Save the model to this file instead of the name chosen by -naming, like wget -O"),
+	}
+	fs.Func("copy-to", "Also write each download into this directory as it arrives (repeatable)", func(dir string) error {
+		p.copyTo = append(p.copyTo, dir)
+		return nil
+	})
+	fs.BoolFunc("c", "Continue a partial download, like wget -c (same as -if-exists resume)", func(string) error {
+		return fs.Set("if-exists", "resume")
+	})
+	fs.Func("naming", "File naming policy: "+namingPolicyNames()+", or a template such as {family}-{params}-{quant}.gguf (default ollama)", func(name string) (err error) {
+		p.naming, err = parseNamingPolicy(name)
+		return err
+	})
+	return p
+}
+
+// options converts the parsed flags into PullOptions
+func (p *pullFlags) options() PullOptions {
+	opts := PullOptions{RegisterAs: *p.registerAs, IfExists: *p.ifExists, HFFallback: *p.hfFallback, RunScript: *p.runScript, PrintPath: *p.printPath, Output: *p.output, CrossDevice: *p.cross, NoVerify: *p.noVerify, OutputFile: *p.outFile, ScanHook: *p.scanHook, CopyTo: p.copyTo, Naming: p.naming, NoProjector: *p.noProj, Full: *p.full, Modelfile: *p.modelfile, Install: *p.install}
+	if *p.transform != "" {
+		opts.Transform = ExecTransformer{Command: *p.transform}
+	}
+	return opts
+}
+
+// parseInterspersed parses flags that may appear before, between or after positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// runCommand parses the command's flags and runs it
+func runCommand(cmd *Command, args []string) error {
+	fs := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+	global := addGlobalFlags(fs)
+	run := cmd.Setup(fs)
+	fs.Usage = func() { printCommandHelp(cmd, fs) }
+
+	var positional []string
+	var err error
+	if cmd.PassThrough {
+		err = fs.Parse(args)
+		positional = fs.Args()
+	} else {
+		positional, err = parseInterspersed(fs, args)
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := global.apply(); err != nil {
+		return err
+	}
+	return withTelemetry(cmd.Name, func() error { return run(positional) })
+}
+
+// printCommandHelp prints the usage line and flags of a single command
+func printCommandHelp(cmd *Command, fs *flag.FlagSet) {
+	fmt.Println(color.CyanString("\nUsage: ggufDownloader %s %s", cmd.Name, cmd.Usage))
+	fmt.Println(color.WhiteString("  %s", cmd.Summary))
+	fmt.Println(color.CyanString("\nFlags:"))
+	fs.SetOutput(os.Stdout)
+	fs.PrintDefaults()
+}
+
+// printHelp lists every command
+func printHelp() {
+	fmt.Println(color.CyanString("\nUsage: ggufDownloader <command> [flags] [arguments]"))
+	fmt.Println(color.CyanString("\nCommands:"))
+	for _, cmd := range commands {
+		fmt.Printf("  %s%s\n", color.GreenString("%-17s", cmd.Name), cmd.Summary)
+	}
+	fmt.Println(color.WhiteString("\nRun 'ggufDownloader help COMMAND' for the flags of a command."))
+	fmt.Println(color.WhiteString("The classic form 'ggufDownloader -model NAME -params TAG' still works."))
+}
+
+var helpCommand = &Command{
+	Name:    "help",
+	Usage:   "[COMMAND]",
+	Summary: "Show help for a command",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		return func(args []string) error {
+			if len(args) == 0 {
+				printHelp()
+				return nil
+			}
+			cmd := lookupCommand(args[0])
+			if cmd == nil {
+				return fmt.Errorf("unknown command %q", args[0])
+			}
+			cmdFlags := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+			addGlobalFlags(cmdFlags)
+			cmd.Setup(cmdFlags)
+			printCommandHelp(cmd, cmdFlags)
+			return nil
+		}
+	},
+}
+
+// runLegacy handles the original single-command flag interface (-model/-params/-list/...)
+func runLegacy(args []string) error {
+	fs := flag.NewFlagSet("ggufDownloader", flag.ContinueOnError)
+	global := addGlobalFlags(fs)
+	pull := addPullFlags(fs)
+	modelName := fs.String("model", "", "The name of the model to download (e.g., phi3)")
+	modelParameters := fs.String("params", "", "The model parameters to use (e.g., 3.8b)")
+	listModels := fs.Bool("list", false, "List available models")
+	listTags := fs.String("tags", "", "List the tags of this model with their sizes and quantizations, the values -params accepts")
+	batchFile := fs.String("batch", "", "Download every model listed in this batch file")
+	serveAddr := fs.String("serve", "", "Run as a daemon exposing the job API on this address (e.g., :8080)")
+	source := fs.String("source", "ollama", "Where -model is found: ollama, or hf for a Hugging Face repository such as TheBloke/Llama-2-7B-GGUF")
+	hfName := fs.String("file", "", "With -source hf, the GGUF file (or quantization) of the repository to download")
+	fs.Usage = func() {
+		printHelp()
+		fmt.Println(color.CyanString("\nClassic flags:"))
+		fs.SetOutput(os.Stdout)
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if err := global.apply(); err != nil {
+		return err
+	}
+
+	return withTelemetry("classic", func() error {
+		switch {
+		case *serveAddr != "":
+			return serve(*serveAddr, pull.options(), defaultDaemonOptions())
+		case *batchFile != "":
+			return runBatch(*batchFile, pull.options(), "", 1)
+		case len(args) == 0:
+			// No arguments at all: show the most popular models and the basics
+			return listModelsCommand(false, true)
+		case *listModels:
+			return listModelsCommand(true, false)
+		case *listTags != "":
+			return listTagsCommand(*listTags)
+		}
+
+		if !slices.Contains(modelSources, *source) {
+			return fmt.Errorf("unknown -source %q (use %s)", *source, strings.Join(modelSources, ", "))
+		}
+		if *source == "hf" {
+			switch {
+			case *modelName == "":
+				return errors.New("-source hf needs the repository in -model, e.g. TheBloke/Llama-2-7B-GGUF")
+			case *hfName == "":
+				return listHFCommand(*modelName)
+			}
+			filename, err := pullHuggingFace(interruptContext(), *modelName, *hfName, pull.options())
+			if err != nil {
+				return err
+			}
+			reportPulled(filename, pull.options())
+			return nil
+		}
+
+		// Only check for required parameters if we're trying to download a model
+		if *modelName == "" || *modelParameters == "" {
+			displayUsageExamples()
+			fmt.Println(color.CyanString("\nRun without arguments to see available models."))
+			return errors.New("model name and parameters are required")
+		}
+		name, err := normalizeModelName(*modelName)
+		if err != nil {
+			return err
+		}
+		return pullAndReport(name, *modelParameters, pull.options())
+	})
+}
+
+// runCLI dispatches to a subcommand, or to the classic flag interface when the first argument is a flag
+func runCLI(args []string) error {
+	defer func() {
+		if downloadLimiter != nil {
+			downloadLimiter.close()
+		}
+	}()
+
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return runLegacy(args)
+	}
+
+	cmd := lookupCommand(args[0])
+	if cmd == nil {
+		printHelp()
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	return runCommand(cmd, args[1:])
+}
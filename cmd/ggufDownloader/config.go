@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Config holds the persistent settings loaded from the user's config file.
+// Fields tagged `secret:"true"` are never written to exported bundles.
+type Config struct {
+	DefaultProfile string             `json:"default_profile,omitempty"`
+	Profiles       map[string]Profile `json:"profiles,omitempty"`
+	// Converter turns a safetensors directory into a GGUF file; {dir}, {out} and {repo} are substituted
+	Converter string `json:"converter,omitempty"`
+	// ScanHook checks every pulled file before it is used, e.g. "clamscan --no-summary {path}";
+	// a non-zero exit rejects the file
+	ScanHook string `json:"scan_hook,omitempty"`
+	// Aliases name groups of models pulled together with "pull @NAME"
+	Aliases map[string]modelList `json:"aliases,omitempty"`
+	// Mirrors name directories kept up to date with "mirror NAME"
+	Mirrors map[string]MirrorConfig `json:"mirrors,omitempty"`
+}
+
+// modelList is a list of model references, written in the config either as a JSON
+// array or as one comma-separated string
+type modelList []string
+
+func (l *modelList) UnmarshalJSON(data []byte) error {
+	var refs []string
+	if err := json.Unmarshal(data, &refs); err != nil {
+		var joined string
+		if json.Unmarshal(data, &joined) != nil {
+			return errors.New("expected a list of models or a comma-separated string")
+		}
+		refs = strings.Split(joined, ",")
+	}
+	*l = (*l)[:0]
+	for _, ref := range refs {
+		if ref = strings.TrimSpace(ref); ref != "" {
+			*l = append(*l, ref)
+		}
+	}
+	return nil
+}
+
+// expandAliases replaces every "@NAME" argument with the models of that alias, which
+// may itself refer to other aliases
+func (c *Config) expandAliases(args []string) ([]string, error) {
+	var out []string
+	var expand func(ref string, seen []string) error
+	expand = func(ref string, seen []string) error {
+		name, ok := strings.CutPrefix(ref, "@")
+		if !ok {
+			out = append(out, ref)
+			return nil
+		}
+		if slices.Contains(seen, name) {
+			return fmt.Errorf("alias @%s refers to itself (%s)", name, strings.Join(append(seen, name), " -> "))
+		}
+		models, ok := c.Aliases[name]
+		if !ok {
+			return fmt.Errorf("unknown alias @%s; define it under \"aliases\" in the config", name)
+		}
+		for _, m := range models {
+			if err := expand(m, append(seen, name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, arg := range args {
+		if err := expand(arg, nil); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// Profile is a named set of connection settings
+type Profile struct {
+	MinTLS           string   `json:"min_tls,omitempty"`
+	Pins             []string `json:"pins,omitempty"`
+	CrawlConcurrency int      `json:"crawl_concurrency,omitempty"`
+	CrawlDelay       string   `json:"crawl_delay,omitempty"`
+	// Registry replaces registry.ollama.ai, e.g. with an internal mirror
+	Registry      string `json:"registry,omitempty"`
+	RegistryToken string `json:"registry_token,omitempty" secret:"true"`
+	// Namespace is where bare model names live on the registry instead of "library"
+	Namespace string `json:"namespace,omitempty"`
+	// SSHTunnel routes every request through a SOCKS forward over SSH to this bastion
+	SSHTunnel string `json:"ssh_tunnel,omitempty"`
+	// Privacy limits every request to the registry, for compliance-restricted environments
+	Privacy bool `json:"privacy,omitempty"`
+}
+
+// configPath returns the location of the config file
+func configPath() (string, error) {
+	if path := os.Getenv("GGUF_DOWNLOADER_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ggufDownloader", "config.json"), nil
+}
+
+// dataDir returns the directory holding state the tool keeps between runs
+func dataDir() (string, error) {
+	if dir := os.Getenv("GGUF_DOWNLOADER_HOME"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".ggufDownloader"), nil
+}
+
+// activeProject namespaces downloads, the ledger and caches when set with -project
+var activeProject = os.Getenv("GGUF_DOWNLOADER_PROJECT")
+
+// setProject selects the project namespace, rejecting names that would escape the projects directory
+func setProject(name string) error {
+	if name != "" && (name == "." || name == ".." || strings.ContainsAny(name, `/\`)) {
+		return fmt.Errorf("invalid project name %q", name)
+	}
+	activeProject = name
+	return nil
+}
+
+// projectDir returns the directory holding the active project's ledger and caches
+func projectDir() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	if activeProject == "" {
+		return dir, nil
+	}
+	return filepath.Join(dir, "projects", activeProject), nil
+}
+
+// loadConfig reads the config file, returning an empty config if it does not exist
+func loadConfig() (*Config, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// writeJSONFile atomically replaces path with the indented JSON encoding of v
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'), 0o644)
+}
+
+// writeFileAtomic replaces path with data readable as perm, creating its directory if needed
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// A temporary file of its own keeps concurrent writers from renaming each other's
+	// half-written data into place
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// profile returns the named profile, falling back to the default profile when name is empty
+func (c *Config) profile(name string) (Profile, error) {
+	if name == "" {
+		name = c.DefaultProfile
+	}
+	if name == "" {
+		return Profile{}, nil
+	}
+
+	p, ok := c.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("profile %q not found in config", name)
+	}
+	return p, nil
+}
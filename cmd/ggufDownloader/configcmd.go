@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// configBundleVersion is bumped when the export format changes incompatibly
+const configBundleVersion = 1
+
+// configBundle is the shareable file produced by "config export"
+type configBundle struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	Config     Config    `json:"config"`
+}
+
+// saveConfig writes cfg to the config file, readable only by its owner since profiles
+// may hold a registry_token
+func saveConfig(cfg *Config) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'), 0o600)
+}
+
+// stripSecrets zeroes every struct field tagged `secret:"true"` reachable from v
+func stripSecrets(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			stripSecrets(v.Elem())
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).Tag.Get("secret") == "true" {
+				v.Field(i).Set(reflect.Zero(v.Field(i).Type()))
+				continue
+			}
+			stripSecrets(v.Field(i))
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			stripSecrets(v.Index(i))
+		}
+	case reflect.Map:
+		// Map values are not addressable, so scrub a copy and store it back
+		for _, key := range v.MapKeys() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+			stripSecrets(elem)
+			v.SetMapIndex(key, elem)
+		}
+	}
+}
+
+// bundleFields holds the keys a bundle's JSON actually spells out, at the top of its
+// config and in each profile, so an import can turn a setting off or clear it as well
+// as set it, and leaves alone what the bundle does not mention
+type bundleFields struct {
+	Config   map[string]json.RawMessage
+	Profiles map[string]map[string]json.RawMessage
+}
+
+// parseBundleFields reads the keys present in the config of a bundle
+func parseBundleFields(data []byte) (bundleFields, error) {
+	var raw struct {
+		Config map[string]json.RawMessage `json:"config"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return bundleFields{}, err
+	}
+	fields := bundleFields{Config: raw.Config}
+	if profiles, ok := raw.Config["profiles"]; ok {
+		if err := json.Unmarshal(profiles, &fields.Profiles); err != nil {
+			return bundleFields{}, err
+		}
+	}
+	return fields, nil
+}
+
+// jsonName returns the key a struct field is written under
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// overlayFields sets each field of the struct dst whose key is present to the one of
+// src. Secrets are never taken from a bundle, so local ones keep their value.
+func overlayFields(dst, src reflect.Value, present map[string]json.RawMessage) {
+	for i := 0; i < src.NumField(); i++ {
+		field := src.Type().Field(i)
+		if _, ok := present[jsonName(field)]; !ok || field.Tag.Get("secret") == "true" {
+			continue
+		}
+		dst.Field(i).Set(src.Field(i))
+	}
+}
+
+// merge overlays the settings from other, whose keys present in the bundle are fields,
+// onto c: same-named profiles are merged field by field, keeping local secrets, and
+// same-named aliases and mirrors are replaced
+func (c *Config) merge(other Config, fields bundleFields) {
+	for key, value := range map[string]struct{ dst, src *string }{
+		"default_profile": {&c.DefaultProfile, &other.DefaultProfile},
+		"converter":       {&c.Converter, &other.Converter},
+		"scan_hook":       {&c.ScanHook, &other.ScanHook},
+	} {
+		if _, ok := fields.Config[key]; ok {
+			*value.dst = *value.src
+		}
+	}
+	if len(other.Profiles) > 0 && c.Profiles == nil {
+		c.Profiles = make(map[string]Profile)
+	}
+	for name, p := range other.Profiles {
+		merged := c.Profiles[name]
+		overlayFields(reflect.ValueOf(&merged).Elem(), reflect.ValueOf(p), fields.Profiles[name])
+		c.Profiles[name] = merged
+	}
+	if len(other.Aliases) > 0 && c.Aliases == nil {
+		c.Aliases = make(map[string]modelList)
+	}
+	for name, models := range other.Aliases {
+		c.Aliases[name] = models
+	}
+	if len(other.Mirrors) > 0 && c.Mirrors == nil {
+		c.Mirrors = make(map[string]MirrorConfig)
+	}
+	for name, m := range other.Mirrors {
+		c.Mirrors[name] = m
+	}
+}
+
+var configCommand = &Command{
+	Name:        "config",
+	Usage:       "export [FILE] | import [-replace] [-allow-commands] FILE",
+	Summary:     "Export or import shareable settings",
+	PassThrough: true,
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		return func(args []string) error {
+			if len(args) == 0 {
+				return errors.New("usage: config export [FILE] | config import [-replace] [-allow-commands] FILE")
+			}
+
+			switch args[0] {
+			case "export":
+				return exportConfig(args[1:])
+			case "import":
+				return importConfig(args[1:])
+			default:
+				return fmt.Errorf("unknown config command %q (use export or import)", args[0])
+			}
+		}
+	},
+}
+
+// exportConfig writes the current config, without secrets, to a file or stdout
+func exportConfig(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	stripSecrets(reflect.ValueOf(cfg))
+
+	data, err := json.MarshalIndent(configBundle{
+		Version:    configBundleVersion,
+		ExportedAt: time.Now().UTC(),
+		Config:     *cfg,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if len(args) == 0 || args[0] == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(args[0], data, 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] Config exported to %s (secrets excluded)", args[0]))
+	return nil
+}
+
+// importConfig merges an exported bundle into the local config
+func importConfig(args []string) error {
+	fs := flag.NewFlagSet("config import", flag.ContinueOnError)
+	replace := fs.Bool("replace", false, "Replace the local config instead of merging into it")
+	allowCommands := fs.Bool("allow-commands", false, "Also import the converter and scan_hook commands, which the tool runs")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: config import [-replace] [-allow-commands] FILE")
+	}
+
+	var data []byte
+	var err error
+	if fs.Arg(0) == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(fs.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+
+	var bundle configBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return fmt.Errorf("invalid config bundle: %w", err)
+	}
+	if bundle.Version != configBundleVersion {
+		return fmt.Errorf("unsupported config bundle version %d (expected %d)", bundle.Version, configBundleVersion)
+	}
+	stripSecrets(reflect.ValueOf(&bundle.Config))
+	fields, err := parseBundleFields(data)
+	if err != nil {
+		return fmt.Errorf("invalid config bundle: %w", err)
+	}
+
+	cfg := &Config{}
+	if !*replace {
+		if cfg, err = loadConfig(); err != nil {
+			return err
+		}
+	}
+	// Importing someone's bundle must not make this machine run their shell commands
+	// unless asked to
+	for _, command := range []struct {
+		name           string
+		local, bundled *string
+	}{
+		{"converter", &cfg.Converter, &bundle.Config.Converter},
+		{"scan_hook", &cfg.ScanHook, &bundle.Config.ScanHook},
+	} {
+		if *command.bundled == "" || *command.bundled == *command.local {
+			continue
+		}
+		if *allowCommands {
+			fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Importing %s: %s", command.name, *command.bundled))
+			continue
+		}
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Not importing %s %q; pass -allow-commands to run this command from the bundle", command.name, *command.bundled))
+		delete(fields.Config, command.name)
+	}
+	cfg.merge(bundle.Config, fields)
+
+	if err := saveConfig(cfg); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] Imported %d profile(s), %d alias(es) and %d mirror(s) from %s", len(bundle.Config.Profiles), len(bundle.Config.Aliases), len(bundle.Config.Mirrors), fs.Arg(0)))
+	return nil
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package main
+
+// enableVirtualTerminal is a no-op outside Windows, where terminals understand ANSI escapes natively
+func enableVirtualTerminal() bool {
+	return true
+}
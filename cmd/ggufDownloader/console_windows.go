@@ -0,0 +1,30 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// enableVirtualTerminal turns on ANSI escape processing for stdout and stderr,
+// reporting false on legacy consoles that do not support it
+func enableVirtualTerminal() bool {
+	return enableVirtualTerminalOn(os.Stdout) && enableVirtualTerminalOn(os.Stderr)
+}
+
+// enableVirtualTerminalOn turns on ANSI escape processing for one console handle
+func enableVirtualTerminalOn(f *os.File) bool {
+	handle := windows.Handle(f.Fd())
+
+	var mode uint32
+	if err := windows.GetConsoleMode(handle, &mode); err != nil {
+		// Not a console (redirected to a file or pipe); escape codes pass through untouched
+		return true
+	}
+	if mode&windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING != 0 {
+		return true
+	}
+	return windows.SetConsoleMode(handle, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING) == nil
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+
+	"ggufDownloader/pkg/ollamareg"
+)
+
+// safetensorsFiles are the repository files a converter needs besides the weights
+var safetensorsFiles = []string{"tokenizer.model", "merges.txt", "vocab.txt"}
+
+// wantedRepoFile reports whether a file of a safetensors repository is downloaded for conversion
+func wantedRepoFile(name string) bool {
+	if strings.Contains(name, "/") {
+		return false
+	}
+	switch strings.ToLower(path.Ext(name)) {
+	case ".safetensors", ".json", ".tiktoken":
+		return true
+	}
+	for _, f := range safetensorsFiles {
+		if name == f {
+			return true
+		}
+	}
+	return false
+}
+
+// listSafetensorsRepo returns the files of a Hugging Face repository needed to convert it
+func listSafetensorsRepo(ctx context.Context, repo string) ([]*hfFile, error) {
+	var info struct {
+		Siblings []struct {
+			Name string `json:"rfilename"`
+			Size int64  `json:"size"`
+			LFS  *struct {
+				SHA256 string `json:"sha256"`
+				Size   int64  `json:"size"`
+			} `json:"lfs"`
+		} `json:"siblings"`
+	}
+	if err := hfGetJSON(ctx, fmt.Sprintf("https://%s/api/models/%s?blobs=true", HuggingFaceHost, repo), &info); err != nil {
+		return nil, err
+	}
+
+	var files []*hfFile
+	weights := false
+	for _, s := range info.Siblings {
+		if !wantedRepoFile(s.Name) {
+			continue
+		}
+		file := &hfFile{Repo: repo, Name: s.Name, Size: s.Size}
+		if s.LFS != nil {
+			file.SHA256, file.Size = s.LFS.SHA256, s.LFS.Size
+		}
+		files = append(files, file)
+		weights = weights || strings.HasSuffix(strings.ToLower(s.Name), ".safetensors")
+	}
+	if !weights {
+		return nil, fmt.Errorf("%s has no safetensors weights", repo)
+	}
+	return files, nil
+}
+
+// checkFor picks the payload check matching a repository file
+func checkFor(name string) func(*http.Response, []byte) error {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".safetensors":
+		return ollamareg.CheckSafetensors
+	case ".json":
+		return ollamareg.CheckJSON
+	}
+	return nil
+}
+
+// upToDate reports whether the ledger shows path was already produced and the file is unchanged on disk
+func upToDate(entry LedgerEntry, ok bool, want string) bool {
+	if !ok || (want != "" && entry.Digest != want) {
+		return false
+	}
+	info, err := os.Stat(entry.Path)
+	return err == nil && info.Size() == entry.Size
+}
+
+// fetchRepoFile downloads one repository file unless the ledger shows it is already complete, returning its digest
+func fetchRepoFile(ctx context.Context, file *hfFile, dest string, ledger map[string]LedgerEntry) (string, error) {
+	if entry, ok := ledger[dest]; upToDate(entry, ok, file.Digest()) {
+		fmt.Fprintln(os.Stderr, color.CyanString("[INFO] %s is already downloaded", file.Name))
+		return entry.Digest, nil
+	}
+
+	fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Downloading %s (%s)", file.Name, formatBytes(file.Size)))
+	if err := downloadChecked(ctx, file.URL(), dest, nil, 0, checkFor(file.Name)); err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", file.Name, err)
+	}
+	digest := file.Digest()
+	if digest != "" {
+		if err := verifyFile(dest, digest); err != nil {
+			return "", err
+		}
+	} else {
+		var err error
+		if digest, err = fileDigest(dest); err != nil {
+			return "", err
+		}
+	}
+
+	info, err := os.Stat(dest)
+	if err != nil {
+		return "", err
+	}
+	entry := LedgerEntry{Model: file.Repo, Params: "safetensors", Digest: digest, Path: dest, Size: info.Size(), DownloadedAt: time.Now()}
+	if err := recordDownload(entry); err != nil {
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Could not update download ledger: %s", err))
+	}
+	return digest, nil
+}
+
+// conversionInputs fingerprints the downloaded files and the converter command
+func conversionInputs(converter string, digests map[string]string) string {
+	names := make([]string, 0, len(digests))
+	for name := range digests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	fmt.Fprintln(h, converter)
+	for _, name := range names {
+		fmt.Fprintln(h, name, digests[name])
+	}
+	return fmt.Sprintf("sha256:%x", h.Sum(nil))
+}
+
+// expandConverter substitutes the placeholders of the converter command
+func expandConverter(converter, dir, out, repo string) string {
+	return strings.NewReplacer("{dir}", shellQuote(dir), "{out}", shellQuote(out), "{repo}", shellQuote(repo)).Replace(converter)
+}
+
+// convertRepo downloads the safetensors of a Hugging Face repository into dir and converts
+// them to out with the converter command, skipping steps the ledger shows are complete
+func convertRepo(repo, dir, out, converter string) error {
+	ctx := context.Background()
+	files, err := listSafetensorsRepo(ctx, repo)
+	if err != nil {
+		return err
+	}
+
+	entries, err := loadLedger()
+	if err != nil {
+		return err
+	}
+	ledger := make(map[string]LedgerEntry)
+	for _, e := range entries {
+		ledger[e.Path] = e
+	}
+
+	if dir, err = filepath.Abs(dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	digests := make(map[string]string)
+	for _, file := range files {
+		digest, err := fetchRepoFile(ctx, file, filepath.Join(dir, file.Name), ledger)
+		if err != nil {
+			return err
+		}
+		digests[file.Name] = digest
+	}
+	fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] %s downloaded to %s", repo, dir))
+	if converter == "" {
+		return nil
+	}
+
+	if out, err = filepath.Abs(out); err != nil {
+		return err
+	}
+	inputs := conversionInputs(converter, digests)
+	if entry, ok := ledger[out]; ok && entry.Inputs == inputs && upToDate(entry, ok, "") {
+		fmt.Fprintln(os.Stderr, color.CyanString("[INFO] %s is already converted from these files", out))
+		return nil
+	}
+
+	command := expandConverter(converter, dir, out, repo)
+	fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Converting: %s", command))
+	cmd := shellCommand(command)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("converter %q failed: %w", command, err)
+	}
+	if _, err := openGGUFMetadata(out, func(string) bool { return false }); err != nil {
+		return fmt.Errorf("converter did not produce a GGUF file: %w", err)
+	}
+
+	digest, err := fileDigest(out)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(out)
+	if err != nil {
+		return err
+	}
+	entry := LedgerEntry{Model: repo, Params: "gguf", Digest: digest, Path: out, Size: info.Size(), DownloadedAt: time.Now(), Inputs: inputs}
+	if err := recordDownload(entry); err != nil {
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Could not update download ledger: %s", err))
+	}
+	sidecar := &Sidecar{
+		Model:        repo,
+		Digest:       digest,
+		Size:         info.Size(),
+		Source:       "https://" + HuggingFaceHost + "/" + repo,
+		DownloadedAt: time.Now(),
+		Notes:        []string{"converted from safetensors with: " + command},
+	}
+	if err := writeSidecar(out, sidecar); err != nil {
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Could not write sidecar: %s", err))
+	}
+	fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] Converted %s to %s", repo, out))
+	return nil
+}
+
+var convertCommand = &Command{
+	Name:    "convert",
+	Usage:   "ORG/REPO",
+	Summary: "Download a safetensors model from Hugging Face and convert it to GGUF",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		converter := fs.String("converter", "", "Conversion command with {dir}, {out} and {repo} placeholders (default: \"converter\" from the config file)")
+		dir := fs.String("dir", "", "Directory for the safetensors files (default REPO-safetensors)")
+		out := fs.String("out", "", "Output GGUF file (default REPO.gguf)")
+		downloadOnly := fs.Bool("download-only", false, "Download the safetensors without converting them")
+		return func(args []string) error {
+			if len(args) != 1 || strings.Count(args[0], "/") != 1 {
+				return errors.New("usage: convert [-converter CMD] [-dir DIR] [-out FILE] ORG/REPO")
+			}
+			repo := args[0]
+			name := strings.ToLower(path.Base(repo))
+
+			command := *converter
+			if command == "" && !*downloadOnly {
+				cfg, err := loadConfig()
+				if err != nil {
+					return err
+				}
+				if command = cfg.Converter; command == "" {
+					return errors.New("no converter configured; pass -converter or set \"converter\" in the config file")
+				}
+			}
+			if *downloadOnly {
+				command = ""
+			}
+
+			var err error
+			if *dir == "" {
+				if *dir, err = outputPath(name + "-safetensors"); err != nil {
+					return err
+				}
+			}
+			if *out == "" {
+				if *out, err = outputPath(name + ".gguf"); err != nil {
+					return err
+				}
+			}
+			return convertRepo(repo, *dir, *out, command)
+		}
+	},
+}
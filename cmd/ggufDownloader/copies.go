@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// copyToKey carries the -copy-to directories of a pull down to its downloads
+type copyToKey struct{}
+
+// withCopies makes the downloads made under ctx also write their file into each of dirs
+func withCopies(ctx context.Context, dirs []string) context.Context {
+	if len(dirs) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, copyToKey{}, dirs)
+}
+
+// copyPaths returns where the finished copies of filename go for a download made under
+// ctx; until the download is verified they are written to COPY.part like the download
+func copyPaths(ctx context.Context, filename string) []string {
+	dirs, _ := ctx.Value(copyToKey{}).([]string)
+	var paths []string
+	// Copies are named after the finished file, not the .part it is downloaded to
+	name := filepath.Base(strings.TrimSuffix(filename, ".part"))
+	for _, dir := range dirs {
+		paths = append(paths, filepath.Join(dir, name))
+	}
+	return paths
+}
+
+// outputCopies writes a download to extra destinations as it arrives. A destination
+// that fails is dropped with a warning and its partial file removed, while the
+// download and the other copies carry on.
+type outputCopies struct {
+	mu    sync.Mutex
+	files []*os.File
+	pos   int64
+}
+
+// openCopies opens the copies of filename for a download that starts at offset, first
+// bringing each one up to offset from the bytes already on disk; nil means no copies
+func openCopies(ctx context.Context, filename string, offset int64) *outputCopies {
+	paths := copyPaths(ctx, filename)
+	if len(paths) == 0 {
+		return nil
+	}
+	c := &outputCopies{pos: offset}
+	for _, path := range paths {
+		f, err := openCopy(partialPath(path), filename, offset)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Not copying to %s: %s", path, err))
+			continue
+		}
+		c.files = append(c.files, f)
+	}
+	return c
+}
+
+// openCopy opens one copy, reusing as much of an earlier partial copy as it can
+func openCopy(path, src string, offset int64) (*os.File, error) {
+	if abs, _ := filepath.Abs(path); abs != "" {
+		if srcAbs, _ := filepath.Abs(src); abs == srcAbs {
+			return nil, fmt.Errorf("it is the download itself")
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	have := min(info.Size(), offset)
+	if err := f.Truncate(have); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if have < offset {
+		in, err := os.Open(src)
+		if err == nil {
+			_, err = io.Copy(io.NewOffsetWriter(f, have), io.NewSectionReader(in, have, offset-have))
+			in.Close()
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Write appends to every copy
+func (c *outputCopies) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeAt(p, c.pos)
+	c.pos += int64(len(p))
+	return len(p), nil
+}
+
+// WriteAt writes to every copy at off, for downloads split over several connections
+func (c *outputCopies) WriteAt(p []byte, off int64) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeAt(p, off)
+	return len(p), nil
+}
+
+func (c *outputCopies) writeAt(p []byte, off int64) {
+	kept := c.files[:0]
+	for _, f := range c.files {
+		if _, err := f.WriteAt(p, off); err != nil {
+			c.drop(f, err)
+			continue
+		}
+		kept = append(kept, f)
+	}
+	c.files = kept
+}
+
+// drop gives up on one copy
+func (c *outputCopies) drop(f *os.File, err error) {
+	fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Dropping the copy at %s: %s", f.Name(), err))
+	f.Close()
+	os.Remove(f.Name())
+}
+
+// Truncate cuts every copy to the n bytes the download kept
+func (c *outputCopies) Truncate(n int64) {
+	if c == nil {
+		return
+	}
+	for _, f := range c.files {
+		f.Truncate(n)
+	}
+}
+
+// Discard removes every copy, for a download whose own file is removed
+func (c *outputCopies) Discard() {
+	if c == nil {
+		return
+	}
+	for _, f := range c.files {
+		f.Close()
+		os.Remove(f.Name())
+	}
+	c.files = nil
+}
+
+// Close finishes every copy, syncing it unless -fsync=false; one that cannot be flushed
+// is dropped like a failed write
+func (c *outputCopies) Close() {
+	if c == nil {
+		return
+	}
+	for _, f := range c.files {
+		var err error
+		if fsyncDownloads {
+			err = f.Sync()
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Dropping the copy at %s: %s", f.Name(), err))
+			os.Remove(f.Name())
+		}
+	}
+	c.files = nil
+}
+
+// copyFinished copies a file that did not come through a download, such as a cached
+// blob, to each of paths, cloning where the filesystem allows; like a download's copies
+// they only appear under their name once complete
+func copyFinished(src string, paths []string) {
+	for _, path := range paths {
+		part := partialPath(path)
+		err := os.MkdirAll(filepath.Dir(path), 0o755)
+		if err == nil {
+			os.Remove(part)
+			if err = reflinkFile(src, part); err != nil {
+				err = copyFile(src, part)
+			}
+		}
+		if err == nil {
+			err = os.Rename(part, path)
+		}
+		if err != nil {
+			os.Remove(part)
+			fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Could not copy %s to %s: %s", src, path, err))
+		}
+	}
+}
+
+// finishCopies gives the copies of a verified download of filename their final names
+func finishCopies(ctx context.Context, filename string) {
+	for _, path := range copyPaths(ctx, filename) {
+		part := partialPath(path)
+		if _, err := os.Stat(part); err != nil {
+			// Dropped along the way
+			continue
+		}
+		if err := os.Rename(part, path); err != nil {
+			fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Dropping the copy at %s: %s", path, err))
+			os.Remove(part)
+		}
+	}
+}
+
+// discardCopies removes the partial copies of a download of filename that is discarded
+func discardCopies(ctx context.Context, filename string) {
+	for _, path := range copyPaths(ctx, filename) {
+		os.Remove(partialPath(path))
+	}
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// diagnosticsAfter is how many failures in a row of the same download lead to the
+// offer of a diagnostics bundle
+const diagnosticsAfter = 2
+
+// maxTracedRequests bounds the request trace kept in memory and in the failure record
+const maxTracedRequests = 50
+
+// requestTrace is one HTTP exchange as recorded for diagnostics
+type requestTrace struct {
+	Time     time.Time           `json:"time"`
+	Method   string              `json:"method"`
+	URL      string              `json:"url"`
+	Range    string              `json:"range,omitempty"`
+	Status   string              `json:"status,omitempty"`
+	Header   map[string][]string `json:"header,omitempty"`
+	Duration string              `json:"duration"`
+	Error    string              `json:"error,omitempty"`
+}
+
+// tracingTransport records the most recent exchanges so a failure can be explained later
+type tracingTransport struct {
+	next http.RoundTripper
+}
+
+var (
+	traceMu sync.Mutex
+	traces  []requestTrace
+)
+
+// RoundTrip records the timing and response headers of each request
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req, span := telemetry.startRequest(req)
+	req, hop := traceProvenance(req)
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	span.response(resp, err)
+	hop(resp, err)
+	if err == nil {
+		resp.Body = activityBody{resp.Body}
+	}
+	trace := requestTrace{
+		Time:     start,
+		Method:   req.Method,
+		URL:      redactURL(req.URL),
+		Range:    req.Header.Get("Range"),
+		Duration: time.Since(start).Round(time.Millisecond).String(),
+	}
+	if err != nil {
+		trace.Error = redact(err.Error())
+	} else {
+		trace.Status = resp.Status
+		trace.Header = redactHeader(resp.Header)
+	}
+
+	traceMu.Lock()
+	if len(traces) == maxTracedRequests {
+		traces = traces[1:]
+	}
+	traces = append(traces, trace)
+	traceMu.Unlock()
+	return resp, err
+}
+
+// recentRequests returns a copy of the request trace
+func recentRequests() []requestTrace {
+	traceMu.Lock()
+	defer traceMu.Unlock()
+	return append([]requestTrace(nil), traces...)
+}
+
+// redactURL drops query strings, which carry signatures on redirected blob URLs
+func redactURL(u *url.URL) string {
+	c := *u
+	c.User = nil
+	if c.RawQuery != "" {
+		c.RawQuery = "REDACTED"
+	}
+	return c.String()
+}
+
+// redactHeader copies a response header without cookies
+func redactHeader(h http.Header) map[string][]string {
+	out := make(map[string][]string, len(h))
+	for k, v := range h {
+		if k == "Set-Cookie" {
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// redact removes the registry and Hugging Face tokens and the home directory from text bound for a bundle
+func redact(s string) string {
+	if registryToken != "" {
+		s = strings.ReplaceAll(s, registryToken, "REDACTED")
+	}
+	if hfToken != "" {
+		s = strings.ReplaceAll(s, hfToken, "REDACTED")
+	}
+	if home, err := os.UserHomeDir(); err == nil && len(home) > 1 {
+		s = strings.ReplaceAll(s, home, "~")
+	}
+	return s
+}
+
+// failureRecord tracks consecutive failures of one download
+type failureRecord struct {
+	Count    int            `json:"count"`
+	FirstAt  time.Time      `json:"first_at"`
+	LastAt   time.Time      `json:"last_at"`
+	Errors   []string       `json:"errors"`
+	Requests []requestTrace `json:"requests,omitempty"`
+}
+
+// failuresPath returns the location of the failure records
+func failuresPath() (string, error) {
+	dir, err := projectDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "failures.json"), nil
+}
+
+// loadFailures reads the failure records, keyed by MODEL:TAG
+func loadFailures() (map[string]*failureRecord, error) {
+	failures := make(map[string]*failureRecord)
+	path, err := failuresPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return failures, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &failures); err != nil {
+		return nil, fmt.Errorf("invalid failure records %s: %w", path, err)
+	}
+	return failures, nil
+}
+
+// failuresMu serializes updates to the failure records from parallel pulls, which
+// would otherwise each save their own copy and drop the others' records
+var failuresMu sync.Mutex
+
+// recordPullOutcome updates the failure record of model:tag, reporting whether it has
+// now failed often enough in a row to offer a diagnostics bundle
+func recordPullOutcome(modelName, tag string, pullErr error) bool {
+	failuresMu.Lock()
+	defer failuresMu.Unlock()
+
+	failures, err := loadFailures()
+	if err != nil {
+		return false
+	}
+	ref := modelName + ":" + tag
+	rec := failures[ref]
+	switch {
+	case pullErr == nil && rec == nil:
+		return false
+	case pullErr == nil:
+		delete(failures, ref)
+	default:
+		if rec == nil {
+			rec = &failureRecord{FirstAt: time.Now()}
+			failures[ref] = rec
+		}
+		rec.Count++
+		rec.LastAt = time.Now()
+		rec.Errors = append(rec.Errors, redact(pullErr.Error()))
+		if len(rec.Errors) > 5 {
+			rec.Errors = rec.Errors[len(rec.Errors)-5:]
+		}
+		rec.Requests = recentRequests()
+	}
+	if path, err := failuresPath(); err == nil {
+		writeJSONFile(path, failures)
+	}
+	return pullErr != nil && rec.Count >= diagnosticsAfter
+}
+
+// offerDiagnostics asks whether to write a diagnostics bundle after repeated failures;
+// without a terminal it only names the command that writes one
+func offerDiagnostics(what string) {
+	fmt.Fprintln(os.Stderr, color.YellowString("[WARN] %s has failed %d or more times in a row", what, diagnosticsAfter))
+	if !interactive() {
+		fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Run \"ggufDownloader diagnose\" to write a diagnostics bundle to attach to a bug report"))
+		return
+	}
+	if !confirm("Write a diagnostics bundle (redacted; no downloads or tokens) to attach to a bug report?") {
+		return
+	}
+	path, err := writeDiagnostics("", true)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, color.RedString("[ERROR] Could not write the diagnostics bundle: %s", err))
+		return
+	}
+	fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] Diagnostics written to %s", path))
+}
+
+// transportSettings are the connection settings in effect, for the diagnostics report
+var transportSettings TransportOptions
+
+// writeDiagnostics writes a zip of the environment, the failure records and, when
+// probe is set, a connectivity check of the registry and ollama.com
+func writeDiagnostics(path string, probe bool) (string, error) {
+	if path == "" {
+		path = "ggufDownloader-diagnostics-" + time.Now().Format("20060102-150405") + ".zip"
+	}
+	failures, err := loadFailures()
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	zw := zip.NewWriter(f)
+	now := time.Now()
+	add := func(name string, write func(io.Writer) error) error {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		return write(w)
+	}
+
+	err = add("report.txt", func(w io.Writer) error {
+		writeReport(w, failures)
+		return nil
+	})
+	if err == nil {
+		err = add("failures.json", func(w io.Writer) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(failures)
+		})
+	}
+	if err == nil && probe {
+		err = add("connectivity.txt", func(w io.Writer) error {
+			probeConnectivity(w)
+			return nil
+		})
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+// writeReport describes the tool, the platform and the settings that shape its connections
+func writeReport(w io.Writer, failures map[string]*failureRecord) {
+	fmt.Fprintf(w, "Tool:          %s\n", UserAgent)
+	fmt.Fprintf(w, "Go:            %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(w, "Written:       %s\n", time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintf(w, "Registry:      %s://%s (token: %t)\n", registryScheme, RegistryHost, registryToken != "")
+	fmt.Fprintf(w, "Min TLS:       %s\n", valueOr(transportSettings.MinTLS, "1.2"))
+	fmt.Fprintf(w, "Pins:          %d\n", len(transportSettings.Pins))
+	fmt.Fprintf(w, "IP family:     %s\n", valueOr(transportSettings.Family, "any"))
+	fmt.Fprintf(w, "SSH tunnel:    %t\n", transportSettings.SSHTunnel != "")
+	fmt.Fprintf(w, "Privacy mode:  %t\n", transportSettings.Privacy)
+	fmt.Fprintf(w, "Replaying:     %t\n", transportSettings.Replay != "")
+	fmt.Fprintf(w, "Project:       %s\n", valueOr(activeProject, "(default)"))
+	fmt.Fprintf(w, "Low memory:    %t\n", lowMemory)
+	for _, env := range []string{"HTTPS_PROXY", "HTTP_PROXY", "NO_PROXY"} {
+		v := os.Getenv(env)
+		if v == "" {
+			v = os.Getenv(strings.ToLower(env))
+		}
+		// Proxy URLs may embed credentials, so only their host is reported
+		if u, err := url.Parse(v); err == nil && u.Host != "" {
+			v = u.Scheme + "://" + u.Host
+		}
+		fmt.Fprintf(w, "%-15s%s\n", env+":", valueOr(v, "(unset)"))
+	}
+
+	refs := make([]string, 0, len(failures))
+	for ref := range failures {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	fmt.Fprintf(w, "\nFailing downloads: %d\n", len(refs))
+	for _, ref := range refs {
+		rec := failures[ref]
+		fmt.Fprintf(w, "\n%s: %d failures between %s and %s\n", ref, rec.Count,
+			rec.FirstAt.UTC().Format(time.RFC3339), rec.LastAt.UTC().Format(time.RFC3339))
+		for _, e := range rec.Errors {
+			fmt.Fprintf(w, "  %s\n", e)
+		}
+	}
+}
+
+// valueOr returns s, or def when s is empty
+func valueOr(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// probeConnectivity resolves, connects to and handshakes with the hosts the tool talks
+// to, timing each step so slow or failing hops stand out
+func probeConnectivity(w io.Writer) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	port := "443"
+	if registryScheme == "http" {
+		port = "80"
+	}
+	host, p, err := net.SplitHostPort(RegistryHost)
+	if err != nil {
+		host, p = RegistryHost, port
+	}
+	probeHost(ctx, w, host, p, registryScheme == "https")
+	if !privacyMode {
+		probeHost(ctx, w, "ollama.com", "443", true)
+	}
+
+	fmt.Fprintf(w, "\nHTTP %s\n", registryURL(""))
+	start := time.Now()
+	resp, err := httpGet(ctx, registryURL(""))
+	if err != nil {
+		fmt.Fprintf(w, "  failed after %s: %s\n", time.Since(start).Round(time.Millisecond), redact(err.Error()))
+		return
+	}
+	resp.Body.Close()
+	fmt.Fprintf(w, "  %s in %s\n", resp.Status, time.Since(start).Round(time.Millisecond))
+}
+
+// probeHost reports DNS, TCP and TLS timings for one host
+func probeHost(ctx context.Context, w io.Writer, host, port string, useTLS bool) {
+	fmt.Fprintf(w, "%s:%s\n", host, port)
+	start := time.Now()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		fmt.Fprintf(w, "  DNS failed after %s: %s\n\n", time.Since(start).Round(time.Millisecond), err)
+		return
+	}
+	fmt.Fprintf(w, "  DNS %d addresses in %s\n", len(addrs), time.Since(start).Round(time.Millisecond))
+
+	var dialer net.Dialer
+	dialer.Timeout = 10 * time.Second
+	reached := ""
+	for i, a := range addrs {
+		if i == 4 {
+			fmt.Fprintf(w, "  ... %d more addresses not tried\n", len(addrs)-i)
+			break
+		}
+		addr := net.JoinHostPort(a.IP.String(), port)
+		start := time.Now()
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			fmt.Fprintf(w, "  TCP %s failed after %s: %s\n", addr, time.Since(start).Round(time.Millisecond), err)
+			continue
+		}
+		conn.Close()
+		fmt.Fprintf(w, "  TCP %s connected in %s\n", addr, time.Since(start).Round(time.Millisecond))
+		if reached == "" {
+			reached = addr
+		}
+	}
+
+	if useTLS && reached != "" {
+		start := time.Now()
+		tlsDialer := &tls.Dialer{NetDialer: &dialer, Config: &tls.Config{ServerName: host}}
+		conn, err := tlsDialer.DialContext(ctx, "tcp", reached)
+		if err != nil {
+			fmt.Fprintf(w, "  TLS failed after %s: %s\n", time.Since(start).Round(time.Millisecond), err)
+		} else {
+			state := conn.(*tls.Conn).ConnectionState()
+			fmt.Fprintf(w, "  TLS %s in %s\n", tls.VersionName(state.Version), time.Since(start).Round(time.Millisecond))
+			if len(state.PeerCertificates) > 0 {
+				cert := state.PeerCertificates[0]
+				fmt.Fprintf(w, "  Certificate %s issued by %s, expires %s\n", cert.Subject.CommonName,
+					cert.Issuer.CommonName, cert.NotAfter.Format("2006-01-02"))
+			}
+			conn.Close()
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+var diagnoseCommand = &Command{
+	Name:    "diagnose",
+	Usage:   "",
+	Summary: "Write a diagnostics bundle to attach to a bug report",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		out := fs.String("out", "", "Bundle file (default ggufDownloader-diagnostics-TIMESTAMP.zip)")
+		offline := fs.Bool("offline", false, "Skip the connectivity checks")
+		return func(args []string) error {
+			if len(args) != 0 {
+				return errors.New("usage: diagnose [-out FILE] [-offline]")
+			}
+			path, err := writeDiagnostics(*out, !*offline)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] Diagnostics written to %s; check it before attaching it to a bug report", path))
+			return nil
+		}
+	},
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// DialError wraps a connection failure with the address family that was attempted
+type DialError struct {
+	Addr   string
+	Family string
+	Forced bool
+	Err    error
+}
+
+func (e *DialError) Error() string {
+	msg := fmt.Sprintf("connecting to %s over %s failed: %v", e.Addr, e.Family, e.Err)
+	switch {
+	case e.Forced:
+		return msg
+	case e.Family == "IPv6":
+		return msg + " (try -4 to force IPv4)"
+	case e.Family == "IPv4":
+		return msg + " (try -6 to force IPv6)"
+	}
+	return msg
+}
+
+func (e *DialError) Unwrap() error {
+	return e.Err
+}
+
+// familyDialer dials with happy eyeballs, or a single address family when one is forced
+type familyDialer struct {
+	dialer net.Dialer
+	// network is "tcp4" or "tcp6" when forced, empty for dual-stack
+	network string
+}
+
+// newFamilyDialer returns a dialer for family "4", "6" or "" (both, with happy eyeballs)
+func newFamilyDialer(family string) (*familyDialer, error) {
+	d := &familyDialer{dialer: net.Dialer{
+		Timeout:   connectTimeout,
+		KeepAlive: 30 * time.Second,
+		// Start an IPv4 attempt if IPv6 has not connected within this delay (RFC 6555)
+		FallbackDelay: 300 * time.Millisecond,
+	}}
+
+	switch family {
+	case "":
+	case "4":
+		d.network = "tcp4"
+	case "6":
+		d.network = "tcp6"
+	default:
+		return nil, fmt.Errorf("unsupported address family %q (use 4 or 6)", family)
+	}
+	return d, nil
+}
+
+// DialContext connects to addr, annotating failures with the address family tried
+func (d *familyDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if d.network != "" {
+		network = d.network
+	} else if preferIPv4.Load() {
+		// A transfer stalled over dual-stack, so stay on IPv4 for the rest of the run
+		network = "tcp4"
+	}
+
+	conn, err := d.dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, &DialError{Addr: addr, Family: attemptedFamily(network, err), Forced: d.network != "", Err: err}
+	}
+	return conn, nil
+}
+
+// attemptedFamily names the address family of a failed dial as precisely as the error allows
+func attemptedFamily(network string, err error) string {
+	switch network {
+	case "tcp4":
+		return "IPv4"
+	case "tcp6":
+		return "IPv6"
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		if tcpAddr, ok := opErr.Addr.(*net.TCPAddr); ok {
+			if tcpAddr.IP.To4() != nil {
+				return "IPv4"
+			}
+			return "IPv6"
+		}
+	}
+	return "IPv4/IPv6"
+}
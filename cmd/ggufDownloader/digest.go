@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"ggufDownloader/pkg/ollamareg"
+)
+
+// defaultDigestAlgorithm hashes local files when no expected digest names another one
+const defaultDigestAlgorithm = "sha256"
+
+// isDigest reports whether s is a well-formed digest rather than, say, a tag
+func isDigest(s string) bool {
+	_, _, err := ollamareg.ParseDigest(s)
+	return err == nil
+}
+
+// digestAlgorithm returns the algorithm of a digest, or the default for an empty or
+// malformed one, so files can be hashed the way their expected digest was computed
+func digestAlgorithm(digest string) string {
+	if algo, _, err := ollamareg.ParseDigest(digest); err == nil {
+		return algo
+	}
+	return defaultDigestAlgorithm
+}
+
+// fileDigest returns the "sha256:<hex>" digest of a file's contents, reusing the
+// checksum cache when the file's size, modification time and inode are unchanged
+func fileDigest(path string) (string, error) {
+	return fileDigestAs(path, defaultDigestAlgorithm)
+}
+
+// fileDigestAs is fileDigest with another algorithm ollamareg.NewHash supports
+func fileDigestAs(path, algo string) (string, error) {
+	return digestFile(path, algo, "")
+}
+
+// digestFile hashes a file through the checksum cache; a non-empty label shows a
+// progress bar while a large file is read
+func digestFile(path, algo, label string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", err
+	}
+	if !rehash {
+		if digest, ok := cachedDigest(abs, info); ok && strings.HasPrefix(digest, algo+":") {
+			return digest, nil
+		}
+	}
+
+	var progress progressWriter
+	if label != "" && info.Size() >= hashProgressThreshold {
+		progress = newProgress(info.Size(), label)
+	}
+	digest, err := hashFile(abs, algo, progress)
+	if err != nil {
+		return "", err
+	}
+	// The file may have been modified while it was read; only cache a stable result
+	if after, err := os.Stat(abs); err == nil && hashCacheKey(after) == hashCacheKey(info) {
+		storeDigest(abs, info, digest)
+	}
+	return digest, nil
+}
+
+// hashProgressThreshold is the size from which hashing a file shows a progress bar
+const hashProgressThreshold = 256 << 20
+
+// hashFile reads a file and returns the "algo:<hex>" digest of its contents, reporting
+// the bytes read to progress unless it is nil
+func hashFile(path, algo string, progress progressWriter) (string, error) {
+	h, err := ollamareg.NewHash(algo)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var w io.Writer = h
+	if progress != nil {
+		w = io.MultiWriter(h, progress)
+		defer progress.Finish()
+	}
+	if _, err := io.Copy(w, throttleHashing(f)); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%x", algo, h.Sum(nil)), nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/fatih/color"
+
+	"ggufDownloader/pkg/ollamareg"
+)
+
+// directIO makes downloads bypass the page cache, set with -direct-io
+var directIO bool
+
+// openOutput opens filename for a download, truncating it or continuing at offset when
+// it is positive. With -direct-io the writes bypass the page cache where the platform and
+// filesystem allow, and fall back to ordinary writes with a warning otherwise.
+func openOutput(filename string, offset int64) (io.WriteCloser, error) {
+	if directIO {
+		w, err := openDirect(filename, offset)
+		if err == nil {
+			return w, nil
+		}
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Writing %s through the page cache: %s", filename, err))
+	}
+	return ollamareg.OpenOutput(filename, offset)
+}
@@ -0,0 +1,27 @@
+//go:build darwin
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// openDirect opens filename with F_NOCACHE, macOS's equivalent of O_DIRECT, which
+// needs no aligned writes
+func openDirect(filename string, offset int64) (*os.File, error) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if offset > 0 {
+		flags = os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(filename, flags, 0o666)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := unix.FcntlInt(f.Fd(), unix.F_NOCACHE, 1); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
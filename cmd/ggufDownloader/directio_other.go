@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !windows
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// openDirect is not implemented on this platform
+func openDirect(filename string, offset int64) (*os.File, error) {
+	return nil, errors.New("direct I/O is not supported on this platform")
+}
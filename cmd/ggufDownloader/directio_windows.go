@@ -0,0 +1,36 @@
+//go:build windows
+
+package main
+
+import (
+	"io"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// openDirect opens filename with FILE_FLAG_WRITE_THROUGH, so writes go to the disk
+// instead of piling up in the cache manager
+func openDirect(filename string, offset int64) (*os.File, error) {
+	name, err := windows.UTF16PtrFromString(filename)
+	if err != nil {
+		return nil, err
+	}
+	disposition := uint32(windows.CREATE_ALWAYS)
+	if offset > 0 {
+		disposition = windows.OPEN_EXISTING
+	}
+	h, err := windows.CreateFile(name, windows.GENERIC_WRITE, windows.FILE_SHARE_READ, nil,
+		disposition, windows.FILE_ATTRIBUTE_NORMAL|windows.FILE_FLAG_WRITE_THROUGH, 0)
+	if err != nil {
+		return nil, err
+	}
+	f := os.NewFile(uintptr(h), filename)
+	if offset > 0 {
+		if _, err := f.Seek(0, io.SeekEnd); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
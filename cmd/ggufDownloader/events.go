@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// eventBacklog is how many log lines of the running job a new subscriber is sent first
+const eventBacklog = 200
+
+// progressEventInterval is how often a transfer's progress is published
+const progressEventInterval = 500 * time.Millisecond
+
+// jobEvent is one server-sent event about a job
+type jobEvent struct {
+	Kind string // "log", "progress" or "state"
+	Data any
+}
+
+// logEvent is a line the daemon printed while working on a job
+type logEvent struct {
+	Job  string    `json:"job"`
+	Time time.Time `json:"time"`
+	Line string    `json:"line"`
+}
+
+// progressEvent reports how far a transfer of a job has come; Total is -1 when unknown
+type progressEvent struct {
+	Job         string `json:"job"`
+	Description string `json:"description"`
+	Current     int64  `json:"current"`
+	Total       int64  `json:"total"`
+}
+
+// eventHub fans the running job's output out to the clients following it
+type eventHub struct {
+	mu      sync.Mutex
+	job     string
+	backlog []jobEvent
+	subs    map[chan jobEvent]string
+	// progress is the running job's latest transfer progress, published at progressAt
+	progress   progressEvent
+	progressAt time.Time
+	// received bytes over active transfer time give the daemon's measured rate
+	received int64
+	active   time.Duration
+}
+
+// events is the daemon's hub; nil outside daemon mode, which keeps progress unobserved
+var events *eventHub
+
+func newEventHub() *eventHub {
+	return &eventHub{subs: make(map[chan jobEvent]string)}
+}
+
+// start attributes everything published from now on to job
+func (h *eventHub) start(job string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.job, h.backlog = job, nil
+	h.progress, h.progressAt = progressEvent{}, time.Time{}
+}
+
+// end stops attributing output to job
+func (h *eventHub) end(job string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.job == job {
+		h.job, h.backlog = "", nil
+	}
+}
+
+// subscribe returns a channel of job's events and the log lines it already printed
+func (h *eventHub) subscribe(job string) (chan jobEvent, []jobEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ch := make(chan jobEvent, 256)
+	h.subs[ch] = job
+	var backlog []jobEvent
+	if h.job == job {
+		backlog = append(backlog, h.backlog...)
+	}
+	return ch, backlog
+}
+
+func (h *eventHub) unsubscribe(ch chan jobEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subs, ch)
+}
+
+// publish sends an event to the followers of job; a follower too slow to keep up
+// misses events rather than holding up the download
+func (h *eventHub) publish(job string, ev jobEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.deliver(job, ev)
+}
+
+// publishCurrent publishes ev for the running job, if there is one
+func (h *eventHub) publishCurrent(build func(job string) jobEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.job == "" {
+		return
+	}
+	ev := build(h.job)
+	if p, ok := ev.Data.(progressEvent); ok {
+		h.measure(p)
+	}
+	if ev.Kind == "log" {
+		h.backlog = append(h.backlog, ev)
+		if len(h.backlog) > eventBacklog {
+			h.backlog = h.backlog[len(h.backlog)-eventBacklog:]
+		}
+	}
+	h.deliver(h.job, ev)
+}
+
+// measure adds the bytes a transfer received since its last progress event to the
+// measured rate. Gaps longer than a few intervals are a stalled or new transfer and are
+// not counted, so waiting does not drag the rate down. Callers must hold h.mu.
+func (h *eventHub) measure(p progressEvent) {
+	now := time.Now()
+	last := h.progress
+	if !h.progressAt.IsZero() && p.Description == last.Description && p.Total == last.Total && p.Current >= last.Current {
+		if gap := now.Sub(h.progressAt); gap <= 4*progressEventInterval {
+			h.received += p.Current - last.Current
+			h.active += gap
+		}
+	}
+	h.progress, h.progressAt = p, now
+}
+
+// runningProgress returns the latest progress of job, if it is the running job and has
+// reported any
+func (h *eventHub) runningProgress(job string) (progressEvent, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.progress, h.job == job && !h.progressAt.IsZero()
+}
+
+// measuredRate returns the average rate of the daemon's transfers in bytes per second,
+// or 0 before it has measured one for a few seconds
+func (h *eventHub) measuredRate() float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.active < 5*time.Second {
+		return 0
+	}
+	return float64(h.received) / h.active.Seconds()
+}
+
+// deliver hands ev to the subscribers of job; callers must hold h.mu
+func (h *eventHub) deliver(job string, ev jobEvent) {
+	for ch, sub := range h.subs {
+		if sub != job {
+			continue
+		}
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// ansiEscape matches the color codes in console output
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// captureLogs tees everything written to os.Stderr, including by child processes, into
+// log events of the running job. Progress bars redraw with carriage returns; those
+// redraws are left out, since progress events carry the same information.
+func (h *eventHub) captureLogs() error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	console := os.Stderr
+	os.Stderr = w
+	go func() {
+		reader := bufio.NewReader(io.TeeReader(r, console))
+		var line []byte
+		for {
+			b, err := reader.ReadByte()
+			if err != nil {
+				return
+			}
+			switch b {
+			case '\r':
+				line = line[:0]
+			case '\n':
+				if text := ansiEscape.ReplaceAllString(string(line), ""); text != "" {
+					h.publishCurrent(func(job string) jobEvent {
+						return jobEvent{"log", logEvent{Job: job, Time: time.Now().UTC(), Line: text}}
+					})
+				}
+				line = line[:0]
+			default:
+				line = append(line, b)
+			}
+		}
+	}()
+	return nil
+}
+
+// observedProgress publishes a renderer's progress as events of the running job
+type observedProgress struct {
+	progressWriter
+	// mu guards the counters; downloads split over several connections write concurrently
+	mu          sync.Mutex
+	description string
+	current     int64
+	total       int64
+	published   time.Time
+}
+
+// observeProgress wraps a progress renderer for the event hub in daemon mode
+func observeProgress(p progressWriter, total, offset int64, description string) progressWriter {
+	if events == nil {
+		return p
+	}
+	return &observedProgress{progressWriter: p, description: description, current: offset, total: total}
+}
+
+func (o *observedProgress) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	o.current += int64(len(p))
+	if time.Since(o.published) >= progressEventInterval {
+		o.publish()
+	}
+	o.mu.Unlock()
+	return o.progressWriter.Write(p)
+}
+
+// Describe passes a new label on to the renderer
+func (o *observedProgress) Describe(description string) {
+	o.mu.Lock()
+	o.description = description
+	o.mu.Unlock()
+	if d, ok := o.progressWriter.(interface{ Describe(string) }); ok {
+		d.Describe(description)
+	}
+}
+
+// Finish publishes the final count
+func (o *observedProgress) Finish() error {
+	o.mu.Lock()
+	o.publish()
+	o.mu.Unlock()
+	return o.progressWriter.Finish()
+}
+
+// publish sends the current count; callers must hold o.mu
+func (o *observedProgress) publish() {
+	o.published = time.Now()
+	events.publishCurrent(func(job string) jobEvent {
+		return jobEvent{"progress", progressEvent{Job: job, Description: o.description, Current: o.current, Total: o.total}}
+	})
+}
+
+// handleEvents streams a job's state changes, log lines and progress as server-sent
+// events until the job is done or has failed
+func (m *jobManager) handleEvents(w http.ResponseWriter, r *http.Request, id string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming is not supported"))
+		return
+	}
+	ch, backlog := events.subscribe(id)
+	defer events.unsubscribe(ch)
+	job, found := m.get(id)
+	if !found {
+		writeError(w, http.StatusNotFound, errJobNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Reverse proxies must pass events on as they come
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(ev jobEvent) {
+		data, _ := json.Marshal(ev.Data)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Kind, data)
+	}
+	send(jobEvent{"state", job})
+	for _, ev := range backlog {
+		send(ev)
+	}
+	flusher.Flush()
+	if finished(job.State) {
+		return
+	}
+
+	keepalive := time.NewTicker(15 * time.Second)
+	defer keepalive.Stop()
+	for {
+		select {
+		case ev := <-ch:
+			send(ev)
+			flusher.Flush()
+			if state, ok := ev.Data.(Job); ok && finished(state.State) {
+				return
+			}
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// finished reports whether a job will not change state on its own any more
+func finished(state JobState) bool {
+	return state == JobDone || state == JobFailed
+}
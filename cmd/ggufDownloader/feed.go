@@ -0,0 +1,378 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// Kinds of feed entries
+const (
+	feedAdded   = "new"
+	feedUpdated = "updated"
+)
+
+// maxFeedEntries is how many entries the feed keeps; older ones drop off the end
+const maxFeedEntries = 200
+
+// defaultFeedInterval is how often the daemon snapshots the catalog for its feed
+const defaultFeedInterval = 6 * time.Hour
+
+// feedModel is what a catalog snapshot remembers about a model to detect changes
+type feedModel struct {
+	Parameters []string `json:"parameters"`
+	TagCount   string   `json:"tag_count"`
+}
+
+// feedEntry is one new or updated model in the feed
+type feedEntry struct {
+	Kind    string    `json:"kind"`
+	Model   string    `json:"model"`
+	Summary string    `json:"summary"`
+	At      time.Time `json:"at"`
+}
+
+// feedState is the last catalog snapshot and the entries derived from all snapshots so far
+type feedState struct {
+	CheckedAt time.Time            `json:"checked_at"`
+	Models    map[string]feedModel `json:"models"`
+	Entries   []feedEntry          `json:"entries"`
+}
+
+// feedMu serializes snapshots taken by the daemon with feed requests reading the state
+var feedMu sync.Mutex
+
+// feedPath returns the location of the feed state of the active project
+func feedPath() (string, error) {
+	dir, err := projectDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "feed.json"), nil
+}
+
+// loadFeed reads the feed state, returning an empty state if no snapshot was taken yet
+func loadFeed() (*feedState, error) {
+	path, err := feedPath()
+	if err != nil {
+		return nil, err
+	}
+	state := &feedState{}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(data, state); err != nil {
+			return nil, fmt.Errorf("invalid feed file %s: %w", path, err)
+		}
+	}
+	if state.Models == nil {
+		state.Models = make(map[string]feedModel)
+	}
+	return state, nil
+}
+
+// refreshFeed snapshots the catalog and records models added or updated since the last
+// snapshot; the first snapshot only records a baseline
+func refreshFeed() (*feedState, int, error) {
+	feedMu.Lock()
+	defer feedMu.Unlock()
+
+	state, err := loadFeed()
+	if err != nil {
+		return nil, 0, err
+	}
+	now := time.Now().UTC()
+	baseline := len(state.Models) == 0
+	current := make(map[string]feedModel)
+	var added []feedEntry
+	err = scanSearchResults("newest", "", func(m ModelInfo) error {
+		model := feedModel{Parameters: m.Parameters, TagCount: m.TagCount}
+		current[m.Name] = model
+		if baseline {
+			return nil
+		}
+		prev, ok := state.Models[m.Name]
+		switch {
+		case !ok:
+			summary := m.Description
+			if len(m.Parameters) > 0 {
+				summary += " Sizes: " + strings.Join(m.Parameters, ", ") + "."
+			}
+			added = append(added, feedEntry{Kind: feedAdded, Model: m.Name, Summary: strings.TrimSpace(summary), At: now})
+		default:
+			if change := describeChange(prev, model); change != "" {
+				added = append(added, feedEntry{Kind: feedUpdated, Model: m.Name, Summary: change, At: now})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(current) == 0 {
+		return nil, 0, errors.New("the model list was empty")
+	}
+
+	// Models missing from one listing are kept, so they are not reported as new when they return
+	for name, m := range current {
+		state.Models[name] = m
+	}
+	state.CheckedAt = now
+	state.Entries = append(added, state.Entries...)
+	if len(state.Entries) > maxFeedEntries {
+		state.Entries = state.Entries[:maxFeedEntries]
+	}
+	path, err := feedPath()
+	if err != nil {
+		return nil, 0, err
+	}
+	return state, len(added), writeJSONFile(path, state)
+}
+
+// describeChange summarizes how a model changed between two snapshots, or returns ""
+func describeChange(prev, cur feedModel) string {
+	var changes []string
+	var sizes []string
+	for _, p := range cur.Parameters {
+		if !slices.Contains(prev.Parameters, p) {
+			sizes = append(sizes, p)
+		}
+	}
+	if len(sizes) > 0 {
+		changes = append(changes, "new sizes: "+strings.Join(sizes, ", "))
+	}
+	before, err1 := strconv.Atoi(prev.TagCount)
+	after, err2 := strconv.Atoi(cur.TagCount)
+	if err1 == nil && err2 == nil && after > before {
+		changes = append(changes, fmt.Sprintf("%d new tag(s), %d in total", after-before, after))
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+	return strings.Join(changes, "; ")
+}
+
+// feedLink returns the page of a model on ollama.com; community models live under their
+// user's name rather than the library
+func feedLink(model string) string {
+	if strings.Contains(model, "/") {
+		return "https://ollama.com/" + model
+	}
+	return "https://ollama.com/library/" + model
+}
+
+// title is the headline of an entry
+func (e feedEntry) title() string {
+	if e.Kind == feedUpdated {
+		return e.Model + " updated"
+	}
+	return "New model: " + e.Model
+}
+
+// id is a stable identifier for an entry, so feed readers do not repeat it
+func (e feedEntry) id() string {
+	return fmt.Sprintf("tag:ggufDownloader,%s:%s/%s/%d", e.At.Format("2006-01-02"), e.Kind, e.Model, e.At.Unix())
+}
+
+// Atom and RSS 2.0 documents, with only the elements feed readers need
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title   string   `xml:"title"`
+	ID      string   `xml:"id"`
+	Link    atomLink `xml:"link"`
+	Updated string   `xml:"updated"`
+	Summary string   `xml:"summary,omitempty"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Link    atomLink    `xml:"link"`
+	Updated string      `xml:"updated"`
+	Author  string      `xml:"author>name"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type rssGUID struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Description string  `xml:"description,omitempty"`
+}
+
+type rssFeed struct {
+	XMLName     xml.Name  `xml:"rss"`
+	Version     string    `xml:"version,attr"`
+	Title       string    `xml:"channel>title"`
+	Link        string    `xml:"channel>link"`
+	Description string    `xml:"channel>description"`
+	PubDate     string    `xml:"channel>pubDate"`
+	Items       []rssItem `xml:"channel>item"`
+}
+
+// writeFeed renders up to limit entries as an Atom ("atom") or RSS 2.0 ("rss") feed
+func writeFeed(w io.Writer, state *feedState, format string, limit int) error {
+	entries := state.Entries
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	updated := state.CheckedAt
+	if updated.IsZero() {
+		updated = time.Now().UTC()
+	}
+
+	const title = "Ollama library: new and updated models"
+	var doc interface{}
+	switch format {
+	case "atom":
+		feed := atomFeed{
+			Title: title, ID: "tag:ggufDownloader,2024:catalog", Link: atomLink{Href: "https://ollama.com/library"},
+			Updated: updated.Format(time.RFC3339), Author: "ggufDownloader",
+		}
+		for _, e := range entries {
+			feed.Entries = append(feed.Entries, atomEntry{
+				Title: e.title(), ID: e.id(), Link: atomLink{Href: feedLink(e.Model), Rel: "alternate"},
+				Updated: e.At.Format(time.RFC3339), Summary: e.Summary,
+			})
+		}
+		doc = feed
+	case "rss":
+		feed := rssFeed{
+			Version: "2.0", Title: title, Link: "https://ollama.com/library",
+			Description: "Models added to or updated in the Ollama library, from ggufDownloader catalog snapshots",
+			PubDate:     updated.Format(time.RFC1123Z),
+		}
+		for _, e := range entries {
+			feed.Items = append(feed.Items, rssItem{
+				Title: e.title(), Link: feedLink(e.Model), GUID: rssGUID{IsPermaLink: "false", Value: e.id()},
+				PubDate: e.At.Format(time.RFC1123Z), Description: e.Summary,
+			})
+		}
+		doc = feed
+	default:
+		return fmt.Errorf("unknown feed format %q (use atom or rss)", format)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// handleFeed serves the stored feed, as RSS for /feed.rss and as Atom otherwise
+func handleFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	feedMu.Lock()
+	state, err := loadFeed()
+	feedMu.Unlock()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	format, contentType := "atom", "application/atom+xml; charset=utf-8"
+	if strings.HasSuffix(r.URL.Path, ".rss") || r.URL.Query().Get("format") == "rss" {
+		format, contentType = "rss", "application/rss+xml; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	writeFeed(w, state, format, 50)
+}
+
+// watchFeed snapshots the catalog every interval for the daemon's feed
+func watchFeed(interval time.Duration) {
+	for {
+		if _, n, err := refreshFeed(); err != nil {
+			fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Feed snapshot failed: %s", err))
+		} else if n > 0 {
+			fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Feed: %d new or updated model(s)", n))
+		}
+		time.Sleep(interval)
+	}
+}
+
+var feedCommand = &Command{
+	Name:    "feed",
+	Summary: "Snapshot the catalog and write an Atom/RSS feed of new and updated models",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		format := fs.String("format", "atom", "Feed format: atom or rss")
+		out := fs.String("out", "", "Write the feed to this file instead of stdout")
+		limit := fs.Int("n", 50, "Number of entries in the feed")
+		noRefresh := fs.Bool("no-refresh", false, "Render the stored entries without taking a new snapshot")
+		return func(args []string) error {
+			if len(args) != 0 {
+				return errors.New("usage: feed [-format atom|rss] [-out FILE]")
+			}
+			if *format != "atom" && *format != "rss" {
+				return fmt.Errorf("unknown feed format %q (use atom or rss)", *format)
+			}
+
+			var state *feedState
+			var err error
+			if *noRefresh {
+				state, err = loadFeed()
+			} else {
+				var n int
+				state, n, err = refreshFeed()
+				if err == nil {
+					fmt.Fprintln(os.Stderr, color.CyanString("[INFO] %d new or updated model(s) since the last snapshot", n))
+				}
+			}
+			if err != nil {
+				return err
+			}
+
+			if *out == "" {
+				return writeFeed(os.Stdout, state, *format, *limit)
+			}
+			f, err := os.Create(*out + ".tmp")
+			if err != nil {
+				return err
+			}
+			if err := writeFeed(f, state, *format, *limit); err != nil {
+				f.Close()
+				os.Remove(f.Name())
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+			return os.Rename(f.Name(), *out)
+		}
+	},
+}
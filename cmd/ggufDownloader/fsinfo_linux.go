@@ -0,0 +1,46 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// fileAccessTime returns when the file was last read, falling back to its modification time
+func fileAccessTime(info os.FileInfo) time.Time {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return info.ModTime()
+	}
+	return time.Unix(st.Atim.Sec, st.Atim.Nsec)
+}
+
+// diskFree returns the bytes available to unprivileged users on the filesystem holding path
+func diskFree(path string) (int64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return int64(st.Bavail) * int64(st.Bsize), nil
+}
+
+// fileInode returns the inode number of the file, or 0 if it is not available
+func fileInode(info os.FileInfo) uint64 {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0
+	}
+	return uint64(st.Ino)
+}
+
+// fileDiskUsage returns the bytes the file actually occupies, which is less than its
+// size for a sparse file such as a download split over several connections
+func fileDiskUsage(path string, info os.FileInfo) int64 {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return info.Size()
+	}
+	return st.Blocks * 512
+}
@@ -0,0 +1,29 @@
+//go:build !linux && !darwin && !windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// fileAccessTime falls back to the modification time where access times are not exposed
+func fileAccessTime(info os.FileInfo) time.Time {
+	return info.ModTime()
+}
+
+// diskFree is not implemented on this platform
+func diskFree(path string) (int64, error) {
+	return 0, errors.New("free space reporting is not supported on this platform")
+}
+
+// fileInode is not exposed by os.FileInfo on this platform, so size and modification time identify a file alone
+func fileInode(info os.FileInfo) uint64 {
+	return 0
+}
+
+// fileDiskUsage is not exposed on this platform, so a file is taken to occupy its size
+func fileDiskUsage(path string, info os.FileInfo) int64 {
+	return info.Size()
+}
@@ -0,0 +1,64 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// fileAccessTime returns when the file was last read, falling back to its modification time
+func fileAccessTime(info os.FileInfo) time.Time {
+	attrs, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return info.ModTime()
+	}
+	return time.Unix(0, attrs.LastAccessTime.Nanoseconds())
+}
+
+// diskFree returns the bytes available to the current user on the volume holding path
+func diskFree(path string) (int64, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var available uint64
+	if err := windows.GetDiskFreeSpaceEx(p, &available, nil, nil); err != nil {
+		return 0, err
+	}
+	return int64(available), nil
+}
+
+// fileInode is not exposed by os.FileInfo on this platform, so size and modification time identify a file alone
+func fileInode(info os.FileInfo) uint64 {
+	return 0
+}
+
+// fileStandardInfo is FILE_STANDARD_INFO
+type fileStandardInfo struct {
+	AllocationSize int64
+	EndOfFile      int64
+	NumberOfLinks  uint32
+	DeletePending  bool
+	Directory      bool
+}
+
+// fileDiskUsage returns the bytes allocated to the file, which is less than its size
+// for a sparse file such as a download split over several connections
+func fileDiskUsage(path string, info os.FileInfo) int64 {
+	f, err := os.Open(path)
+	if err != nil {
+		return info.Size()
+	}
+	defer f.Close()
+	var st fileStandardInfo
+	if err := windows.GetFileInformationByHandleEx(windows.Handle(f.Fd()), windows.FileStandardInfo,
+		(*byte)(unsafe.Pointer(&st)), uint32(unsafe.Sizeof(st))); err != nil {
+		return info.Size()
+	}
+	return st.AllocationSize
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+
+	"ggufDownloader/pkg/ollamareg"
+)
+
+// fullModelFile is what the weights are called inside the directory of a -full pull
+const fullModelFile = "model.gguf"
+
+// fullLayerName returns the file a layer is saved as by a -full pull, or "" for the
+// weights and projectors, which the pull places itself. Layers of a type this tool does
+// not know are kept too, named after the last part of their media type.
+func fullLayerName(t MediaType) string {
+	if name, ok := t.MetadataFile(); ok {
+		return name
+	}
+	switch {
+	case t.IsModel(), t.IsProjector():
+		return ""
+	case t.IsAdapter():
+		return "adapter.gguf"
+	}
+	return path.Base(strings.ReplaceAll(string(t), ".", "/")) + ".bin"
+}
+
+// checkBlobBytes verifies a blob held in memory against its digest
+func checkBlobBytes(data []byte, digest string) error {
+	if got := fmt.Sprintf("sha256:%x", sha256.Sum256(data)); got != digest {
+		return fmt.Errorf("digest mismatch: expected %s, got %s", digest, got)
+	}
+	return nil
+}
+
+// pullFullLayers writes the layers of a manifest other than the weights and projectors
+// into dir, with the config as config.json and the manifest as manifest.json, so the
+// model can be put together again without the registry. Small layers are fetched into
+// memory; adapters and unknown large layers are downloaded like the weights. Every layer
+// gets a bar named after its file, with the overall progress alongside.
+func pullFullLayers(ctx context.Context, modelName string, manifest *Manifest, dir string, opts PullOptions) error {
+	type fullLayer struct {
+		name  string
+		layer Layer
+	}
+	var layers []fullLayer
+	if manifest.Config.Digest != "" {
+		layers = append(layers, fullLayer{"config.json", manifest.Config})
+	}
+	used := make(map[string]int)
+	for _, layer := range manifest.Layers {
+		if name := fullLayerName(layer.MediaType); name != "" {
+			layers = append(layers, fullLayer{uniqueName(used, name), layer})
+		}
+	}
+	var total int64
+	for _, l := range layers {
+		total += l.layer.Size
+	}
+
+	progress := newBundleProgress(len(layers), total)
+	for _, l := range layers {
+		layer, dst := l.layer, filepath.Join(dir, l.name)
+		if layer.MediaType.IsAdapter() || layer.Size > maxMetadataLayerSize {
+			check := ollamareg.CheckGGUF
+			if !layer.MediaType.IsAdapter() {
+				check = nil
+			}
+			layerCtx, rendered := withBundleLayer(ctx, progress, l.name)
+			if err := pullLayerFile(layerCtx, modelName, layer, dst, "layer", check, opts, ""); err != nil {
+				return fmt.Errorf("%s: %w", l.name, err)
+			}
+			if rendered.progress == nil {
+				// Served from the blob cache
+				progress.skip(layer.Size)
+			}
+			continue
+		}
+		data, err := fetchBlobBytes(ctx, modelName, layer.Digest, progress.layer(l.name, layer.Size, 0))
+		if err == nil {
+			err = checkBlobBytes(data, layer.Digest)
+		}
+		if err == nil {
+			err = os.WriteFile(dst, data, 0o644)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", l.name, err)
+		}
+	}
+
+	if err := writeJSONFile(filepath.Join(dir, "manifest.json"), manifest); err != nil {
+		return fmt.Errorf("manifest.json: %w", err)
+	}
+	fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] Wrote every layer of %s to %s", modelName, dir))
+	return nil
+}
@@ -0,0 +1,1071 @@
+package main
+
+// Ollama Model Downloader
+// This program downloads models from the Ollama registry.
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+
+	"ggufDownloader/pkg/ollamareg"
+)
+
+// UserAgent is the user agent string used for HTTP requests
+const UserAgent = "GGUF-Downloader/1.0 (github.com/emreugur35/ggufDownloader)"
+
+// ModelInfo represents information about an available model
+type ModelInfo struct {
+	Name         string   `json:"name"`
+	Description  string   `json:"description"`
+	Parameters   []string `json:"parameters"`
+	Capabilities []string `json:"capabilities"`
+	PullCount    string   `json:"pull_count"`
+	TagCount     string   `json:"tag_count"`
+	UpdatedAt    string   `json:"updated_at"`
+	// DownloadSizes maps tags to the bytes they download, where known, as in the built-in snapshot
+	DownloadSizes map[string]int64 `json:"download_sizes,omitempty"`
+}
+
+// sizesLabel lists the model's sizes, each with its download size when known
+func (m ModelInfo) sizesLabel() string {
+	tags := m.Parameters
+	if len(tags) == 0 && m.DownloadSizes["latest"] > 0 {
+		tags = []string{"latest"}
+	}
+	labels := make([]string, len(tags))
+	for i, tag := range tags {
+		labels[i] = tag
+		if n := m.DownloadSizes[tag]; n > 0 {
+			labels[i] += " " + formatDownloadSize(n)
+		}
+	}
+	return strings.Join(labels, ", ")
+}
+
+// formatDownloadSize writes n the way ollama.com lists download sizes, e.g. 4.9GB or 398MB
+func formatDownloadSize(n int64) string {
+	v, unit := float64(n)/1e6, "MB"
+	if n >= 1e9 {
+		v, unit = float64(n)/1e9, "GB"
+	}
+	if v < 10 {
+		return strconv.FormatFloat(v, 'f', 1, 64) + unit
+	}
+	return strconv.FormatFloat(v, 'f', 0, 64) + unit
+}
+
+// downloadManifest fetches the manifest of model:ref from the registry
+func downloadManifest(ctx context.Context, modelName, modelParameters string) (*Manifest, error) {
+	return registryClient().Manifest(ctx, modelName, modelParameters)
+}
+
+// parseModelRef splits "model:tag" into its parts, defaulting the tag to "latest"
+func parseModelRef(ref string) (string, string, error) {
+	name, tag, found := strings.Cut(ref, ":")
+	if name == "" || (found && tag == "") {
+		return "", "", fmt.Errorf("invalid model reference %q (expected model:tag)", ref)
+	}
+	if !found {
+		tag = "latest"
+	}
+	name, err := normalizeModelName(name)
+	if err != nil {
+		return "", "", err
+	}
+	return name, tag, nil
+}
+
+// normalizeModelName checks a model name, bare or NAMESPACE/MODEL, and writes it the way
+// files and the ledger know it: names copied from ollama.com or the registry lose their
+// host, and the default namespace is left implicit, so "library/llama3" is "llama3"
+func normalizeModelName(name string) (string, error) {
+	for _, host := range []string{"ollama.com/", "www.ollama.com/", RegistryHost + "/"} {
+		name = strings.TrimPrefix(name, host)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, ` @\`) {
+			return "", fmt.Errorf("invalid model name %q (expected MODEL or NAMESPACE/MODEL)", name)
+		}
+	}
+	return strings.TrimPrefix(name, defaultNamespace+"/"), nil
+}
+
+// tagPattern is the form of a tag the registry accepts
+var tagPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+
+// validateTag checks a tag, or a manifest digest in its place, before it names a
+// download and so a file
+func validateTag(tag string) error {
+	if tagPattern.MatchString(tag) || isDigest(tag) {
+		return nil
+	}
+	return fmt.Errorf("invalid tag %q (expected letters, digits, '_', '.' and '-', or a digest)", tag)
+}
+
+// blobURL returns the registry URL of a blob belonging to a model
+func blobURL(modelName, digest string) string {
+	return registryClient().BlobURL(modelName, digest)
+}
+
+// downloadFile downloads a GGUF blob into filename, appending from offset when it is positive
+func downloadFile(ctx context.Context, url, filename string, transform StreamTransformer, offset int64) error {
+	return downloadChecked(ctx, url, filename, transform, offset, ollamareg.CheckGGUF)
+}
+
+// downloadChecked downloads url into filename, rejecting a fresh response whose leading bytes
+// fail check; a nil check accepts any payload. A transfer that stalls is re-dialed and
+// continues from the end of the file.
+func downloadChecked(ctx context.Context, url, filename string, transform StreamTransformer, offset int64, check func(*http.Response, []byte) error) error {
+	ctx, cancel := withPhaseTimeout(ctx, "download of "+filepath.Base(filename), "-transfer-timeout", transferTimeout)
+	defer cancel()
+	client := registryClient()
+	opts := downloadOptions(ctx, transform, check)
+	// Only the registry is known to always send lengths and accept ranges; Hugging Face
+	// files and aria2 mirrors may come from servers that do not
+	opts.Registry = strings.HasPrefix(url, client.URL(""))
+	err := client.DownloadFile(ctx, url, filename, offset, opts)
+	return timeoutCause(ctx, err)
+}
+
+// downloadOptions renders a download's progress the tool's way and writes the copies
+// -copy-to asks for under ctx
+func downloadOptions(ctx context.Context, transform StreamTransformer, check func(*http.Response, []byte) error) ollamareg.DownloadOptions {
+	return ollamareg.DownloadOptions{
+		Progress: func(label string, total, offset int64) ollamareg.Progress {
+			if l, ok := ctx.Value(bundleLayerKey{}).(*bundleLayer); ok && label == "Downloading" {
+				return l.render(total, offset)
+			}
+			return newProgressAt(total, offset, label)
+		},
+		Check:     check,
+		Transform: transform,
+		Copies: func(filename string, offset int64) ollamareg.Copies {
+			// A nil *outputCopies would make a non-nil interface
+			if copies := openCopies(ctx, filename, offset); copies != nil {
+				return copies
+			}
+			return nil
+		},
+	}
+}
+
+// fetchAvailableModels scrapes the ollama.com search results for query, most popular first
+func fetchAvailableModels(query string) ([]ModelInfo, error) {
+	var models []ModelInfo
+	err := scanAvailableModels(query, func(m ModelInfo) error {
+		models = append(models, m)
+		return nil
+	})
+	return models, err
+}
+
+// scanAvailableModels streams the ollama.com search results for query to fn
+func scanAvailableModels(query string, fn func(ModelInfo) error) error {
+	return scanSearchResults("popular", query, fn)
+}
+
+// scanSearchResults streams the ollama.com search results for query in the given order (popular or newest) to fn
+func scanSearchResults(order, query string, fn func(ModelInfo) error) error {
+	resp, err := scraper.get(context.Background(), "https://ollama.com/search?o="+order+"&c=all&q="+url.QueryEscape(query))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("failed to fetch model list: " + resp.Status)
+	}
+	if lowMemory {
+		return scanModelList(resp.Body, fn)
+	}
+	return parseModelList(resp.Body, fn)
+}
+
+func displayUsageExamples() {
+	fmt.Println(color.CyanString("\nCommand-line Usage Examples:"))
+	fmt.Println(color.WhiteString("  # List all available models:"))
+	fmt.Println("  ./ggufDownloader list")
+	fmt.Println("  ./ggufDownloader search coder")
+
+	fmt.Println(color.WhiteString("\n  # Show the tags of a model:"))
+	fmt.Println("  ./ggufDownloader tags llama2")
+	fmt.Println("  ./ggufDownloader -tags llama2   # with sizes and quantizations")
+
+	fmt.Println(color.WhiteString("\n  # Download a specific model:"))
+	fmt.Println("  ./ggufDownloader pull llama2:7b")
+	fmt.Println("  ./ggufDownloader pull phi:latest")
+	fmt.Println("  ./ggufDownloader -model mistral -params 7b-instruct")
+	fmt.Println("  ./ggufDownloader -source hf -model TheBloke/Llama-2-7B-GGUF -file Q4_K_M")
+
+	fmt.Println(color.WhiteString("\n  # The downloaded file will be saved as:"))
+	fmt.Println("  # modelname:params.gguf (e.g., llama2:7b.gguf)")
+}
+
+func displaySimpleUsage() {
+	fmt.Println(color.CyanString("\nSimple Usage:"))
+	fmt.Println(color.WhiteString("  List models:  ./ggufDownloader list"))
+	fmt.Println(color.WhiteString("  Download:     ./ggufDownloader pull MODEL:TAG"))
+	fmt.Println(color.WhiteString("  Help:         ./ggufDownloader help"))
+
+	// Add some basic examples to the simple usage display
+	fmt.Println(color.YellowString("\nQuick Examples:"))
+	fmt.Println("  ./ggufDownloader pull llama2:7b")
+	fmt.Println("  ./ggufDownloader -model phi -params latest")
+}
+
+// modelTable renders model listings one row at a time
+type modelTable struct {
+	nameWidth   int
+	sizesWidth  int // 0 for minSizesWidth
+	showDetails bool
+	markWidth   int // room left before each row for a caller-printed marker
+}
+
+// Column widths of the model table
+const (
+	minSizesWidth     = 30
+	maxSizesWidth     = 60
+	capabilitiesWidth = 30
+	infoWidth         = 20
+)
+
+// sizesColumn returns the width of the sizes column
+func (t modelTable) sizesColumn() int {
+	return max(t.sizesWidth, minSizesWidth)
+}
+
+// fit widens the name and sizes columns to the models, the sizes up to maxSizesWidth
+func (t *modelTable) fit(models []ModelInfo) {
+	for _, model := range models {
+		if len(model.Name) > t.nameWidth-3 {
+			t.nameWidth = len(model.Name) + 3
+		}
+		if n := len(model.sizesLabel()) + 3; n > t.sizesWidth {
+			t.sizesWidth = min(n, maxSizesWidth)
+		}
+	}
+}
+
+// printHeader prints the column headers and separator
+func (t modelTable) printHeader() {
+	fmt.Println()
+	headerFmt := color.CyanString
+	fmt.Printf("%*s", t.markWidth, "")
+	fmt.Printf(headerFmt("%-*s", t.nameWidth, "MODEL"))
+	fmt.Printf(headerFmt("%-*s", t.sizesColumn(), "AVAILABLE SIZES"))
+
+	if t.showDetails {
+		fmt.Printf(headerFmt("%-*s", capabilitiesWidth, "CAPABILITIES"))
+		fmt.Printf(headerFmt("%-*s", infoWidth, "DOWNLOADS"))
+		fmt.Printf(headerFmt("%s", "UPDATED"))
+	}
+	fmt.Println()
+
+	// Print separator line
+	separator := strings.Repeat("-", t.markWidth+t.nameWidth+t.sizesColumn())
+	if t.showDetails {
+		separator += strings.Repeat("-", capabilitiesWidth+infoWidth+20)
+	}
+	fmt.Println(headerFmt(separator))
+}
+
+// printRow prints one model
+func (t modelTable) printRow(model ModelInfo) {
+	// Model name in green
+	fmt.Printf(color.GreenString("%-*s", t.nameWidth, model.Name))
+
+	// Sizes in yellow
+	sizesWidth := t.sizesColumn()
+	sizes := model.sizesLabel()
+	if len(sizes) > sizesWidth-3 {
+		sizes = sizes[:sizesWidth-6] + "..."
+	}
+	fmt.Printf(color.YellowString("%-*s", sizesWidth, sizes))
+
+	// Additional details
+	if t.showDetails {
+		// Capabilities
+		caps := strings.Join(model.Capabilities, ", ")
+		if len(caps) > capabilitiesWidth-3 {
+			caps = caps[:capabilitiesWidth-6] + "..."
+		}
+		fmt.Printf(color.CyanString("%-*s", capabilitiesWidth, caps))
+
+		// Pull count
+		fmt.Printf(color.WhiteString("%-*s", infoWidth, model.PullCount))
+
+		// Updated date
+		fmt.Printf(color.WhiteString("%s", model.UpdatedAt))
+	}
+	fmt.Println()
+}
+
+// printModelsTable prints the models in a table format
+func printModelsTable(models []ModelInfo, showDetails bool) {
+	t := modelTable{nameWidth: 20, showDetails: showDetails}
+	t.fit(models)
+
+	t.printHeader()
+	for _, model := range models {
+		t.printRow(model)
+	}
+}
+
+// streamModelsTable prints search results as they are parsed, caching them on the way
+// when cache is set, and stops printing after limit rows when limit is positive
+func streamModelsTable(query string, showDetails, cache bool, limit int) (int, error) {
+	// The longest name is unknown up front, so use a width that fits nearly all of them
+	t := modelTable{nameWidth: 30, showDetails: showDetails}
+	t.printHeader()
+
+	var w *catalogWriter
+	if cache {
+		var err error
+		if w, err = newCatalogWriter(); err != nil {
+			fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Could not cache catalog: %s", err))
+		}
+	}
+
+	count := 0
+	err := scanAvailableModels(query, func(m ModelInfo) error {
+		if limit <= 0 || count < limit {
+			t.printRow(m)
+		}
+		count++
+		if w != nil {
+			return w.add(m)
+		}
+		return nil
+	})
+	if w != nil {
+		if err != nil {
+			w.abort()
+		} else if cerr := w.close(); cerr != nil {
+			fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Could not cache catalog: %s", cerr))
+		}
+	}
+	return count, err
+}
+
+// PullOptions controls what happens to a model around its download
+type PullOptions struct {
+	// Transform rewrites the blob while it downloads
+	Transform StreamTransformer
+	// RegisterAs registers the file with the local Ollama instance under this name
+	RegisterAs string
+	// IfExists is what to do when the output file already exists: skip, overwrite, rename or resume
+	IfExists string
+	// HFFallback fetches an equivalent GGUF from Hugging Face when the registry cannot serve the blob
+	HFFallback bool
+	// RunScript writes the suggested run command next to the model as MODEL:TAG.sh
+	RunScript bool
+	// PrintPath prints the absolute path of each downloaded file on stdout for scripts
+	PrintPath bool
+	// Manifest fetches this manifest digest instead of the tag's current manifest
+	Manifest string
+	// Digest is the blob digest the download must have; the file is verified against it
+	Digest string
+	// Output streams the blob to an ssh://[user@]host[:port]/path target instead of a local file
+	Output string
+	// CrossDevice is how a cached blob on another filesystem is placed: ask, copy or symlink
+	CrossDevice string
+	// NoVerify skips hashing the file after the download, leaving it unverified
+	NoVerify bool
+	// ScanHook is run on the finished file with {path} substituted; a zero exit accepts it
+	ScanHook string
+	// CopyTo are directories that receive a copy of the file as it downloads
+	CopyTo []string
+	// OutputFile is the path to save the model to, overriding Naming
+	OutputFile string
+	// Naming decides the output file name; nil names files the Ollama way
+	Naming NamingPolicy
+	// NoProjector skips the vision projector a multimodal model is paired with
+	NoProjector bool
+	// Full saves every layer of the manifest, not only the weights, in a directory of
+	// its own, with the weights as model.gguf
+	Full bool
+	// Modelfile writes an Ollama Modelfile for the download, built from its template,
+	// system, params and license layers
+	Modelfile bool
+	// Install writes the model's blobs and manifest into the local Ollama store
+	Install bool
+}
+
+// fileName returns the file name a model is saved under with the chosen naming policy
+func (o PullOptions) fileName(m NamedModel) string {
+	if o.OutputFile != "" {
+		return o.OutputFile
+	}
+	if o.Naming == nil {
+		return OllamaNaming{}.FileName(m)
+	}
+	return o.Naming.FileName(m)
+}
+
+// existsPolicies are the accepted values of PullOptions.IfExists
+var existsPolicies = []string{"skip", "overwrite", "rename", "resume"}
+
+// renamedPath returns filename with the first free numeric suffix, e.g. "phi:latest-1.gguf"
+func renamedPath(filename string) string {
+	ext := filepath.Ext(filename)
+	base := strings.TrimSuffix(filename, ext)
+	for n := 1; ; n++ {
+		candidate := fmt.Sprintf("%s-%d%s", base, n, ext)
+		if _, err := os.Stat(candidate); errors.Is(err, os.ErrNotExist) {
+			return candidate
+		}
+	}
+}
+
+// fetchPinnedManifest fetches the manifest with the pinned digest, falling back to the
+// tag's current manifest when the registry no longer serves it by digest
+func fetchPinnedManifest(ctx context.Context, modelName, tag, pinned string) (*Manifest, error) {
+	if pinned != "" {
+		manifest, err := fetchManifest(ctx, modelName, pinned)
+		if err == nil {
+			return manifest, nil
+		}
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Manifest %s is unavailable (%s); trying %s:%s", pinned, err, modelName, tag))
+	}
+	return fetchManifest(ctx, modelName, tag)
+}
+
+// pullModel resolves the manifest for a model and downloads its weights, returning the output filename
+func pullModel(ctx context.Context, modelName, modelParameters string, opts PullOptions) (string, error) {
+	if opts.IfExists != "" && !slices.Contains(existsPolicies, opts.IfExists) {
+		return "", fmt.Errorf("unknown -if-exists policy %q (use %s)", opts.IfExists, strings.Join(existsPolicies, ", "))
+	}
+	if opts.CrossDevice != "" && !slices.Contains(crossDevicePolicies, opts.CrossDevice) {
+		return "", fmt.Errorf("unknown -cross-device policy %q (use %s)", opts.CrossDevice, strings.Join(crossDevicePolicies, ", "))
+	}
+	if opts.OutputFile == "-" || strings.HasSuffix(opts.OutputFile, string(filepath.Separator)) {
+		return "", fmt.Errorf("-O needs a file name, not %q", opts.OutputFile)
+	}
+	if opts.Output != "" {
+		if opts.Full {
+			return "", errors.New("-full saves a directory of files and cannot be combined with -output")
+		}
+		if opts.Modelfile || opts.Install {
+			return "", errors.New("-modelfile and -install write local files and cannot be combined with -output")
+		}
+		if len(opts.CopyTo) > 0 {
+			return "", errors.New("-copy-to applies to local downloads, not -output")
+		}
+		return pullToRemote(ctx, modelName, modelParameters, opts)
+	}
+	ctx = withProvenance(withCopies(ctx, opts.CopyTo))
+
+	manifest, err := fetchPinnedManifest(ctx, modelName, modelParameters, opts.Manifest)
+	if err != nil {
+		return "", err
+	}
+
+	layer := manifest.ModelLayer()
+	if layer == nil {
+		return "", errors.New("model digest not found in manifest")
+	}
+	modelDigest := layer.Digest
+	if opts.Digest != "" && modelDigest != opts.Digest {
+		return "", fmt.Errorf("%s:%s now serves %s, not the pinned %s", modelName, modelParameters, modelDigest, opts.Digest)
+	}
+
+	config, err := fetchModelConfig(ctx, modelName, manifest)
+	if err == nil {
+		fmt.Fprintln(os.Stderr, color.CyanString("[INFO] %s:%s is %s", modelName, modelParameters, config.Summary()))
+	}
+
+	downloadURL := blobURL(modelName, modelDigest)
+	sidecar := &Sidecar{Model: modelName, Tag: modelParameters, Digest: modelDigest, Source: downloadURL, Config: config}
+	// An explicit -O path is taken as given, not placed in the project's models directory
+	outputFilename := opts.OutputFile
+	if outputFilename == "" {
+		name := opts.fileName(NamedModel{Model: modelName, Tag: modelParameters, Digest: modelDigest, Config: config})
+		if opts.Full {
+			// The directory is named like the file would have been
+			name = filepath.Join(strings.TrimSuffix(name, ".gguf"), fullModelFile)
+		}
+		if outputFilename, err = outputPath(name); err != nil {
+			return "", err
+		}
+	}
+	if opts.Full {
+		if err := os.MkdirAll(filepath.Dir(outputFilename), 0o755); err != nil {
+			return "", err
+		}
+	}
+
+	transform := opts.Transform
+	// Downloads are written to FILE.part and take the model's name only once complete,
+	// so an interrupted one is picked up by the next pull instead of starting over
+	partial := partialPath(outputFilename)
+	if info, err := os.Stat(outputFilename); err == nil {
+		switch opts.IfExists {
+		case "skip":
+			fmt.Fprintln(os.Stderr, color.YellowString("[WARN] %s already exists, skipping", outputFilename))
+			return outputFilename, nil
+		case "rename":
+			outputFilename = renamedPath(outputFilename)
+			partial = partialPath(outputFilename)
+		case "resume":
+			if transform != nil {
+				return "", errors.New("-if-exists resume cannot be combined with -transform")
+			}
+			// The file may be a partial download made under the model's name; it is
+			// continued like a .part file and verified once complete
+			if _, err := os.Stat(partial); errors.Is(err, os.ErrNotExist) && info.Mode().IsRegular() {
+				if err := os.Rename(outputFilename, partial); err != nil {
+					return "", err
+				}
+				os.Rename(ollamareg.ResumeStatePath(outputFilename), ollamareg.ResumeStatePath(partial))
+			}
+		default:
+			fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Overwriting existing %s", outputFilename))
+		}
+	}
+	var resumeFrom int64
+	if info, err := os.Stat(partial); err == nil {
+		switch {
+		case transform != nil:
+			// A transformed stream cannot be picked up in the middle
+			discardPartial(ctx, partial)
+		case opts.IfExists != "resume" && !ollamareg.CanResume(partial, downloadURL):
+			// Left by a download of another blob, such as the tag's previous version
+			fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Discarding %s, which belongs to another download", partial))
+			discardPartial(ctx, partial)
+		default:
+			resumeFrom = info.Size()
+		}
+	}
+
+	scanHook, err := scanHookFor(opts)
+	if err != nil {
+		return "", err
+	}
+
+	// Transformed output no longer matches the digest, so it bypasses the blob cache
+	cached, cacheable := false, false
+	if transform == nil {
+		if cached, err = materializeBlob(modelDigest, outputFilename, opts.CrossDevice); err != nil {
+			return "", err
+		}
+	}
+
+	// Set when the registry could not serve the blob and Hugging Face did instead
+	var fromHF *hfFile
+	if cached {
+		fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Using cached blob for %s", outputFilename))
+		addProvenance(ctx, ProvenanceHop{Step: "cache", Digest: modelDigest, Detail: "copied from the local blob cache"})
+		discardPartial(ctx, partial)
+	} else {
+		if resumeFrom > 0 {
+			fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Resuming %s at %s...", outputFilename, formatBytes(resumeFrom)))
+		} else {
+			fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Downloading %s...", outputFilename))
+		}
+		err := downloadFile(ctx, downloadURL, partial, transform, resumeFrom)
+		switch {
+		case err != nil && opts.HFFallback && blobUnavailable(err):
+			fmt.Fprintln(os.Stderr, color.YellowString("[WARN] %s; looking for an equivalent GGUF on Hugging Face", err))
+			discardPartial(ctx, partial)
+			file, hfErr := fallbackToHuggingFace(ctx, modelName, config, partial, transform)
+			if hfErr != nil {
+				discardPartial(ctx, partial)
+				return "", fmt.Errorf("%w (Hugging Face fallback: %v)", err, hfErr)
+			}
+			sidecar.Digest, sidecar.Source = file.Digest(), file.URL()
+			fromHF = file
+			sidecar.Notes = append(sidecar.Notes,
+				fmt.Sprintf("registry blob %s was unavailable (%s)", modelDigest, err),
+				fmt.Sprintf("downloaded %s from Hugging Face repository %s instead", file.Name, file.Repo),
+				"the file is an independent build of the same model and quantization, not the Ollama blob")
+		case err != nil:
+			// Transformed output cannot be resumed, and neither can a file whose server
+			// gave no validators to resume it against
+			_, stateErr := os.Stat(ollamareg.ResumeStatePath(partial))
+			keepPartial(ctx, partial, transform == nil && stateErr == nil)
+			return "", err
+		default:
+			verify := transform == nil
+			switch {
+			case verify && opts.NoVerify:
+				fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Skipping verification of %s (-no-verify); run verify on it later", outputFilename))
+				sidecar.Notes = append(sidecar.Notes, "not verified after download (-no-verify)")
+			case verify:
+				// A download hashed on its way to disk is checked instantly; a file resumed
+				// without checkpoints is read back
+				if err := verifyFile(partial, modelDigest); err != nil {
+					discardPartial(ctx, partial)
+					return "", fmt.Errorf("%w; the download was discarded, so the next pull starts over", err)
+				}
+			}
+			// An unverified file must not be shared under its expected digest
+			cacheable = transform == nil && !(verify && opts.NoVerify)
+		}
+		if transform != nil {
+			sidecar.Notes = append(sidecar.Notes, "rewritten by a -transform command, so it differs from the source file")
+			sidecar.Digest = ""
+		}
+		// Scanned while it is still FILE.part, so a rejected file never appears under its name
+		if err := scanFile(partial, sidecar.Digest, scanHook); err != nil {
+			discardPartial(ctx, partial)
+			return "", err
+		}
+		if err := finishPartial(ctx, partial, outputFilename); err != nil {
+			return "", err
+		}
+	}
+
+	if cached {
+		// Blobs cached before the hook was configured were never scanned, so cached ones are too
+		if err := scanFile(outputFilename, sidecar.Digest, scanHook); err != nil {
+			return "", err
+		}
+		copyFinished(outputFilename, copyPaths(ctx, outputFilename))
+	}
+	if cacheable {
+		cacheBlob(outputFilename, modelDigest)
+	}
+
+	if opts.RegisterAs != "" {
+		digest := sidecar.Digest
+		if digest == "" {
+			if digest, err = fileDigest(outputFilename); err != nil {
+				return "", err
+			}
+		}
+		if err := registerWithOllama(ctx, outputFilename, digest, opts.RegisterAs); err != nil {
+			return "", fmt.Errorf("downloaded %s but registering with Ollama failed: %w", outputFilename, err)
+		}
+		fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] Registered with Ollama as %s", opts.RegisterAs))
+	}
+
+	if err := recordPull(modelName, modelParameters, sidecar.Digest, outputFilename, cached, fromHF); err != nil {
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Could not update download ledger: %s", err))
+	}
+	if info, err := os.Stat(outputFilename); err == nil {
+		sidecar.Size = info.Size()
+	}
+	sidecar.DownloadedAt = time.Now()
+	sidecar.Provenance = provenanceChain(ctx)
+	// llama.cpp needs the projector of a vision model next to the weights to read images
+	var layersErr error
+	if !opts.NoProjector {
+		sidecar.Projectors, layersErr = pullProjectors(ctx, modelName, manifest, outputFilename, opts, scanHook)
+	}
+	if opts.Full && layersErr == nil {
+		layersErr = pullFullLayers(ctx, modelName, manifest, filepath.Dir(outputFilename), opts)
+	}
+	if opts.Modelfile && layersErr == nil {
+		layersErr = writeModelfile(ctx, modelName, modelParameters, manifest, outputFilename, sidecar.Projectors, opts.Full)
+	}
+	if opts.Install && layersErr == nil {
+		// Only files checked against their digest are put into the store as they are
+		local := make(map[string]string)
+		if (cached || cacheable) && sidecar.Digest == modelDigest {
+			local[modelDigest] = outputFilename
+		}
+		for _, p := range sidecar.Projectors {
+			if !opts.NoVerify {
+				local[p.Digest] = p.File
+			}
+		}
+		layersErr = installToOllama(ctx, modelName, modelParameters, manifest, local, opts)
+	}
+	if err := writeSidecar(outputFilename, sidecar); err != nil {
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Could not write sidecar: %s", err))
+	}
+	for _, path := range copyPaths(ctx, outputFilename) {
+		// A copy dropped along the way is missing or short, and gets no sidecar
+		if info, err := os.Stat(path); err == nil && info.Size() == sidecar.Size {
+			writeSidecar(path, sidecar)
+			fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] Copied to %s", path))
+		}
+	}
+	if layersErr != nil {
+		return "", fmt.Errorf("%s is complete, but %w; pull again to finish it", outputFilename, layersErr)
+	}
+	return outputFilename, nil
+}
+
+// outputPath places a downloaded file in the active project's models directory, or the working directory otherwise
+func outputPath(filename string) (string, error) {
+	if activeProject == "" {
+		return filename, nil
+	}
+	dir, err := projectDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "models")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filename), nil
+}
+
+// recordPull adds a completed download to the ledger; cached marks pulls served from the
+// blob cache, and hf is the Hugging Face file it came from instead of the registry, if any
+func recordPull(modelName, modelParameters, digest, filename string, cached bool, hf *hfFile) error {
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	entry := LedgerEntry{
+		Model:        modelName,
+		Params:       modelParameters,
+		Digest:       digest,
+		Path:         path,
+		Size:         info.Size(),
+		Cached:       cached,
+		DownloadedAt: time.Now(),
+	}
+	if hf != nil {
+		entry.Source, entry.URL = sourceHF, hf.URL()
+	}
+	return recordDownload(entry)
+}
+
+// listModelsCommand prints the catalog; brief shows only the most popular models with the basic usage
+func listModelsCommand(showDetails, brief bool) error {
+	// Limit the number of models shown in the simple view to avoid overwhelming
+	maxModelsToShow := 10
+
+	if lowMemory {
+		fmt.Println(color.CyanString("\n=== Available models from Ollama ==="))
+		limit := 0
+		if brief {
+			limit = maxModelsToShow
+		}
+		count, err := streamModelsTable("", showDetails && !brief, true, limit)
+		if err != nil || count == 0 {
+			if err == nil {
+				err = errors.New("no models found on the page")
+			}
+			// The header is already out, so continue the table with the offline rows
+			cache, err := offlineCatalog(err)
+			if err != nil {
+				return err
+			}
+			models := cache.Models
+			count = len(models)
+			if limit > 0 && count > limit {
+				models = models[:limit]
+			}
+			t := modelTable{nameWidth: 30, showDetails: showDetails && !brief}
+			for _, m := range models {
+				t.printRow(m)
+			}
+		}
+		if limit > 0 && count > limit {
+			fmt.Printf(color.WhiteString("\n... and %d more (use list to see all)\n"), count-limit)
+		}
+		if brief {
+			displaySimpleUsage()
+		} else {
+			displayUsageExamples()
+		}
+		return nil
+	}
+
+	models, err := fetchAvailableModels("")
+	if err == nil && len(models) == 0 {
+		// An empty page means the scraper no longer understands the layout
+		err = errors.New("no models found on the page")
+	}
+	if err != nil {
+		cache, err := offlineCatalog(err)
+		if err != nil {
+			return err
+		}
+		models = cache.Models
+	} else if err := saveCatalog(models); err != nil {
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Could not cache catalog: %s", err))
+	}
+
+	// Show the header with a clear separator for better visibility
+	fmt.Println(color.CyanString("\n=== Available models from Ollama ==="))
+
+	if brief && len(models) > maxModelsToShow {
+		printModelsTable(models[:maxModelsToShow], false)
+		fmt.Printf(color.WhiteString("\n... and %d more (use list to see all)\n"), len(models)-maxModelsToShow)
+	} else {
+		printModelsTable(models, showDetails)
+	}
+
+	// Always show usage information, with varying detail based on context
+	if brief {
+		displaySimpleUsage()
+	} else {
+		displayUsageExamples()
+	}
+	return nil
+}
+
+var listCommand = &Command{
+	Name:    "list",
+	Summary: "List available models with sizes, capabilities and download counts",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		short := fs.Bool("short", false, "Show only the most popular models without details")
+		return func(args []string) error {
+			return listModelsCommand(!*short, *short)
+		}
+	},
+}
+
+var searchCommand = &Command{
+	Name:    "search",
+	Usage:   "QUERY",
+	Summary: "Search models on ollama.com",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		license := fs.String("license", "", "Only show models whose license matches, e.g. commercial, apache-2.0,mit or !non-commercial")
+		hf := fs.Bool("hf", false, "Also show each model's GGUF repositories on Hugging Face and their quantizations")
+		return func(args []string) error {
+			if len(args) == 0 {
+				return errors.New("usage: search [-license LICENSES] [-hf] QUERY")
+			}
+			query := strings.Join(args, " ")
+			var filter *licenseFilter
+			if *license != "" {
+				var err error
+				if filter, err = parseLicenseFilter(*license); err != nil {
+					return err
+				}
+			}
+			// Licenses and cross-references are looked up per model, so those results are collected first
+			if lowMemory && filter == nil && !*hf {
+				count, err := streamModelsTable(query, true, false, 0)
+				if err == nil && count == 0 {
+					fmt.Fprintln(os.Stderr, color.YellowString("[WARN] No models match %q", query))
+				}
+				return err
+			}
+			models, err := fetchAvailableModels(query)
+			if err != nil {
+				return err
+			}
+			if len(models) > 0 && filter != nil {
+				found := len(models)
+				models = filterByLicense(models, filter)
+				fmt.Fprintln(os.Stderr, color.CyanString("[INFO] %d of %d models match -license %s", len(models), found, *license))
+			}
+			if len(models) == 0 {
+				fmt.Fprintln(os.Stderr, color.YellowString("[WARN] No models match %q", query))
+				return nil
+			}
+			printModelsTable(models, true)
+			if *hf {
+				fmt.Println("\nOn Hugging Face:")
+				for _, model := range models {
+					fmt.Println(color.GreenString(model.Name))
+					printHFCrossReference(context.Background(), os.Stdout, model.Name, nil)
+				}
+			}
+			return nil
+		}
+	},
+}
+
+// reportPulled announces a completed download; with PrintPath its absolute path is the only output on stdout
+func reportPulled(filename string, opts PullOptions) {
+	fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] Download completed: %s", filename))
+	if isRemoteOutput(filename) {
+		if opts.PrintPath {
+			fmt.Println(filename)
+		}
+		return
+	}
+	if !opts.PrintPath || opts.RunScript {
+		suggestRunCommands(filename, opts.RunScript)
+	}
+	if !opts.PrintPath {
+		return
+	}
+	if abs, err := filepath.Abs(filename); err == nil {
+		filename = abs
+	}
+	fmt.Println(filename)
+}
+
+// pullAndReport downloads a model and prints the outcome; a tag pattern such as
+// "7b-*-q4_K_M" downloads every matching tag in turn
+func pullAndReport(modelName, modelParameters string, opts PullOptions) error {
+	ctx := interruptContext()
+	if !isTagPattern(modelParameters) {
+		outputFilename, err := pullModel(ctx, modelName, modelParameters, opts)
+		if recordPullOutcome(modelName, modelParameters, err) {
+			offerDiagnostics(modelName + ":" + modelParameters)
+		}
+		if err != nil {
+			return err
+		}
+		reportPulled(outputFilename, opts)
+		return nil
+	}
+
+	// The tag list the pattern was matched against leads the provenance of every pull
+	ctx = withProvenance(ctx)
+	tags, err := matchTags(ctx, modelName, modelParameters)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, color.CyanString("[INFO] %s:%s matches %d tags: %s", modelName, modelParameters, len(tags), strings.Join(tags, ", ")))
+
+	failed, repeated := 0, false
+	for _, tag := range tags {
+		outputFilename, err := pullModel(ctx, modelName, tag, opts)
+		if recordPullOutcome(modelName, tag, err) {
+			repeated = true
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("[ERROR] %s:%s: %s", modelName, tag, err))
+			failed++
+			continue
+		}
+		reportPulled(outputFilename, opts)
+	}
+	if repeated {
+		offerDiagnostics("A download of " + modelName)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d tags failed", failed, len(tags))
+	}
+	return nil
+}
+
+var pullCommand = &Command{
+	Name:    "pull",
+	Usage:   "MODEL[:TAG]... | @ALIAS",
+	Summary: "Download models",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		pull := addPullFlags(fs)
+		specFile := fs.String("spec", "", "Download the exact digests pinned by a spec from \"share\" (- for stdin)")
+		return func(args []string) error {
+			if *specFile != "" {
+				if len(args) != 0 {
+					return errors.New("-spec cannot be combined with model arguments")
+				}
+				spec, err := readShareSpec(*specFile)
+				if err != nil {
+					return err
+				}
+				opts, err := spec.options(pull.options())
+				if err != nil {
+					return err
+				}
+				return pullAndReport(spec.Model, spec.Tag, opts)
+			}
+			if len(args) == 0 {
+				return errors.New("usage: pull MODEL[:TAG]... | pull @ALIAS | pull -spec FILE")
+			}
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			refs, err := cfg.expandAliases(args)
+			if err != nil {
+				return err
+			}
+			if len(refs) > 1 && pull.options().OutputFile != "" {
+				return errors.New("-O names a single file, so it takes exactly one model")
+			}
+			for _, ref := range refs {
+				modelName, tag, err := parseModelRef(ref)
+				if err != nil {
+					return err
+				}
+				if err := pullAndReport(modelName, tag, pull.options()); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	},
+}
+
+// setupHTTPClient configures the shared HTTP client and scraper from the selected profile and flag overrides
+func setupHTTPClient(profileName string, overrides TransportOptions, crawl CrawlOptions) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	profile, err := cfg.profile(profileName)
+	if err != nil {
+		return err
+	}
+
+	registry := overrides.Registry
+	if registry == "" {
+		registry = profile.Registry
+	}
+	if err := setRegistry(registry, profile.RegistryToken); err != nil {
+		return err
+	}
+	if err := setNamespace(profile.Namespace); err != nil {
+		return err
+	}
+
+	opts := TransportOptions{MinTLS: profile.MinTLS, Pins: profile.Pins, Family: overrides.Family}
+	if overrides.MinTLS != "" {
+		opts.MinTLS = overrides.MinTLS
+	}
+	if len(overrides.Pins) > 0 {
+		opts.Pins = overrides.Pins
+	}
+	opts.Privacy = overrides.Privacy || profile.Privacy
+	privacyMode = opts.Privacy
+	opts.Record, opts.Replay = overrides.Record, overrides.Replay
+	opts.SSHTunnel = overrides.SSHTunnel
+	if opts.SSHTunnel == "" {
+		opts.SSHTunnel = profile.SSHTunnel
+	}
+	// A replay never reaches the network, so there is nothing to tunnel
+	if opts.SSHTunnel != "" && opts.Replay == "" {
+		if opts.Proxy, err = startSSHTunnel(opts.SSHTunnel); err != nil {
+			return err
+		}
+	}
+
+	client, err := newHTTPClient(opts)
+	if err != nil {
+		return err
+	}
+	httpClient = client
+	transportSettings = opts
+
+	if crawl.Concurrency == 0 {
+		crawl.Concurrency = profile.CrawlConcurrency
+	}
+	if crawl.Delay == 0 && profile.CrawlDelay != "" {
+		if crawl.Delay, err = time.ParseDuration(profile.CrawlDelay); err != nil {
+			return fmt.Errorf("invalid crawl_delay in profile: %w", err)
+		}
+	}
+	scraper = newPoliteFetcher(crawl)
+	return nil
+}
+
+func main() {
+	err := runCLI(os.Args[1:])
+	closeSSHTunnel()
+	if err != nil {
+		fmt.Fprintln(consoleErr, color.RedString("[ERROR] %s", err))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,507 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// defaultGGUFAlignment is where tensor data starts when general.alignment is not set
+const defaultGGUFAlignment = 32
+
+// ggufTypeNames name the metadata value types for meta set -type
+var ggufTypeNames = map[string]uint32{
+	"uint8": ggufTypeUint8, "int8": ggufTypeInt8, "uint16": ggufTypeUint16, "int16": ggufTypeInt16,
+	"uint32": ggufTypeUint32, "int32": ggufTypeInt32, "float32": ggufTypeFloat32, "bool": ggufTypeBool,
+	"string": ggufTypeString, "uint64": ggufTypeUint64, "int64": ggufTypeInt64, "float64": ggufTypeFloat64,
+}
+
+// ggufScalarSizes are the encoded sizes of the fixed-size value types
+var ggufScalarSizes = map[uint32]int{
+	ggufTypeUint8: 1, ggufTypeInt8: 1, ggufTypeBool: 1, ggufTypeUint16: 2, ggufTypeInt16: 2,
+	ggufTypeUint32: 4, ggufTypeInt32: 4, ggufTypeFloat32: 4, ggufTypeUint64: 8, ggufTypeInt64: 8, ggufTypeFloat64: 8,
+}
+
+// ggufKV is one metadata entry with its value still encoded, so entries that are not
+// edited are written back byte for byte
+type ggufKV struct {
+	Key  string
+	Type uint32
+	Raw  []byte
+}
+
+// ggufHeader is everything in a GGUF file before the tensor data
+type ggufHeader struct {
+	Version     uint32
+	TensorCount uint64
+	KV          []ggufKV
+	// Tensors holds the tensor descriptions as they are encoded; their offsets are
+	// relative to the data section, so they survive a header of another size
+	Tensors []byte
+	// DataOffset is where the tensor data starts in the file
+	DataOffset int64
+}
+
+// copyValue moves one encoded value of type t from g to w
+func (g *ggufReader) copyValue(t uint32, w *bytes.Buffer) error {
+	if n, ok := ggufScalarSizes[t]; ok {
+		b, err := g.read(n)
+		w.Write(b)
+		return err
+	}
+	switch t {
+	case ggufTypeString:
+		n, err := g.uint64()
+		if err != nil {
+			return err
+		}
+		if n > maxGGUFString {
+			return fmt.Errorf("string of %d bytes exceeds the metadata limit", n)
+		}
+		binary.Write(w, binary.LittleEndian, n)
+		_, err = io.CopyN(w, g.r, int64(n))
+		return err
+	case ggufTypeArray:
+		elemType, err := g.uint32()
+		if err != nil {
+			return err
+		}
+		count, err := g.uint64()
+		if err != nil {
+			return err
+		}
+		binary.Write(w, binary.LittleEndian, elemType)
+		binary.Write(w, binary.LittleEndian, count)
+		for i := uint64(0); i < count; i++ {
+			if err := g.copyValue(elemType, w); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown metadata value type %d", t)
+}
+
+// readGGUFHeader reads the metadata and tensor descriptions of a GGUF file verbatim
+func readGGUFHeader(r io.Reader) (*ggufHeader, error) {
+	g := &ggufReader{r: bufio.NewReaderSize(r, 1<<20)}
+	magic, err := g.read(4)
+	if err != nil {
+		return nil, err
+	}
+	if string(magic) != string(ggufMagic) {
+		return nil, errors.New("not a GGUF file")
+	}
+	h := &ggufHeader{}
+	if h.Version, err = g.uint32(); err != nil {
+		return nil, err
+	}
+	if h.Version < 2 {
+		return nil, fmt.Errorf("GGUF version %d is not supported", h.Version)
+	}
+	if h.TensorCount, err = g.uint64(); err != nil {
+		return nil, err
+	}
+	kvCount, err := g.uint64()
+	if err != nil {
+		return nil, err
+	}
+
+	alignment := uint64(defaultGGUFAlignment)
+	for i := uint64(0); i < kvCount; i++ {
+		key, err := g.string()
+		if err != nil {
+			return nil, fmt.Errorf("metadata entry %d: %w", i, err)
+		}
+		t, err := g.uint32()
+		if err != nil {
+			return nil, fmt.Errorf("metadata %s: %w", key, err)
+		}
+		var raw bytes.Buffer
+		if err := g.copyValue(t, &raw); err != nil {
+			return nil, fmt.Errorf("metadata %s: %w", key, err)
+		}
+		if key == "general.alignment" && t == ggufTypeUint32 {
+			alignment = uint64(binary.LittleEndian.Uint32(raw.Bytes()))
+		}
+		h.KV = append(h.KV, ggufKV{key, t, raw.Bytes()})
+	}
+
+	var tensors bytes.Buffer
+	for i := uint64(0); i < h.TensorCount; i++ {
+		name, err := g.string()
+		if err != nil {
+			return nil, fmt.Errorf("tensor %d: %w", i, err)
+		}
+		binary.Write(&tensors, binary.LittleEndian, uint64(len(name)))
+		tensors.WriteString(name)
+		dims, err := g.uint32()
+		if err != nil {
+			return nil, fmt.Errorf("tensor %s: %w", name, err)
+		}
+		if dims > maxGGUFDims {
+			return nil, fmt.Errorf("tensor %s: %d dimensions exceed the limit of %d", name, dims, maxGGUFDims)
+		}
+		binary.Write(&tensors, binary.LittleEndian, dims)
+		// The dimensions, then the type and the data offset
+		b := make([]byte, int(dims)*8+4+8)
+		if _, err := io.ReadFull(g.r, b); err != nil {
+			return nil, fmt.Errorf("tensor %s: %w", name, err)
+		}
+		tensors.Write(b)
+	}
+	h.Tensors = tensors.Bytes()
+	if alignment == 0 {
+		return nil, errors.New("general.alignment is 0")
+	}
+	h.DataOffset = int64(alignUp(uint64(h.encodedSize()), alignment))
+	return h, nil
+}
+
+// alignUp rounds n up to a multiple of alignment
+func alignUp(n, alignment uint64) uint64 {
+	return (n + alignment - 1) / alignment * alignment
+}
+
+// encodedSize is the length of the header before padding
+func (h *ggufHeader) encodedSize() int {
+	n := 4 + 4 + 8 + 8 + len(h.Tensors)
+	for _, kv := range h.KV {
+		n += 8 + len(kv.Key) + 4 + len(kv.Raw)
+	}
+	return n
+}
+
+// encode writes the header padded to the alignment at which the tensor data starts
+func (h *ggufHeader) encode(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	buf.Write(ggufMagic)
+	binary.Write(&buf, binary.LittleEndian, h.Version)
+	binary.Write(&buf, binary.LittleEndian, h.TensorCount)
+	binary.Write(&buf, binary.LittleEndian, uint64(len(h.KV)))
+	for _, kv := range h.KV {
+		binary.Write(&buf, binary.LittleEndian, uint64(len(kv.Key)))
+		buf.WriteString(kv.Key)
+		binary.Write(&buf, binary.LittleEndian, kv.Type)
+		buf.Write(kv.Raw)
+	}
+	buf.Write(h.Tensors)
+	alignment := uint64(defaultGGUFAlignment)
+	if i := h.find("general.alignment"); i >= 0 && h.KV[i].Type == ggufTypeUint32 {
+		alignment = uint64(binary.LittleEndian.Uint32(h.KV[i].Raw))
+	}
+	buf.Write(make([]byte, alignUp(uint64(buf.Len()), alignment)-uint64(buf.Len())))
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+// find returns the index of key, or -1
+func (h *ggufHeader) find(key string) int {
+	for i, kv := range h.KV {
+		if kv.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// set gives key a new value of type t, appending the key if it is new
+func (h *ggufHeader) set(key string, t uint32, raw []byte) {
+	if i := h.find(key); i >= 0 {
+		h.KV[i] = ggufKV{key, t, raw}
+		return
+	}
+	h.KV = append(h.KV, ggufKV{key, t, raw})
+}
+
+// unset removes key, reporting whether it was there
+func (h *ggufHeader) unset(key string) bool {
+	i := h.find(key)
+	if i < 0 {
+		return false
+	}
+	h.KV = append(h.KV[:i], h.KV[i+1:]...)
+	return true
+}
+
+// encodeGGUFValue encodes text as a scalar value of type t
+func encodeGGUFValue(t uint32, text string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	write := func(v any) { binary.Write(&buf, binary.LittleEndian, v) }
+	switch t {
+	case ggufTypeString:
+		write(uint64(len(text)))
+		buf.WriteString(text)
+	case ggufTypeBool:
+		var b bool
+		if b, err = strconv.ParseBool(text); err == nil {
+			write(b)
+		}
+	case ggufTypeUint8, ggufTypeUint16, ggufTypeUint32, ggufTypeUint64:
+		var v uint64
+		if v, err = strconv.ParseUint(text, 0, ggufScalarSizes[t]*8); err == nil {
+			switch t {
+			case ggufTypeUint8:
+				write(uint8(v))
+			case ggufTypeUint16:
+				write(uint16(v))
+			case ggufTypeUint32:
+				write(uint32(v))
+			default:
+				write(v)
+			}
+		}
+	case ggufTypeInt8, ggufTypeInt16, ggufTypeInt32, ggufTypeInt64:
+		var v int64
+		if v, err = strconv.ParseInt(text, 0, ggufScalarSizes[t]*8); err == nil {
+			switch t {
+			case ggufTypeInt8:
+				write(int8(v))
+			case ggufTypeInt16:
+				write(int16(v))
+			case ggufTypeInt32:
+				write(int32(v))
+			default:
+				write(v)
+			}
+		}
+	case ggufTypeFloat32:
+		var v float64
+		if v, err = strconv.ParseFloat(text, 32); err == nil {
+			write(math.Float32bits(float32(v)))
+		}
+	case ggufTypeFloat64:
+		var v float64
+		if v, err = strconv.ParseFloat(text, 64); err == nil {
+			write(math.Float64bits(v))
+		}
+	default:
+		return nil, errors.New("arrays cannot be set")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%q: %w", text, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ggufEdit is one change requested with meta set
+type ggufEdit struct {
+	Key   string
+	Value string
+	// Unset removes the key instead
+	Unset bool
+}
+
+// editGGUF applies edits to the header of src and writes the result to dst, copying
+// the tensor data unchanged. An existing key keeps its type unless typeName is given;
+// a new key is a string unless typeName says otherwise.
+func editGGUF(src, dst string, edits []ggufEdit, typeName string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	h, err := readGGUFHeader(in)
+	if err != nil {
+		return fmt.Errorf("%s: %w", src, err)
+	}
+
+	for _, e := range edits {
+		if e.Key == "general.alignment" {
+			return errors.New("general.alignment moves every tensor and cannot be edited")
+		}
+		if e.Unset {
+			if !h.unset(e.Key) {
+				return fmt.Errorf("%s has no key %s", src, e.Key)
+			}
+			continue
+		}
+		t := uint32(ggufTypeString)
+		if i := h.find(e.Key); i >= 0 {
+			t = h.KV[i].Type
+		}
+		if typeName != "" {
+			var ok bool
+			if t, ok = ggufTypeNames[typeName]; !ok {
+				return fmt.Errorf("unknown type %q", typeName)
+			}
+		}
+		raw, err := encodeGGUFValue(t, e.Value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", e.Key, err)
+		}
+		h.set(e.Key, t, raw)
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := h.encode(out); err != nil {
+		out.Close()
+		return err
+	}
+	if _, err := io.Copy(out, io.NewSectionReader(in, h.DataOffset, math.MaxInt64-h.DataOffset)); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// parseGGUFEdits reads KEY=VALUE arguments; KEY=@FILE takes the value from a file,
+// such as a chat template
+func parseGGUFEdits(args []string, unset []string) ([]ggufEdit, error) {
+	var edits []ggufEdit
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("expected KEY=VALUE, got %q", arg)
+		}
+		if file, found := strings.CutPrefix(value, "@"); found {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			value = string(data)
+		}
+		edits = append(edits, ggufEdit{Key: key, Value: value})
+	}
+	for _, key := range unset {
+		edits = append(edits, ggufEdit{Key: key, Unset: true})
+	}
+	if len(edits) == 0 {
+		return nil, errors.New("nothing to change")
+	}
+	return edits, nil
+}
+
+// setGGUFMetadata edits a file's metadata, either into a corrected copy at out or in
+// place, where the original is kept as FILE.bak. The file no longer has the digest it
+// was downloaded with, so its sidecar says so.
+func setGGUFMetadata(path, out string, edits []ggufEdit, typeName string) error {
+	var keys []string
+	for _, e := range edits {
+		keys = append(keys, e.Key)
+	}
+	note := "metadata edited with meta set (" + strings.Join(keys, ", ") + "), so it differs from the source file"
+
+	if out != "" {
+		if same, _ := sameFile(path, out); same {
+			return errors.New("-o names the file being edited; leave it out to edit in place")
+		}
+		if err := editGGUF(path, out, edits, typeName); err != nil {
+			os.Remove(out)
+			return err
+		}
+		markEdited(path, out, note)
+		fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] Wrote %s with the new metadata", out))
+		return nil
+	}
+
+	backup := path + ".bak"
+	if _, err := os.Stat(backup); err == nil {
+		return fmt.Errorf("%s already exists; remove it or write a copy with -o", backup)
+	}
+	if err := os.Rename(path, backup); err != nil {
+		return err
+	}
+	if err := editGGUF(backup, path, edits, typeName); err != nil {
+		os.Remove(path)
+		os.Rename(backup, path)
+		return err
+	}
+	markEdited(path, path, note)
+	fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] Updated %s; the original is at %s", path, backup))
+	return nil
+}
+
+// sameFile reports whether two paths name the same existing file
+func sameFile(a, b string) (bool, error) {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	bi, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	return os.SameFile(ai, bi), nil
+}
+
+// markEdited writes the sidecar of an edited file, based on the original's if it has one
+func markEdited(original, edited, note string) {
+	var sidecar Sidecar
+	data, err := os.ReadFile(sidecarPath(original))
+	if err != nil || json.Unmarshal(data, &sidecar) != nil {
+		return
+	}
+	sidecar.Digest = ""
+	sidecar.Notes = append(sidecar.Notes, note)
+	if info, err := os.Stat(edited); err == nil {
+		sidecar.Size = info.Size()
+	}
+	if err := writeSidecar(edited, &sidecar); err != nil {
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Could not update the sidecar: %s", err))
+	}
+}
+
+// getGGUFMetadata prints the values of keys in full, one per line, or every scalar
+// value when no keys are given
+func getGGUFMetadata(path string, keys []string) error {
+	wanted := make(map[string]bool)
+	for _, k := range keys {
+		wanted[k] = true
+	}
+	meta, err := openGGUFMetadata(path, func(key string) bool { return len(keys) == 0 || wanted[key] })
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if len(keys) == 0 {
+		printMetadata(meta)
+		return nil
+	}
+	for _, k := range keys {
+		v, ok := meta.KV[k]
+		if !ok {
+			return fmt.Errorf("%s has no key %s", path, k)
+		}
+		if values, isArray := v.([]any); isArray {
+			data, _ := json.Marshal(values)
+			fmt.Println(string(data))
+			continue
+		}
+		fmt.Println(v)
+	}
+	return nil
+}
+
+// runMetaGet is "meta get FILE [KEY]..."
+func runMetaGet(args []string) error {
+	if len(args) < 1 {
+		return errors.New("usage: meta get FILE [KEY]...")
+	}
+	return getGGUFMetadata(resolveFile(args[0]), args[1:])
+}
+
+// runMetaSet is "meta set [-o OUT] [-type TYPE] [-unset KEY] FILE KEY=VALUE..."
+func runMetaSet(args []string, out, typeName string, unset []string) error {
+	if len(args) < 1 {
+		return errors.New("usage: meta set [-o OUT] [-type TYPE] [-unset KEY] FILE KEY=VALUE...")
+	}
+	edits, err := parseGGUFEdits(args[1:], unset)
+	if err != nil {
+		return err
+	}
+	return setGGUFMetadata(resolveFile(args[0]), out, edits, typeName)
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// GGUF metadata value types
+const (
+	ggufTypeUint8 uint32 = iota
+	ggufTypeInt8
+	ggufTypeUint16
+	ggufTypeInt16
+	ggufTypeUint32
+	ggufTypeInt32
+	ggufTypeFloat32
+	ggufTypeBool
+	ggufTypeString
+	ggufTypeArray
+	ggufTypeUint64
+	ggufTypeInt64
+	ggufTypeFloat64
+)
+
+// ggufMagic opens every GGUF file
+var ggufMagic = []byte("GGUF")
+
+// maxGGUFString guards against corrupt lengths allocating gigabytes
+const maxGGUFString = 64 << 20
+
+// maxGGUFDims is GGML_MAX_DIMS, the most dimensions a tensor can have
+const maxGGUFDims = 4
+
+// GGUFMetadata is the header of a GGUF file
+type GGUFMetadata struct {
+	Version     uint32
+	TensorCount uint64
+	KV          map[string]any
+}
+
+// ggufReader decodes little-endian GGUF values
+type ggufReader struct {
+	r   *bufio.Reader
+	buf [8]byte
+}
+
+func (g *ggufReader) read(n int) ([]byte, error) {
+	if _, err := io.ReadFull(g.r, g.buf[:n]); err != nil {
+		return nil, err
+	}
+	return g.buf[:n], nil
+}
+
+func (g *ggufReader) uint32() (uint32, error) {
+	b, err := g.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+func (g *ggufReader) uint64() (uint64, error) {
+	b, err := g.read(8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+func (g *ggufReader) string() (string, error) {
+	n, err := g.uint64()
+	if err != nil {
+		return "", err
+	}
+	if n > maxGGUFString {
+		return "", fmt.Errorf("string of %d bytes exceeds the metadata limit", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(g.r, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// value decodes one value of type t; when keep is false the value is consumed and discarded
+func (g *ggufReader) value(t uint32, keep bool) (any, error) {
+	switch t {
+	case ggufTypeUint8, ggufTypeInt8, ggufTypeBool:
+		b, err := g.read(1)
+		if err != nil {
+			return nil, err
+		}
+		switch t {
+		case ggufTypeInt8:
+			return int64(int8(b[0])), nil
+		case ggufTypeBool:
+			return b[0] != 0, nil
+		}
+		return uint64(b[0]), nil
+	case ggufTypeUint16, ggufTypeInt16:
+		b, err := g.read(2)
+		if err != nil {
+			return nil, err
+		}
+		v := binary.LittleEndian.Uint16(b)
+		if t == ggufTypeInt16 {
+			return int64(int16(v)), nil
+		}
+		return uint64(v), nil
+	case ggufTypeUint32, ggufTypeInt32, ggufTypeFloat32:
+		v, err := g.uint32()
+		if err != nil {
+			return nil, err
+		}
+		switch t {
+		case ggufTypeInt32:
+			return int64(int32(v)), nil
+		case ggufTypeFloat32:
+			return float64(math.Float32frombits(v)), nil
+		}
+		return uint64(v), nil
+	case ggufTypeUint64, ggufTypeInt64, ggufTypeFloat64:
+		v, err := g.uint64()
+		if err != nil {
+			return nil, err
+		}
+		switch t {
+		case ggufTypeInt64:
+			return int64(v), nil
+		case ggufTypeFloat64:
+			return math.Float64frombits(v), nil
+		}
+		return v, nil
+	case ggufTypeString:
+		if !keep {
+			n, err := g.uint64()
+			if err != nil {
+				return nil, err
+			}
+			if n > maxGGUFString {
+				return nil, fmt.Errorf("string of %d bytes exceeds the metadata limit", n)
+			}
+			_, err = g.r.Discard(int(n))
+			return nil, err
+		}
+		return g.string()
+	case ggufTypeArray:
+		elemType, err := g.uint32()
+		if err != nil {
+			return nil, err
+		}
+		count, err := g.uint64()
+		if err != nil {
+			return nil, err
+		}
+		var values []any
+		if keep {
+			values = make([]any, 0, min(count, 1<<20))
+		}
+		for i := uint64(0); i < count; i++ {
+			v, err := g.value(elemType, keep)
+			if err != nil {
+				return nil, err
+			}
+			if keep {
+				values = append(values, v)
+			}
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("unknown metadata value type %d", t)
+	}
+}
+
+// readGGUFMetadata parses the header of a GGUF stream. Arrays and strings are only
+// kept for keys where keep returns true (nil keeps everything), so large tokenizer
+// tables can be skipped without holding them in memory.
+func readGGUFMetadata(r io.Reader, keep func(key string) bool) (*GGUFMetadata, error) {
+	return parseGGUFMetadata(r, func(key string, t uint32) bool {
+		return keep == nil || keep(key)
+	})
+}
+
+// parseGGUFMetadata is readGGUFMetadata with a keep callback that also sees each value's type
+func parseGGUFMetadata(r io.Reader, keep func(key string, t uint32) bool) (*GGUFMetadata, error) {
+	bufSize := 1 << 20
+	if lowMemory {
+		bufSize = 64 << 10
+	}
+	g := &ggufReader{r: bufio.NewReaderSize(r, bufSize)}
+	magic, err := g.read(4)
+	if err != nil {
+		return nil, err
+	}
+	if string(magic) != string(ggufMagic) {
+		return nil, errors.New("not a GGUF file")
+	}
+
+	meta := &GGUFMetadata{KV: make(map[string]any)}
+	if meta.Version, err = g.uint32(); err != nil {
+		return nil, err
+	}
+	if meta.Version < 2 {
+		return nil, fmt.Errorf("GGUF version %d is not supported", meta.Version)
+	}
+	if meta.TensorCount, err = g.uint64(); err != nil {
+		return nil, err
+	}
+	kvCount, err := g.uint64()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := uint64(0); i < kvCount; i++ {
+		key, err := g.string()
+		if err != nil {
+			return nil, fmt.Errorf("metadata entry %d: %w", i, err)
+		}
+		t, err := g.uint32()
+		if err != nil {
+			return nil, fmt.Errorf("metadata %s: %w", key, err)
+		}
+		keepValue := keep(key, t)
+		v, err := g.value(t, keepValue)
+		if err != nil {
+			return nil, fmt.Errorf("metadata %s: %w", key, err)
+		}
+		if keepValue || (t != ggufTypeArray && t != ggufTypeString) {
+			meta.KV[key] = v
+		}
+	}
+	return meta, nil
+}
+
+// String returns a string value, or "" if the key is missing
+func (m *GGUFMetadata) String(key string) string {
+	s, _ := m.KV[key].(string)
+	return s
+}
+
+// Int returns an integer value
+func (m *GGUFMetadata) Int(key string) (int64, bool) {
+	switch v := m.KV[key].(type) {
+	case uint64:
+		return int64(v), true
+	case int64:
+		return v, true
+	}
+	return 0, false
+}
+
+// Strings returns a string array value
+func (m *GGUFMetadata) Strings(key string) []string {
+	values, _ := m.KV[key].([]any)
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		s, _ := v.(string)
+		out = append(out, s)
+	}
+	return out
+}
+
+// Ints returns an integer array value
+func (m *GGUFMetadata) Ints(key string) []int64 {
+	values, _ := m.KV[key].([]any)
+	out := make([]int64, 0, len(values))
+	for _, v := range values {
+		switch n := v.(type) {
+		case uint64:
+			out = append(out, int64(n))
+		case int64:
+			out = append(out, n)
+		}
+	}
+	return out
+}
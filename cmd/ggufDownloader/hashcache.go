@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// rehash bypasses the checksum cache, forcing every file to be read again
+var rehash bool
+
+// hashCacheEntry identifies the version of a file a digest was computed for
+type hashCacheEntry struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime"`
+	Inode   uint64 `json:"inode,omitempty"`
+	Digest  string `json:"digest"`
+}
+
+// hashCacheMu serializes cache updates from parallel verifications
+var hashCacheMu sync.Mutex
+
+// hashCachePath returns the location of the checksum cache, shared by every project
+func hashCachePath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "hashes.json"), nil
+}
+
+// readHashCache loads the checksum cache, treating a missing or unreadable file as empty
+func readHashCache(path string) map[string]hashCacheEntry {
+	cache := make(map[string]hashCacheEntry)
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &cache)
+	}
+	return cache
+}
+
+// hashCacheKey describes the on-disk version of a file
+func hashCacheKey(info os.FileInfo) hashCacheEntry {
+	return hashCacheEntry{Size: info.Size(), ModTime: info.ModTime().UnixNano(), Inode: fileInode(info)}
+}
+
+// cachedDigest returns the digest recorded for path if the file has not changed since
+func cachedDigest(path string, info os.FileInfo) (string, bool) {
+	cachePath, err := hashCachePath()
+	if err != nil {
+		return "", false
+	}
+	hashCacheMu.Lock()
+	defer hashCacheMu.Unlock()
+
+	entry, ok := readHashCache(cachePath)[path]
+	key := hashCacheKey(info)
+	if !ok || entry.Size != key.Size || entry.ModTime != key.ModTime || entry.Inode != key.Inode {
+		return "", false
+	}
+	return entry.Digest, true
+}
+
+// storeDigest records the digest of path, dropping entries for files that no longer exist
+func storeDigest(path string, info os.FileInfo, digest string) error {
+	cachePath, err := hashCachePath()
+	if err != nil {
+		return err
+	}
+	hashCacheMu.Lock()
+	defer hashCacheMu.Unlock()
+
+	// Re-read so entries written by other instances in the meantime are kept
+	cache := readHashCache(cachePath)
+	for p := range cache {
+		if _, err := os.Stat(p); os.IsNotExist(err) {
+			delete(cache, p)
+		}
+	}
+	entry := hashCacheKey(info)
+	entry.Digest = digest
+	cache[path] = entry
+	return writeJSONFile(cachePath, cache)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// defaultStallTimeout is how long a running job may go without receiving a byte
+// before the daemon reports itself unhealthy
+const defaultStallTimeout = 10 * time.Minute
+
+// healthFileInterval is how often -health-file is refreshed while the daemon is healthy
+const healthFileInterval = 15 * time.Second
+
+// lastTransfer is when any response body last delivered data, in Unix nanoseconds
+var lastTransfer atomic.Int64
+
+// activityBody records in lastTransfer that a response is still delivering data
+type activityBody struct {
+	io.ReadCloser
+}
+
+func (b activityBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		lastTransfer.Store(time.Now().UnixNano())
+	}
+	return n, err
+}
+
+// healthStatus is the body of /healthz and /readyz and the content of -health-file
+type healthStatus struct {
+	Status       string     `json:"status"`
+	Detail       string     `json:"detail,omitempty"`
+	RunningJob   string     `json:"running_job,omitempty"`
+	RunningSince *time.Time `json:"running_since,omitempty"`
+	LastTransfer *time.Time `json:"last_transfer,omitempty"`
+	Queued       int        `json:"queued"`
+	CheckedAt    time.Time  `json:"checked_at"`
+}
+
+// health reports whether the worker is making progress: a running job that has not
+// received a byte for stallTimeout means a transfer is wedged
+func (m *jobManager) health(stallTimeout time.Duration) (healthStatus, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	status := healthStatus{Status: "ok", CheckedAt: now.UTC()}
+	for _, job := range m.jobs {
+		if job.State == JobQueued {
+			status.Queued++
+		}
+	}
+	if last := lastTransfer.Load(); last != 0 {
+		t := time.Unix(0, last).UTC()
+		status.LastTransfer = &t
+	}
+	if m.running == "" {
+		return status, true
+	}
+
+	since := m.runningSince.UTC()
+	status.RunningJob, status.RunningSince = m.running, &since
+	active := m.runningSince
+	if status.LastTransfer != nil && status.LastTransfer.After(active) {
+		active = *status.LastTransfer
+	}
+	if stallTimeout > 0 && now.Sub(active) > stallTimeout {
+		status.Status = "stalled"
+		status.Detail = fmt.Sprintf("job %s has received nothing for %s", m.running, now.Sub(active).Round(time.Second))
+		return status, false
+	}
+	return status, true
+}
+
+// ready reports whether the daemon can accept jobs: its job list could be persisted
+func (m *jobManager) ready() (healthStatus, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := healthStatus{Status: "ready", CheckedAt: time.Now().UTC()}
+	if m.saveErr != nil {
+		status.Status = "not ready"
+		status.Detail = "cannot persist jobs: " + m.saveErr.Error()
+		return status, false
+	}
+	return status, true
+}
+
+// healthHandler serves a health check, answering 503 while it fails
+func healthHandler(check func() (healthStatus, bool)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status, ok := check()
+		code := http.StatusOK
+		if !ok {
+			code = http.StatusServiceUnavailable
+		}
+		writeJSON(w, code, status)
+	}
+}
+
+// writeHealthFile keeps path fresh while the daemon is healthy and removes it when it is
+// not, for supervisors that check a file's age instead of calling /healthz
+func (m *jobManager) writeHealthFile(path string, stallTimeout time.Duration) {
+	healthy := true
+	for {
+		status, ok := m.health(stallTimeout)
+		if notReady, ready := m.ready(); !ready {
+			status, ok = notReady, false
+		}
+		if ok {
+			if err := writeJSONFile(path, status); err != nil {
+				fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Could not write health file: %s", err))
+			}
+		} else {
+			os.Remove(path)
+			if healthy {
+				fmt.Fprintln(os.Stderr, color.RedString("[ERROR] Unhealthy: %s", status.Detail))
+			}
+		}
+		healthy = ok
+		time.Sleep(healthFileInterval)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+
+	"ggufDownloader/pkg/ollamareg"
+)
+
+// hfToken is sent as a bearer token to Hugging Face, from HF_TOKEN like the Hugging
+// Face tools read it, so gated and private repositories can be listed and downloaded
+var hfToken = os.Getenv("HF_TOKEN")
+
+// modelSources are the accepted values of -source
+var modelSources = []string{"ollama", "hf"}
+
+// listHFGGUFs returns the GGUF files of a Hugging Face repository
+func listHFGGUFs(ctx context.Context, repo string) ([]*hfFile, error) {
+	var info struct {
+		Siblings []struct {
+			Name string `json:"rfilename"`
+			Size int64  `json:"size"`
+			LFS  *struct {
+				SHA256 string `json:"sha256"`
+				Size   int64  `json:"size"`
+			} `json:"lfs"`
+		} `json:"siblings"`
+	}
+	if err := hfGetJSON(ctx, fmt.Sprintf("https://%s/api/models/%s?blobs=true", HuggingFaceHost, repo), &info); err != nil {
+		return nil, fmt.Errorf("%s: %w", repo, err)
+	}
+
+	var files []*hfFile
+	for _, s := range info.Siblings {
+		if !strings.EqualFold(path.Ext(s.Name), ".gguf") {
+			continue
+		}
+		file := &hfFile{Repo: repo, Name: s.Name, Size: s.Size}
+		if s.LFS != nil {
+			file.SHA256, file.Size = s.LFS.SHA256, s.LFS.Size
+		}
+		files = append(files, file)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s has no GGUF files", repo)
+	}
+	return files, nil
+}
+
+// chooseHFFile picks the file named name from a repository's GGUF files. A name that
+// matches no file exactly may be a quantization such as Q4_K_M, which picks the one
+// file carrying it.
+func chooseHFFile(files []*hfFile, name string) (*hfFile, error) {
+	for _, f := range files {
+		if f.Name == name || path.Base(f.Name) == name {
+			return f, nil
+		}
+	}
+	var matches []*hfFile
+	for _, f := range files {
+		if strings.EqualFold(path.Base(f.Name), name) || strings.EqualFold(fileQuant(f.Name), name) {
+			matches = append(matches, f)
+		}
+	}
+	switch len(matches) {
+	case 1:
+		return matches[0], nil
+	case 0:
+		return nil, fmt.Errorf("%s has no GGUF file %q; run without -file to list them", files[0].Repo, name)
+	}
+	names := make([]string, len(matches))
+	for i, f := range matches {
+		names[i] = f.Name
+	}
+	return nil, fmt.Errorf("%q matches %d files of %s: %s", name, len(matches), files[0].Repo, strings.Join(names, ", "))
+}
+
+// printHFGGUFs lists the GGUF files of a repository with their sizes and quantizations
+func printHFGGUFs(files []*hfFile) {
+	fmt.Println(color.CyanString("%-60s%-12s%s", "FILE", "SIZE", "QUANT"))
+	for _, f := range files {
+		fmt.Printf("%s%s%s\n", color.GreenString("%-60s", f.Name), color.YellowString("%-12s", formatBytes(f.Size)), fileQuant(f.Name))
+	}
+}
+
+// listHFCommand prints the GGUF files of a Hugging Face repository, the values -file accepts
+func listHFCommand(repo string) error {
+	files, err := listHFGGUFs(context.Background(), repo)
+	if err != nil {
+		return err
+	}
+	printHFGGUFs(files)
+	return nil
+}
+
+// pullHuggingFace downloads one GGUF file of a Hugging Face repository through the
+// same FILE.part, resume, blob cache, verification and scan steps as registry blobs,
+// returning where it was saved
+func pullHuggingFace(ctx context.Context, repo, name string, opts PullOptions) (string, error) {
+	switch {
+	case opts.Output != "":
+		return "", errors.New("-source hf cannot be combined with -output")
+	case opts.Transform != nil:
+		return "", errors.New("-source hf cannot be combined with -transform")
+	case opts.Full || opts.Modelfile || opts.Install:
+		return "", errors.New("-full, -modelfile and -install need an Ollama manifest, which -source hf has none of")
+	}
+	files, err := listHFGGUFs(ctx, repo)
+	if err != nil {
+		return "", err
+	}
+	file, err := chooseHFFile(files, name)
+	if err != nil {
+		return "", err
+	}
+	if splitSuffix.MatchString(strings.TrimSuffix(path.Base(file.Name), path.Ext(file.Name))) {
+		return "", fmt.Errorf("%s is one part of a split GGUF, which -source hf does not join; choose a single-file quantization", file.Name)
+	}
+
+	filename := opts.OutputFile
+	if filename == "" {
+		if filename, err = outputPath(path.Base(file.Name)); err != nil {
+			return "", err
+		}
+	}
+	if _, err := os.Stat(filename); err == nil {
+		switch opts.IfExists {
+		case "skip":
+			fmt.Fprintln(os.Stderr, color.YellowString("[WARN] %s already exists, skipping", filename))
+			return filename, nil
+		case "rename":
+			filename = renamedPath(filename)
+		}
+	}
+	scanHook, err := scanHookFor(opts)
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Fprintln(os.Stderr, color.CyanString("[INFO] %s from %s is %s", file.Name, repo, formatBytes(file.Size)))
+	ctx = withCopies(ctx, opts.CopyTo)
+	if err := pullFile(ctx, file.URL(), file.Digest(), filename, "Hugging Face file", ollamareg.CheckGGUF, opts, scanHook); err != nil {
+		return "", err
+	}
+
+	if opts.RegisterAs != "" {
+		digest := file.Digest()
+		if digest == "" {
+			if digest, err = fileDigest(filename); err != nil {
+				return "", err
+			}
+		}
+		if err := registerWithOllama(ctx, filename, digest, opts.RegisterAs); err != nil {
+			return "", fmt.Errorf("downloaded %s but registering with Ollama failed: %w", filename, err)
+		}
+		fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] Registered with Ollama as %s", opts.RegisterAs))
+	}
+	if err := recordPull(repo, file.Name, file.Digest(), filename, false, file); err != nil {
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Could not update download ledger: %s", err))
+	}
+	sidecar := &Sidecar{Model: repo, Tag: file.Name, Digest: file.Digest(), Size: file.Size, Source: file.URL(), DownloadedAt: time.Now()}
+	if info, err := os.Stat(filename); err == nil {
+		sidecar.Size = info.Size()
+	}
+	if err := writeSidecar(filename, sidecar); err != nil {
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Could not write sidecar: %s", err))
+	}
+	return filepath.Clean(filename), nil
+}
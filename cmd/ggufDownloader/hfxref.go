@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// hfCrossRefTTL is how long the Hugging Face repositories found for a model are reused
+const hfCrossRefTTL = 24 * time.Hour
+
+// hfCrossRefRepos is how many Hugging Face repositories are shown for a model
+const hfCrossRefRepos = 3
+
+// hfRepoQuants is a Hugging Face repository holding GGUF builds of a model
+type hfRepoQuants struct {
+	Repo      string   `json:"repo"`
+	Downloads int      `json:"downloads"`
+	Quants    []string `json:"quants"`
+}
+
+// URL returns the repository's page
+func (r hfRepoQuants) URL() string {
+	return fmt.Sprintf("https://%s/%s", HuggingFaceHost, r.Repo)
+}
+
+// quantSuffix matches the quantization at the end of a GGUF file name, e.g. ".Q4_K_M"
+var quantSuffix = regexp.MustCompile(`(?i)[-_.](i?q[1-8](?:_[a-z0-9]+)*|bf16|f16|f32)$`)
+
+// splitSuffix matches the part number of a split GGUF file, "-00001-of-00003"
+var splitSuffix = regexp.MustCompile(`-\d{5}-of-\d{5}$`)
+
+// fileQuant returns the quantization a GGUF file name ends with, or "" if it names none
+func fileQuant(name string) string {
+	name = path.Base(name)
+	if strings.EqualFold(path.Ext(name), ".gguf") {
+		name = name[:len(name)-len(".gguf")]
+	}
+	name = splitSuffix.ReplaceAllString(name, "")
+	m := quantSuffix.FindStringSubmatch(name)
+	if m == nil {
+		return ""
+	}
+	return strings.ToUpper(m[1])
+}
+
+// normalizeRepoName reduces a name to lowercase letters and digits, so "llama3" matches
+// "Meta-Llama-3-8B-Instruct-GGUF"
+func normalizeRepoName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, strings.ToLower(s))
+}
+
+// crossReferenceHF finds the most downloaded Hugging Face repositories with GGUF files
+// of modelName, and which quantizations each of them offers
+func crossReferenceHF(ctx context.Context, modelName string) ([]hfRepoQuants, error) {
+	var repos []hfRepoQuants
+	if loadMetadata("hf-xref", modelName, hfCrossRefTTL, &repos) {
+		return repos, nil
+	}
+
+	base := path.Base(modelName)
+	var found []struct {
+		ID        string `json:"id"`
+		Downloads int    `json:"downloads"`
+	}
+	searchURL := fmt.Sprintf("https://%s/api/models?search=%s&filter=gguf&sort=downloads&direction=-1&limit=20",
+		HuggingFaceHost, url.QueryEscape(base))
+	if err := hfGetJSON(ctx, searchURL, &found); err != nil {
+		return nil, err
+	}
+
+	want := normalizeRepoName(base)
+	for _, repo := range found {
+		if len(repos) == hfCrossRefRepos {
+			break
+		}
+		if !strings.Contains(normalizeRepoName(path.Base(repo.ID)), want) {
+			continue
+		}
+		var info struct {
+			Siblings []struct {
+				Name string `json:"rfilename"`
+			} `json:"siblings"`
+		}
+		if err := hfGetJSON(ctx, fmt.Sprintf("https://%s/api/models/%s", HuggingFaceHost, repo.ID), &info); err != nil {
+			continue
+		}
+		quants := map[string]bool{}
+		for _, s := range info.Siblings {
+			// Vision projectors ship next to the weights but are not a build of the model
+			name := strings.ToLower(path.Base(s.Name))
+			if strings.HasSuffix(name, ".gguf") && !strings.HasPrefix(name, "mmproj") {
+				if q := fileQuant(s.Name); q != "" {
+					quants[q] = true
+				}
+			}
+		}
+		if len(quants) == 0 {
+			continue
+		}
+		entry := hfRepoQuants{Repo: repo.ID, Downloads: repo.Downloads}
+		for q := range quants {
+			entry.Quants = append(entry.Quants, q)
+		}
+		sort.Strings(entry.Quants)
+		repos = append(repos, entry)
+	}
+	storeMetadata("hf-xref", modelName, repos)
+	return repos, nil
+}
+
+// printHFCrossReference lists the Hugging Face repositories of a model; when tagQuants,
+// the quantizations Ollama publishes, are known, the ones only Hugging Face has are starred
+func printHFCrossReference(ctx context.Context, w io.Writer, modelName string, tagQuants map[string]bool) {
+	repos, err := crossReferenceHF(ctx, modelName)
+	switch {
+	case err != nil:
+		fmt.Fprintf(w, "  %s\n", color.YellowString("Hugging Face lookup failed: %s", err))
+		return
+	case len(repos) == 0:
+		fmt.Fprintf(w, "  %s\n", color.YellowString("No GGUF repository found on Hugging Face"))
+		return
+	}
+	for _, repo := range repos {
+		fmt.Fprintf(w, "  %s (%d downloads)\n", color.CyanString(repo.URL()), repo.Downloads)
+		quants := make([]string, len(repo.Quants))
+		for i, q := range repo.Quants {
+			quants[i] = q
+			if tagQuants != nil && !tagQuants[q] {
+				quants[i] = color.GreenString(q + "*")
+			}
+		}
+		fmt.Fprintf(w, "    %s\n", strings.Join(quants, " "))
+	}
+}
+
+// tagQuantizations returns the quantizations named by a model's tags, e.g. Q4_K_M
+// from "8b-instruct-q4_K_M"
+func tagQuantizations(tags []string) map[string]bool {
+	quants := map[string]bool{}
+	for _, tag := range tags {
+		if q := fileQuant(tag); q != "" {
+			quants[q] = true
+		}
+	}
+	return quants
+}
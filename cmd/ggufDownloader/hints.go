@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/fatih/color"
+)
+
+// Hardware is the machine a model will run on, probed or given on the command line
+type Hardware struct {
+	Cores int
+	RAM   int64
+	VRAM  int64
+}
+
+// memoryOverhead accounts for the KV cache and runtime buffers on top of the weights
+const memoryOverhead = 1.2
+
+// Rough memory bandwidths used to estimate generation speed, which is bandwidth bound
+const (
+	gpuBandwidth        = 400e9
+	cpuBandwidthPerCore = 6e9
+	maxCPUBandwidth     = 80e9
+)
+
+// hardwareFlags are the flags that describe or override the probed hardware
+type hardwareFlags struct {
+	ram  *string
+	vram *string
+}
+
+// addHardwareFlags registers the hardware override flags
+func addHardwareFlags(fs *flag.FlagSet) *hardwareFlags {
+	return &hardwareFlags{
+		ram:  fs.String("ram", "", "System memory to plan for (e.g., 32G); detected when empty"),
+		vram: fs.String("vram", "", "GPU memory available for offloading (e.g., 24G); no GPU is assumed when empty"),
+	}
+}
+
+// probe detects the local hardware, applying any overrides
+func (h *hardwareFlags) probe() (Hardware, error) {
+	hw := Hardware{Cores: runtime.NumCPU()}
+	var err error
+	if *h.ram != "" {
+		if hw.RAM, err = parseSize(*h.ram); err != nil {
+			return hw, err
+		}
+	} else if hw.RAM, err = totalMemory(); err != nil {
+		return hw, err
+	}
+	if *h.vram != "" {
+		if hw.VRAM, err = parseSize(*h.vram); err != nil {
+			return hw, err
+		}
+	}
+	return hw, nil
+}
+
+// Suitability is a rough rating of how well a model of a given size runs on some hardware
+type Suitability struct {
+	Fit          string
+	TokensPerSec float64
+}
+
+// speedClass buckets an estimated generation speed
+func (s Suitability) speedClass() string {
+	switch {
+	case s.TokensPerSec == 0:
+		return "-"
+	case s.TokensPerSec >= 20:
+		return "fast"
+	case s.TokensPerSec >= 8:
+		return "interactive"
+	case s.TokensPerSec >= 2:
+		return "slow"
+	default:
+		return "very slow"
+	}
+}
+
+// rateModel estimates where a model of size bytes fits and how fast it generates
+func rateModel(size int64, hw Hardware) Suitability {
+	need := float64(size) * memoryOverhead
+	cpuBandwidth := float64(hw.Cores) * cpuBandwidthPerCore
+	if cpuBandwidth > maxCPUBandwidth {
+		cpuBandwidth = maxCPUBandwidth
+	}
+
+	switch {
+	case hw.VRAM > 0 && need <= float64(hw.VRAM):
+		return Suitability{"GPU", gpuBandwidth / float64(size)}
+	case hw.VRAM > 0 && need <= float64(hw.VRAM+hw.RAM):
+		// Generation time is dominated by the part of the weights left in system memory
+		onCPU := float64(size) - float64(hw.VRAM)/memoryOverhead
+		return Suitability{"GPU+CPU", 1 / (onCPU/cpuBandwidth + (float64(size)-onCPU)/gpuBandwidth)}
+	case need <= float64(hw.RAM):
+		return Suitability{"CPU", cpuBandwidth / float64(size)}
+	default:
+		return Suitability{"too large", 0}
+	}
+}
+
+// printHardware prints the hardware the hints are based on
+func printHardware(hw Hardware) {
+	vram := "none"
+	if hw.VRAM > 0 {
+		vram = formatBytes(hw.VRAM)
+	}
+	fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Hardware: %d cores, %s RAM, %s GPU memory", hw.Cores, formatBytes(hw.RAM), vram))
+}
+
+// tagInfo returns the size of the weights published under model:tag, with their
+// parameter size and quantization as the config layer states them; the tag's own
+// label is only a fallback, since "latest" or "8b" leave the quantization unsaid
+func tagInfo(ctx context.Context, modelName, tag string) (size int64, params, quant string, err error) {
+	manifest, err := fetchManifest(ctx, modelName, tag)
+	if err != nil {
+		return 0, "", "", err
+	}
+	layer := manifest.ModelLayer()
+	if layer == nil {
+		return 0, "", "", fmt.Errorf("%s:%s has no model layer", modelName, tag)
+	}
+	params, quant = "-", fileQuant(tag)
+	if config, err := fetchModelConfig(ctx, modelName, manifest); err == nil {
+		if config.Parameters() != "" {
+			params = config.Parameters()
+		}
+		if config.Quantization() != "" {
+			quant = config.Quantization()
+		}
+	}
+	if quant == "" {
+		quant = "-"
+	}
+	return layer.Size, params, quant, nil
+}
+
+// printTagSizes prints the size, parameter size and quantization of every tag, so a
+// tag can be chosen without guessing what its name stands for
+func printTagSizes(ctx context.Context, modelName string, tags []string) {
+	fmt.Println(color.CyanString("%-30s%-12s%-9s%s", "TAG", "SIZE", "PARAMS", "QUANT"))
+	for _, tag := range tags {
+		size, params, quant, err := tagInfo(ctx, modelName, tag)
+		if err != nil {
+			fmt.Println(color.YellowString("%-30s%s", tag, err))
+			continue
+		}
+		fmt.Printf("%s%s%-9s%s\n", color.GreenString("%-30s", tag), color.YellowString("%-12s", formatBytes(size)), params, quant)
+	}
+}
+
+// printTagHints prints the size, fit and speed class of every tag
+func printTagHints(ctx context.Context, modelName string, tags []string, hw Hardware) {
+	printHardware(hw)
+	fmt.Println()
+	fmt.Println(color.CyanString("%-30s%-12s%-9s%-10s%-12s%s", "TAG", "SIZE", "PARAMS", "QUANT", "FITS", "SPEED"))
+	for _, tag := range tags {
+		size, params, quant, err := tagInfo(ctx, modelName, tag)
+		if err != nil {
+			fmt.Println(color.YellowString("%-30s%s", tag, err))
+			continue
+		}
+		s := rateModel(size, hw)
+		speed := s.speedClass()
+		if s.TokensPerSec > 0 {
+			speed = fmt.Sprintf("%s (~%.0f tok/s)", speed, s.TokensPerSec)
+		}
+		fit := color.GreenString("%-12s", s.Fit)
+		if s.Fit == "too large" {
+			fit = color.RedString("%-12s", s.Fit)
+		}
+		fmt.Printf("%s%s%-9s%-10s%s%s\n", color.GreenString("%-30s", tag), color.YellowString("%-12s", formatBytes(size)), params, quant, fit, speed)
+	}
+	fmt.Println(color.WhiteString("\nEstimates assume generation is memory-bandwidth bound; treat them as a rough guide."))
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// HuggingFaceHost serves the fallback GGUF files
+const HuggingFaceHost = "huggingface.co"
+
+// hfFile is a GGUF file in a Hugging Face repository
+type hfFile struct {
+	Repo   string
+	Name   string
+	SHA256 string
+	Size   int64
+}
+
+// URL returns the download URL of the file
+func (f *hfFile) URL() string {
+	return fmt.Sprintf("https://%s/%s/resolve/main/%s", HuggingFaceHost, f.Repo, f.Name)
+}
+
+// parseHFURL splits a file URL made by hfFile.URL into its repository and file name
+func parseHFURL(url string) (repo, name string, ok bool) {
+	rest, ok := strings.CutPrefix(url, "https://"+HuggingFaceHost+"/")
+	if !ok {
+		return "", "", false
+	}
+	repo, name, ok = strings.Cut(rest, "/resolve/main/")
+	return repo, name, ok && repo != "" && name != ""
+}
+
+// Digest returns the "sha256:<hex>" digest Hugging Face publishes for the file, if any
+func (f *hfFile) Digest() string {
+	if f.SHA256 == "" {
+		return ""
+	}
+	return "sha256:" + f.SHA256
+}
+
+// blobUnavailable reports whether a download failed because the registry could not serve the blob
+func blobUnavailable(err error) bool {
+	var status *StatusError
+	if !errors.As(err, &status) {
+		return false
+	}
+	switch status.StatusCode {
+	case http.StatusNotFound, http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	}
+	return false
+}
+
+// hfGetJSON decodes a Hugging Face API response into v
+func hfGetJSON(ctx context.Context, apiURL string, v any) error {
+	resp, err := httpGet(ctx, apiURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		if (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) && hfToken == "" {
+			return errors.New("Hugging Face API request failed: " + resp.Status + "; gated and private repositories need a token in HF_TOKEN")
+		}
+		return errors.New("Hugging Face API request failed: " + resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// paramSize turns a model type such as "8.0B" into the "8b" used in file names
+func paramSize(modelType string) string {
+	s := strings.ToLower(strings.TrimSpace(modelType))
+	return strings.Replace(s, ".0b", "b", 1)
+}
+
+// containsToken reports whether name contains token as a whole component, so
+// "Q4_K" does not match "Q4_K_M"
+func containsToken(name, token string) bool {
+	pattern := `(^|[^a-z0-9_])` + regexp.QuoteMeta(strings.ToLower(token)) + `($|[^a-z0-9_])`
+	return regexp.MustCompile(pattern).MatchString(strings.ToLower(name))
+}
+
+// findHuggingFaceGGUF searches Hugging Face for a GGUF of the same model, parameter
+// size and quantization as the Ollama tag
+func findHuggingFaceGGUF(ctx context.Context, modelName string, config *ModelConfig) (*hfFile, error) {
+	if config == nil || config.Quantization() == "" {
+		return nil, errors.New("the model's quantization is unknown, so no equivalent file can be chosen")
+	}
+	size := paramSize(config.Parameters())
+
+	queries := []string{modelName}
+	if family := config.Family(); family != "" && family != modelName {
+		queries = append(queries, family)
+	}
+	for _, query := range queries {
+		var repos []struct {
+			ID string `json:"id"`
+		}
+		searchURL := fmt.Sprintf("https://%s/api/models?search=%s&filter=gguf&sort=downloads&direction=-1&limit=20",
+			HuggingFaceHost, url.QueryEscape(query))
+		if err := hfGetJSON(ctx, searchURL, &repos); err != nil {
+			return nil, err
+		}
+
+		for _, repo := range repos {
+			var info struct {
+				Siblings []struct {
+					Name string `json:"rfilename"`
+					LFS  *struct {
+						SHA256 string `json:"sha256"`
+						Size   int64  `json:"size"`
+					} `json:"lfs"`
+				} `json:"siblings"`
+			}
+			if err := hfGetJSON(ctx, fmt.Sprintf("https://%s/api/models/%s?blobs=true", HuggingFaceHost, repo.ID), &info); err != nil {
+				continue
+			}
+			for _, s := range info.Siblings {
+				name := strings.ToLower(s.Name)
+				if !strings.HasSuffix(name, ".gguf") || strings.Contains(name, "-of-") {
+					continue
+				}
+				if !containsToken(s.Name, config.Quantization()) {
+					continue
+				}
+				if size != "" && !strings.Contains(name, size) && !strings.Contains(strings.ToLower(repo.ID), size) {
+					continue
+				}
+				file := &hfFile{Repo: repo.ID, Name: s.Name}
+				if s.LFS != nil {
+					file.SHA256, file.Size = s.LFS.SHA256, s.LFS.Size
+				}
+				return file, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no %s %s GGUF of %s found on Hugging Face", size, config.Quantization(), modelName)
+}
+
+// confirm asks a yes/no question on the terminal; without a terminal the opt-in flag counts as yes
+func confirm(question string) bool {
+	if !interactive() {
+		return true
+	}
+	fmt.Fprint(os.Stderr, color.YellowString("%s [y/N] ", question))
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// fallbackToHuggingFace downloads an equivalent GGUF from Hugging Face into filename
+func fallbackToHuggingFace(ctx context.Context, modelName string, config *ModelConfig, filename string, transform StreamTransformer) (*hfFile, error) {
+	file, err := findHuggingFaceGGUF(ctx, modelName, config)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Found %s in %s (%s)", file.Name, file.Repo, formatBytes(file.Size)))
+	if !confirm("Download it from Hugging Face instead? It is a different build than the Ollama blob.") {
+		return nil, errors.New("declined")
+	}
+
+	if err := downloadFile(ctx, file.URL(), filename, transform, 0); err != nil {
+		return nil, err
+	}
+	if transform == nil && file.Digest() != "" {
+		if err := verifyFile(filename, file.Digest()); err != nil {
+			return nil, err
+		}
+	}
+	return file, nil
+}
@@ -0,0 +1,14 @@
+//go:build darwin
+
+package main
+
+import "golang.org/x/sys/unix"
+
+// totalMemory returns the installed RAM in bytes
+func totalMemory() (int64, error) {
+	n, err := unix.SysctlUint64("hw.memsize")
+	if err != nil {
+		return 0, err
+	}
+	return int64(n), nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !windows
+
+package main
+
+import "errors"
+
+// totalMemory is not implemented on this platform
+func totalMemory() (int64, error) {
+	return 0, errors.New("memory detection is not supported on this platform; pass -ram")
+}
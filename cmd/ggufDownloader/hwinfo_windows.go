@@ -0,0 +1,34 @@
+//go:build windows
+
+package main
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// memoryStatusEx mirrors the Win32 MEMORYSTATUSEX structure
+type memoryStatusEx struct {
+	Length               uint32
+	MemoryLoad           uint32
+	TotalPhys            uint64
+	AvailPhys            uint64
+	TotalPageFile        uint64
+	AvailPageFile        uint64
+	TotalVirtual         uint64
+	AvailVirtual         uint64
+	AvailExtendedVirtual uint64
+}
+
+var procGlobalMemoryStatusEx = windows.NewLazySystemDLL("kernel32.dll").NewProc("GlobalMemoryStatusEx")
+
+// totalMemory returns the installed RAM in bytes
+func totalMemory() (int64, error) {
+	status := memoryStatusEx{}
+	status.Length = uint32(unsafe.Sizeof(status))
+	if ok, _, err := procGlobalMemoryStatusEx.Call(uintptr(unsafe.Pointer(&status))); ok == 0 {
+		return 0, err
+	}
+	return int64(status.TotalPhys), nil
+}
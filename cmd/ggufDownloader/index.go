@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// indexFilename is the default name of the index written into the watched directory
+const indexFilename = "index.json"
+
+// IndexEntry describes one model file in a directory index
+type IndexEntry struct {
+	Name     string         `json:"name"`
+	Path     string         `json:"path"`
+	Digest   string         `json:"digest"`
+	Size     int64          `json:"size"`
+	ModTime  time.Time      `json:"mod_time"`
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+// ModelIndex is the index.json consumed by launchers and front-ends
+type ModelIndex struct {
+	UpdatedAt time.Time    `json:"updated_at"`
+	Models    []IndexEntry `json:"models"`
+}
+
+// indexedMetadataKeys are the GGUF header fields copied into the index; keys ending in a
+// dot are prefixed with the model's architecture
+var indexedMetadataKeys = []string{
+	"general.architecture",
+	"general.name",
+	"general.file_type",
+	"general.parameter_count",
+	".context_length",
+	".embedding_length",
+	".block_count",
+}
+
+// indexMetadata reads the small set of GGUF header fields worth indexing
+func indexMetadata(path string) map[string]any {
+	meta, err := openGGUFMetadata(path, func(key string) bool {
+		return !strings.HasPrefix(key, "tokenizer.")
+	})
+	if err != nil {
+		return nil
+	}
+	arch := meta.String("general.architecture")
+	out := make(map[string]any)
+	for _, key := range indexedMetadataKeys {
+		if strings.HasPrefix(key, ".") {
+			key = arch + key
+		}
+		if v, ok := meta.KV[key]; ok {
+			out[strings.TrimPrefix(strings.TrimPrefix(key, arch+"."), "general.")] = v
+		}
+	}
+	return out
+}
+
+// indexEntry builds the entry for a model file, reusing prev when the file is unchanged
+func indexEntry(path string, info os.FileInfo, prev *IndexEntry) (IndexEntry, error) {
+	if prev != nil && prev.Size == info.Size() && prev.ModTime.Equal(info.ModTime()) {
+		return *prev, nil
+	}
+
+	entry := IndexEntry{
+		Name:    strings.TrimSuffix(filepath.Base(path), ".gguf"),
+		Path:    path,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}
+	// A sidecar written by pull saves hashing gigabytes again
+	var sidecar Sidecar
+	if data, err := os.ReadFile(sidecarPath(path)); err == nil && json.Unmarshal(data, &sidecar) == nil && sidecar.Size == info.Size() {
+		entry.Digest = sidecar.Digest
+	}
+	if entry.Digest == "" {
+		digest, err := fileDigest(path)
+		if err != nil {
+			return entry, err
+		}
+		entry.Digest = digest
+	}
+	entry.Metadata = indexMetadata(path)
+	return entry, nil
+}
+
+// modelIndexer keeps the index of one directory up to date
+type modelIndexer struct {
+	dir     string
+	out     string
+	entries map[string]IndexEntry
+	// pending holds files seen changing on the last scan, indexed once they settle
+	pending map[string]os.FileInfo
+}
+
+// load seeds the indexer from an existing index so unchanged files are not hashed again
+func (x *modelIndexer) load() {
+	x.entries = make(map[string]IndexEntry)
+	x.pending = make(map[string]os.FileInfo)
+	data, err := os.ReadFile(x.out)
+	if err != nil {
+		return
+	}
+	var idx ModelIndex
+	if json.Unmarshal(data, &idx) == nil {
+		for _, e := range idx.Models {
+			x.entries[e.Path] = e
+		}
+	}
+}
+
+// scan updates the index from the directory, reporting whether anything changed. With
+// settle set, new or modified files are only indexed once they stop changing between scans.
+func (x *modelIndexer) scan(settle bool) (bool, error) {
+	files, err := modelFiles(x.dir)
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	seen := make(map[string]bool)
+	for _, f := range files {
+		path, err := filepath.Abs(f)
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		seen[path] = true
+
+		prev, indexed := x.entries[path]
+		if indexed && prev.Size == info.Size() && prev.ModTime.Equal(info.ModTime()) {
+			continue
+		}
+		if settle {
+			last, ok := x.pending[path]
+			x.pending[path] = info
+			if !ok || last.Size() != info.Size() || !last.ModTime().Equal(info.ModTime()) {
+				continue
+			}
+		}
+		delete(x.pending, path)
+
+		var prevPtr *IndexEntry
+		if indexed {
+			prevPtr = &prev
+		}
+		entry, err := indexEntry(path, info, prevPtr)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Could not index %s: %s", path, err))
+			continue
+		}
+		x.entries[path] = entry
+		changed = true
+		fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Indexed %s", entry.Name))
+	}
+
+	for path, e := range x.entries {
+		if !seen[path] {
+			delete(x.entries, path)
+			changed = true
+			fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Removed %s from the index", e.Name))
+		}
+	}
+	return changed, nil
+}
+
+// write stores the index, sorted by name
+func (x *modelIndexer) write() error {
+	idx := ModelIndex{UpdatedAt: time.Now(), Models: make([]IndexEntry, 0, len(x.entries))}
+	for _, e := range x.entries {
+		idx.Models = append(idx.Models, e)
+	}
+	sort.Slice(idx.Models, func(i, j int) bool { return idx.Models[i].Name < idx.Models[j].Name })
+	return writeJSONFile(x.out, idx)
+}
+
+var indexCommand = &Command{
+	Name:    "index",
+	Usage:   "DIR",
+	Summary: "Write an index.json of the models in a directory, optionally keeping it up to date",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		watch := fs.Bool("watch", false, "Keep running and update the index as model files are added or removed")
+		interval := fs.Duration("interval", 5*time.Second, "How often -watch rescans the directory")
+		out := fs.String("out", "", "Index file to write (default DIR/"+indexFilename+")")
+		return func(args []string) error {
+			if len(args) != 1 {
+				return errors.New("usage: index [-watch] [-interval D] [-out FILE] DIR")
+			}
+			if *watch && *interval <= 0 {
+				return errors.New("-interval must be positive")
+			}
+			x := &modelIndexer{dir: args[0], out: *out}
+			if x.out == "" {
+				x.out = filepath.Join(args[0], indexFilename)
+			}
+			x.load()
+
+			changed, err := x.scan(false)
+			if err != nil {
+				return err
+			}
+			if changed || !*watch {
+				if err := x.write(); err != nil {
+					return err
+				}
+			}
+			fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] %s lists %d model(s)", x.out, len(x.entries)))
+			if !*watch {
+				return nil
+			}
+
+			fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Watching %s every %s (Ctrl-C to stop)", args[0], *interval))
+			for range time.Tick(*interval) {
+				changed, err := x.scan(true)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, color.YellowString("[WARN] %s", err))
+					continue
+				}
+				if changed {
+					if err := x.write(); err != nil {
+						fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Could not write %s: %s", x.out, err))
+					}
+				}
+			}
+			return nil
+		}
+	},
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Token types in tokenizer.ggml.token_type
+const (
+	tokenTypeControl     = 3
+	tokenTypeUserDefined = 4
+)
+
+// specialTokenKeys are the tokenizer's special token ids, in display order
+var specialTokenKeys = []struct{ key, label string }{
+	{"tokenizer.ggml.bos_token_id", "BOS"},
+	{"tokenizer.ggml.eos_token_id", "EOS"},
+	{"tokenizer.ggml.eot_token_id", "EOT"},
+	{"tokenizer.ggml.padding_token_id", "PAD"},
+	{"tokenizer.ggml.unknown_token_id", "UNK"},
+	{"tokenizer.ggml.separator_token_id", "SEP"},
+}
+
+// printMetadata prints the scalar metadata of a GGUF file
+func printMetadata(meta *GGUFMetadata) {
+	fmt.Println(color.CyanString("GGUF version %d, %d tensors", meta.Version, meta.TensorCount))
+	keys := make([]string, 0, len(meta.KV))
+	for k := range meta.KV {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := fmt.Sprint(meta.KV[k])
+		if len(v) > 80 {
+			v = v[:77] + "..."
+		}
+		fmt.Printf("%s %s\n", color.GreenString("%-45s", k), strings.ReplaceAll(v, "\n", `\n`))
+	}
+}
+
+// tokenLabel renders a token id with its text
+func tokenLabel(tokens []string, id int64) string {
+	if id < 0 || id >= int64(len(tokens)) {
+		return fmt.Sprintf("%d (out of range)", id)
+	}
+	return fmt.Sprintf("%d %q", id, tokens[id])
+}
+
+// printTokenizer prints the vocabulary size, special tokens, the tokens the chat
+// template refers to, and the first n tokens of the vocabulary
+func printTokenizer(meta *GGUFMetadata, n int) error {
+	tokens := meta.Strings("tokenizer.ggml.tokens")
+	if len(tokens) == 0 {
+		return errors.New("the file has no embedded tokenizer")
+	}
+	types := meta.Ints("tokenizer.ggml.token_type")
+
+	fmt.Println(color.CyanString("Tokenizer"))
+	fmt.Printf("  %-16s%s\n", "Model:", meta.String("tokenizer.ggml.model"))
+	if pre := meta.String("tokenizer.ggml.pre"); pre != "" {
+		fmt.Printf("  %-16s%s\n", "Pre-tokenizer:", pre)
+	}
+	fmt.Printf("  %-16s%d\n", "Vocab size:", len(tokens))
+	if merges := meta.Strings("tokenizer.ggml.merges"); len(merges) > 0 {
+		fmt.Printf("  %-16s%d\n", "Merges:", len(merges))
+	}
+
+	fmt.Println(color.CyanString("\nSpecial tokens"))
+	for _, st := range specialTokenKeys {
+		if id, ok := meta.Int(st.key); ok {
+			fmt.Printf("  %-6s%s\n", st.label, tokenLabel(tokens, id))
+		}
+	}
+	control := 0
+	for _, t := range types {
+		if t == tokenTypeControl || t == tokenTypeUserDefined {
+			control++
+		}
+	}
+	fmt.Printf("  %d control or user-defined tokens in total\n", control)
+
+	if template := meta.String("tokenizer.chat_template"); template != "" {
+		fmt.Println(color.CyanString("\nChat template tokens"))
+		found := 0
+		for id, tok := range tokens {
+			if id >= len(types) || (types[id] != tokenTypeControl && types[id] != tokenTypeUserDefined) {
+				continue
+			}
+			if tok != "" && strings.Contains(template, tok) {
+				fmt.Printf("  %s\n", tokenLabel(tokens, int64(id)))
+				found++
+			}
+		}
+		if found == 0 {
+			fmt.Println("  The template uses no special tokens literally")
+		}
+	}
+
+	if n > 0 {
+		fmt.Println(color.CyanString("\nFirst %d tokens", min(n, len(tokens))))
+		for id := 0; id < n && id < len(tokens); id++ {
+			fmt.Printf("  %s\n", tokenLabel(tokens, int64(id)))
+		}
+	}
+	return nil
+}
+
+var inspectCommand = &Command{
+	Name:    "inspect",
+	Usage:   "FILE",
+	Summary: "Print the metadata or embedded tokenizer of a GGUF file",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		tokenizer := fs.Bool("tokenizer", false, "Show the vocabulary size, special tokens and chat-template token ids")
+		first := fs.Int("n", 20, "With -tokenizer, number of tokens to dump from the start of the vocabulary")
+		return func(args []string) error {
+			if len(args) != 1 {
+				return errors.New("usage: inspect [-tokenizer [-n N]] FILE")
+			}
+			path := resolveFile(args[0])
+			if *tokenizer {
+				meta, err := openGGUFMetadata(path, func(key string) bool {
+					return strings.HasPrefix(key, "tokenizer.")
+				})
+				if err != nil {
+					return err
+				}
+				return printTokenizer(meta, *first)
+			}
+
+			// The tokenizer tables run to megabytes and are summarized by -tokenizer instead
+			meta, err := openGGUFMetadata(path, func(key string) bool {
+				return !strings.HasPrefix(key, "tokenizer.ggml.")
+			})
+			if err != nil {
+				return err
+			}
+			printMetadata(meta)
+			return nil
+		}
+	},
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Media types Ollama writes into the manifests of its store, for manifests and configs
+// the registry served without one
+const (
+	ollamaManifestType = "application/vnd.docker.distribution.manifest.v2+json"
+	ollamaConfigType   = "application/vnd.docker.container.image.v1+json"
+)
+
+// ollamaModelsDir returns the local Ollama model store, honoring OLLAMA_MODELS like
+// the ollama server does
+func ollamaModelsDir() (string, error) {
+	if dir := os.Getenv("OLLAMA_MODELS"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".ollama", "models"), nil
+}
+
+// ollamaBlobPath returns where the store keeps the blob with the given digest
+func ollamaBlobPath(dir, digest string) string {
+	return filepath.Join(dir, "blobs", strings.Replace(digest, ":", "-", 1))
+}
+
+// placeOllamaBlob puts a verified local file into the store under its digest, cloning
+// or hard-linking it where possible so the store costs no extra space
+func placeOllamaBlob(src, dst string) error {
+	part := partialPath(dst)
+	os.Remove(part)
+	if err := reflinkFile(src, part); err != nil {
+		if err := os.Link(src, part); err != nil {
+			if err := copyFile(src, part); err != nil {
+				return err
+			}
+		}
+	}
+	return os.Rename(part, dst)
+}
+
+// installToOllama writes every blob of a manifest and the manifest itself into the
+// local Ollama store, so the model shows up in "ollama list" without the server
+// downloading it again. local maps layer digests to files of this pull already checked
+// against them; those are placed in the store directly, and any other layer is fetched
+// from the blob cache or the registry.
+func installToOllama(ctx context.Context, modelName, tag string, manifest *Manifest, local map[string]string, opts PullOptions) error {
+	dir, err := ollamaModelsDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "blobs"), 0o755); err != nil {
+		return err
+	}
+	// The store takes only verified blobs, whatever -no-verify says for the pull
+	opts.NoVerify = false
+
+	layers := append([]Layer{manifest.Config}, manifest.Layers...)
+	for _, layer := range layers {
+		if layer.Digest == "" {
+			continue
+		}
+		dst := ollamaBlobPath(dir, layer.Digest)
+		if info, err := os.Stat(dst); err == nil && info.Size() == layer.Size {
+			continue
+		}
+		if src, ok := local[layer.Digest]; ok {
+			if err := placeOllamaBlob(src, dst); err != nil {
+				return fmt.Errorf("Ollama blob %s: %w", layer.Digest, err)
+			}
+			continue
+		}
+		if layer.Size > maxMetadataLayerSize {
+			if err := pullLayerFile(ctx, modelName, layer, dst, "Ollama blob", nil, opts, ""); err != nil {
+				return fmt.Errorf("Ollama blob %s: %w", layer.Digest, err)
+			}
+			continue
+		}
+		data, err := fetchBlobBytes(ctx, modelName, layer.Digest, nil)
+		if err == nil {
+			err = checkBlobBytes(data, layer.Digest)
+		}
+		if err == nil {
+			err = os.WriteFile(dst, data, 0o644)
+		}
+		if err != nil {
+			return fmt.Errorf("Ollama blob %s: %w", layer.Digest, err)
+		}
+	}
+
+	path := filepath.Join(dir, "manifests", RegistryHost, filepath.FromSlash(repoPath(modelName)), tag)
+	if err := writeOllamaManifest(path, manifest); err != nil {
+		return fmt.Errorf("Ollama manifest: %w", err)
+	}
+	fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] Installed %s:%s into %s; it is listed by ollama list", modelName, tag, dir))
+	return nil
+}
+
+// writeOllamaManifest stores the manifest as the registry sent it, so its digest matches
+// what Ollama resolves the tag to; a manifest cached before its bytes were kept is
+// rebuilt from its fields
+func writeOllamaManifest(path string, manifest *Manifest) error {
+	if len(manifest.Raw) > 0 {
+		return writeFileAtomic(path, manifest.Raw, 0o644)
+	}
+	stored := struct {
+		SchemaVersion int     `json:"schemaVersion"`
+		MediaType     string  `json:"mediaType"`
+		Config        Layer   `json:"config"`
+		Layers        []Layer `json:"layers"`
+	}{2, ollamaManifestType, manifest.Config, manifest.Layers}
+	if stored.Config.MediaType == "" {
+		stored.Config.MediaType = ollamaConfigType
+	}
+	return writeJSONFile(path, stored)
+}
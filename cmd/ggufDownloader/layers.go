@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"ggufDownloader/pkg/ollamareg"
+)
+
+// The manifest types are the registry client's, so code embedding the client and this
+// tool share them
+type (
+	Manifest    = ollamareg.Manifest
+	Layer       = ollamareg.Layer
+	MediaType   = ollamareg.MediaType
+	ModelConfig = ollamareg.ModelConfig
+)
+
+// Media types of the layers found in Ollama manifests
+const (
+	MediaTypeModel     = ollamareg.MediaTypeModel
+	MediaTypeTemplate  = ollamareg.MediaTypeTemplate
+	MediaTypeParams    = ollamareg.MediaTypeParams
+	MediaTypeSystem    = ollamareg.MediaTypeSystem
+	MediaTypeLicense   = ollamareg.MediaTypeLicense
+	MediaTypeMessages  = ollamareg.MediaTypeMessages
+	MediaTypeProjector = ollamareg.MediaTypeProjector
+	MediaTypeAdapter   = ollamareg.MediaTypeAdapter
+)
+
+// fetchModelConfig downloads and parses the config blob of a manifest
+func fetchModelConfig(ctx context.Context, modelName string, manifest *Manifest) (*ModelConfig, error) {
+	if manifest.Config.Digest == "" {
+		return nil, fmt.Errorf("manifest for %s has no config descriptor", modelName)
+	}
+	// A config blob never changes, so it is reused for as long as it is stored
+	var cfg ModelConfig
+	if loadMetadata("configs", manifest.Config.Digest, 0, &cfg) {
+		return &cfg, nil
+	}
+	data, err := fetchBlobBytes(ctx, modelName, manifest.Config.Digest, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid model config: %w", err)
+	}
+	storeMetadata("configs", manifest.Config.Digest, &cfg)
+	return &cfg, nil
+}
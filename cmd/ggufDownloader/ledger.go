@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// LedgerEntry records a model file the tool has downloaded
+type LedgerEntry struct {
+	Model        string    `json:"model"`
+	Params       string    `json:"params"`
+	Digest       string    `json:"digest"`
+	Path         string    `json:"path"`
+	Size         int64     `json:"size"`
+	Cached       bool      `json:"cached,omitempty"`
+	DownloadedAt time.Time `json:"downloaded_at"`
+	// Inputs fingerprints what a converted file was built from, so unchanged conversions are skipped
+	Inputs string `json:"inputs,omitempty"`
+	// Alias is a short name commands accept in place of Path, set with "alias set"
+	Alias string `json:"alias,omitempty"`
+	// Source is sourceHF for a file downloaded from Hugging Face rather than the registry
+	Source string `json:"source,omitempty"`
+	// URL is where a Hugging Face file was downloaded from, for updates, check and repair
+	URL string `json:"url,omitempty"`
+}
+
+// sourceHF marks ledger entries downloaded from Hugging Face
+const sourceHF = "hf"
+
+// ledgerPath returns the location of the download ledger
+func ledgerPath() (string, error) {
+	dir, err := projectDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ledger.json"), nil
+}
+
+// loadLedger reads every ledger entry, returning none if the ledger does not exist yet
+func loadLedger() ([]LedgerEntry, error) {
+	path, err := ledgerPath()
+	if err != nil {
+		return nil, err
+	}
+	return readLedger(path)
+}
+
+// allLedgerEntries reads the ledgers of the default namespace and of every project
+func allLedgerEntries() ([]LedgerEntry, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "projects", "*", "ledger.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var all []LedgerEntry
+	for _, path := range append([]string{filepath.Join(dir, "ledger.json")}, paths...) {
+		entries, err := readLedger(path)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, entries...)
+	}
+	return all, nil
+}
+
+// ledgerEntry returns the ledger entry of path, or nil when the ledger has none
+func ledgerEntry(path string) (*LedgerEntry, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := loadLedger()
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		if entries[i].Path == abs {
+			return &entries[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// readLedger reads the ledger at path
+func readLedger(path string) ([]LedgerEntry, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []LedgerEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid ledger %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// saveLedger replaces the ledger with entries
+func saveLedger(entries []LedgerEntry) error {
+	path, err := ledgerPath()
+	if err != nil {
+		return err
+	}
+	if entries == nil {
+		entries = []LedgerEntry{}
+	}
+	return writeJSONFile(path, entries)
+}
+
+// ledgerMu serializes ledger updates from parallel pulls, which would otherwise each
+// save their own copy and drop the others' entries
+var ledgerMu sync.Mutex
+
+// updateLedger loads the ledger, lets update change its entries and saves the result,
+// holding ledgerMu throughout
+func updateLedger(update func([]LedgerEntry) ([]LedgerEntry, error)) error {
+	ledgerMu.Lock()
+	defer ledgerMu.Unlock()
+
+	entries, err := loadLedger()
+	if err != nil {
+		return err
+	}
+	if entries, err = update(entries); err != nil {
+		return err
+	}
+	return saveLedger(entries)
+}
+
+// recordDownload adds an entry to the ledger, replacing any earlier entry for the same
+// path; the file keeps its alias
+func recordDownload(entry LedgerEntry) error {
+	return updateLedger(func(entries []LedgerEntry) ([]LedgerEntry, error) {
+		kept := entries[:0]
+		for _, e := range entries {
+			if e.Path != entry.Path {
+				kept = append(kept, e)
+			} else if entry.Alias == "" {
+				entry.Alias = e.Alias
+			}
+		}
+		return append(kept, entry), nil
+	})
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// licenseInfo is what the license layer of a model says about its use
+type licenseInfo struct {
+	ID         string `json:"id"`
+	Commercial bool   `json:"commercial"`
+}
+
+// licenseRules identify licenses by phrases in their text, most specific first; a
+// non-commercial clause anywhere in the text wins over the license it amends
+var licenseRules = []struct {
+	phrases    []string
+	id         string
+	commercial bool
+}{
+	{[]string{"noncommercial", "non-commercial", "non commercial", "cc-by-nc", "cc by-nc", "research purposes only", "non-production license", "research license"}, "non-commercial", false},
+	{[]string{"apache license", "apache-2.0"}, "apache-2.0", true},
+	{[]string{"mit license", "permission is hereby granted, free of charge"}, "mit", true},
+	{[]string{"llama 4 community license", "llama 3.3 community license", "llama 3.2 community license", "llama 3.1 community license", "llama 3 community license", "llama 2 community license"}, "llama", true},
+	{[]string{"gemma terms of use"}, "gemma", true},
+	{[]string{"tongyi qianwen license"}, "qwen", true},
+	{[]string{"deepseek license"}, "deepseek", true},
+	{[]string{"openrail"}, "openrail", true},
+	{[]string{"creative commons attribution"}, "cc-by", true},
+	{[]string{"bsd 3-clause", "redistribution and use in source and binary forms"}, "bsd", true},
+}
+
+// classifyLicense identifies a license from its text
+func classifyLicense(text string) licenseInfo {
+	text = strings.ToLower(strings.Join(strings.Fields(text), " "))
+	for _, rule := range licenseRules {
+		for _, p := range rule.phrases {
+			if strings.Contains(text, p) {
+				return licenseInfo{ID: rule.id, Commercial: rule.commercial}
+			}
+		}
+	}
+	return licenseInfo{ID: "unknown"}
+}
+
+// modelLicense reads the license layers of model:tag; a model without one is "unknown"
+func modelLicense(ctx context.Context, modelName, tag string) (licenseInfo, error) {
+	manifest, err := fetchManifest(ctx, modelName, tag)
+	if err != nil {
+		return licenseInfo{}, err
+	}
+	license := licenseInfo{ID: "unknown"}
+	for _, layer := range manifest.LayersByType(MediaTypeLicense) {
+		// License blobs are addressed by digest, so a classification never goes stale
+		var info licenseInfo
+		if !loadMetadata("licenses", layer.Digest, 0, &info) {
+			data, err := fetchBlobBytes(ctx, modelName, layer.Digest, nil)
+			if err != nil {
+				return licenseInfo{}, err
+			}
+			info = classifyLicense(string(data))
+			storeMetadata("licenses", layer.Digest, &info)
+		}
+		license = stricterLicense(license, info)
+	}
+	return license, nil
+}
+
+// stricterLicense combines two licenses of one model. Models ship a license and an
+// acceptable-use policy side by side; the most restrictive one decides.
+func stricterLicense(license, info licenseInfo) licenseInfo {
+	if license.ID == "unknown" || (license.Commercial && !info.Commercial && info.ID != "unknown") {
+		return info
+	}
+	return license
+}
+
+// licenseFilter is a parsed -license value
+type licenseFilter struct {
+	allow []string
+	deny  []string
+}
+
+// parseLicenseFilter parses a comma-separated list of license ids or the words
+// commercial, non-commercial and unknown; a leading ! excludes instead of selects
+func parseLicenseFilter(spec string) (*licenseFilter, error) {
+	f := &licenseFilter{}
+	for _, term := range strings.Split(strings.ToLower(spec), ",") {
+		term = strings.TrimSpace(term)
+		if exclude := strings.TrimPrefix(term, "!"); exclude != term {
+			if exclude == "" {
+				return nil, errors.New("-license: ! must be followed by a license")
+			}
+			f.deny = append(f.deny, exclude)
+		} else if term != "" {
+			f.allow = append(f.allow, term)
+		}
+	}
+	if len(f.allow) == 0 && len(f.deny) == 0 {
+		return nil, errors.New("-license needs at least one license")
+	}
+	return f, nil
+}
+
+// matchesLicense reports whether a license is described by a filter term
+func matchesLicense(info licenseInfo, term string) bool {
+	switch term {
+	case "commercial":
+		return info.Commercial
+	case "non-commercial", "noncommercial":
+		return !info.Commercial && info.ID != "unknown"
+	}
+	return info.ID == term
+}
+
+// keep reports whether a model with the given license passes the filter
+func (f *licenseFilter) keep(info licenseInfo) bool {
+	for _, term := range f.deny {
+		if matchesLicense(info, term) {
+			return false
+		}
+	}
+	if len(f.allow) == 0 {
+		return true
+	}
+	for _, term := range f.allow {
+		if matchesLicense(info, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterByLicense keeps the models whose latest tag passes the filter, looking the
+// licenses up a few at a time; models whose license cannot be read are dropped
+func filterByLicense(models []ModelInfo, f *licenseFilter) []ModelInfo {
+	ctx := context.Background()
+	keep := make([]bool, len(models))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				info, err := modelLicense(ctx, models[i].Name, "latest")
+				if err != nil {
+					fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Skipping %s: could not read its license: %s", models[i].Name, err))
+					continue
+				}
+				keep[i] = f.keep(info)
+			}
+		}()
+	}
+	for i := range models {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	var kept []ModelInfo
+	for i, m := range models {
+		if keep[i] {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
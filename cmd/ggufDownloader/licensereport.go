@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// licenseRecord is one local model in a license report
+type licenseRecord struct {
+	Model string `json:"model"`
+	File  string `json:"file"`
+	// Digest identifies the exact file the license applies to
+	Digest string `json:"digest,omitempty"`
+	Source string `json:"source,omitempty"`
+	// License is the identified license, or the identifier the publisher declares
+	License string `json:"license"`
+	// Commercial is yes, no or unknown
+	Commercial   string    `json:"commercial_use"`
+	Texts        []string  `json:"license_texts,omitempty"`
+	Notes        []string  `json:"notes,omitempty"`
+	DownloadedAt time.Time `json:"downloaded_at"`
+}
+
+// commercialUse renders whether a license allows commercial use
+func commercialUse(info licenseInfo) string {
+	switch {
+	case info.ID == "unknown":
+		return "unknown"
+	case info.Commercial:
+		return "yes"
+	}
+	return "no"
+}
+
+// registryLicenseTexts returns the license texts of model:tag and whether the tag still
+// serves digest. The texts are kept in the metadata store by blob digest.
+func registryLicenseTexts(ctx context.Context, modelName, tag, digest string) ([]string, bool, error) {
+	manifest, err := fetchManifest(ctx, modelName, tag)
+	if err != nil {
+		return nil, false, err
+	}
+	current := true
+	if layer := manifest.ModelLayer(); layer != nil && digest != "" {
+		current = layer.Digest == digest
+	}
+	var texts []string
+	for _, layer := range manifest.LayersByType(MediaTypeLicense) {
+		var text string
+		if !loadMetadata("license-texts", layer.Digest, 0, &text) {
+			data, err := fetchBlobBytes(ctx, modelName, layer.Digest, nil)
+			if err != nil {
+				return nil, current, err
+			}
+			text = string(data)
+			storeMetadata("license-texts", layer.Digest, text)
+		}
+		texts = append(texts, text)
+	}
+	return texts, current, nil
+}
+
+// hfLicenseID returns the license a Hugging Face repository declares in its model card
+func hfLicenseID(ctx context.Context, repo string) (string, error) {
+	var info struct {
+		CardData struct {
+			License any `json:"license"`
+		} `json:"cardData"`
+		Tags []string `json:"tags"`
+	}
+	if err := hfGetJSON(ctx, fmt.Sprintf("https://%s/api/models/%s", HuggingFaceHost, repo), &info); err != nil {
+		return "", err
+	}
+	if id, ok := info.CardData.License.(string); ok && id != "" {
+		return strings.ToLower(id), nil
+	}
+	for _, tag := range info.Tags {
+		if id, ok := strings.CutPrefix(tag, "license:"); ok {
+			return strings.ToLower(id), nil
+		}
+	}
+	return "unknown", nil
+}
+
+// hfCommercialUse tells from a declared license identifier whether it allows commercial
+// use, for the identifiers the license rules know
+func hfCommercialUse(id string) string {
+	if strings.Contains(id, "-nc") || strings.Contains(id, "noncommercial") {
+		return "no"
+	}
+	for _, rule := range licenseRules {
+		if rule.id == id {
+			return commercialUse(licenseInfo{ID: id, Commercial: rule.commercial})
+		}
+	}
+	return "unknown"
+}
+
+// describeLicense fills in the license of a ledger entry: from the license layers of an
+// Ollama model, a -full pull's license.txt, or the model card of a Hugging Face repository
+func describeLicense(ctx context.Context, entry LedgerEntry) licenseRecord {
+	rec := licenseRecord{Model: entry.Model + ":" + entry.Params, File: entry.Path, Digest: entry.Digest, License: "unknown", Commercial: "unknown", DownloadedAt: entry.DownloadedAt}
+	var sidecar Sidecar
+	if data, err := os.ReadFile(sidecarPath(entry.Path)); err == nil && json.Unmarshal(data, &sidecar) == nil {
+		rec.Source = sidecar.Source
+	}
+
+	fromHF := strings.Contains(rec.Source, "://"+HuggingFaceHost+"/")
+	if fromHF {
+		rec.Model = entry.Model
+		if entry.Inputs == "" {
+			rec.Model += " " + entry.Params
+		} else {
+			rec.Notes = append(rec.Notes, "converted from the repository's safetensors")
+		}
+		id, err := hfLicenseID(ctx, entry.Model)
+		if err != nil {
+			rec.Notes = append(rec.Notes, "license could not be looked up: "+err.Error())
+			return rec
+		}
+		rec.License, rec.Commercial = id, hfCommercialUse(id)
+		rec.Notes = append(rec.Notes, "license as declared on the repository's model card; read the repository's license files")
+		return rec
+	}
+	if rec.Source == "" {
+		rec.Source = blobURL(entry.Model, entry.Digest)
+	}
+
+	// A -full pull keeps the license of the exact version it downloaded
+	var texts []string
+	if filepath.Base(entry.Path) == fullModelFile {
+		for _, name := range []string{"license.txt", "license-2.txt", "license-3.txt"} {
+			if data, err := os.ReadFile(filepath.Join(filepath.Dir(entry.Path), name)); err == nil {
+				texts = append(texts, string(data))
+			}
+		}
+	}
+	if texts == nil {
+		var current bool
+		var err error
+		if texts, current, err = registryLicenseTexts(ctx, entry.Model, entry.Params, entry.Digest); err != nil {
+			rec.Notes = append(rec.Notes, "license could not be looked up: "+err.Error())
+			return rec
+		}
+		if !current {
+			rec.Notes = append(rec.Notes, "the tag now serves a newer version; its license is shown")
+		}
+	}
+	if len(texts) == 0 {
+		rec.Notes = append(rec.Notes, "the model is published without a license")
+	}
+	info := licenseInfo{ID: "unknown"}
+	for _, text := range texts {
+		info = stricterLicense(info, classifyLicense(text))
+	}
+	rec.License, rec.Commercial, rec.Texts = info.ID, commercialUse(info), texts
+	return rec
+}
+
+// licenseReport describes the license of every model in the ledger that is still on
+// disk. The inputs of a conversion are left out; the converted file stands for them.
+func licenseReport(ctx context.Context, entries []LedgerEntry) []licenseRecord {
+	records := make([]licenseRecord, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Params == "safetensors" {
+			continue
+		}
+		if _, err := os.Stat(entry.Path); err != nil {
+			continue
+		}
+		records = append(records, describeLicense(ctx, entry))
+	}
+	slices.SortFunc(records, func(a, b licenseRecord) int { return strings.Compare(a.Model, b.Model) })
+	return records
+}
+
+// markdownCell makes text safe inside a Markdown table cell
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
+
+// markdownFence returns a code fence longer than any run of backticks in text
+func markdownFence(text string) string {
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	return fence
+}
+
+// writeLicenseMarkdown renders a license report for legal review: a table of every
+// model, then each distinct license text once with the models it applies to
+func writeLicenseMarkdown(w io.Writer, records []licenseRecord, now time.Time) {
+	fmt.Fprintf(w, "# Model license report\n\n")
+	fmt.Fprintf(w, "Generated %s from the download ledger; %d models on disk.\n", now.UTC().Format("2006-01-02 15:04 MST"), len(records))
+	fmt.Fprintf(w, "License identifiers are a heuristic reading of the license texts, which are\nreproduced below; they are not legal advice.\n\n")
+	fmt.Fprintf(w, "| Model | File | SHA-256 | License | Commercial use | Source |\n|---|---|---|---|---|---|\n")
+	for _, r := range records {
+		fmt.Fprintf(w, "| %s | %s | %s | %s | %s | %s |\n", markdownCell(r.Model), markdownCell(r.File), markdownCell(strings.TrimPrefix(r.Digest, "sha256:")), r.License, r.Commercial, markdownCell(r.Source))
+	}
+
+	var notes []string
+	for _, r := range records {
+		for _, n := range r.Notes {
+			notes = append(notes, fmt.Sprintf("- %s: %s", r.Model, n))
+		}
+	}
+	if len(notes) > 0 {
+		fmt.Fprintf(w, "\n## Notes\n\n%s\n", strings.Join(notes, "\n"))
+	}
+
+	var order []string
+	users := make(map[string][]string)
+	texts := make(map[string]string)
+	for _, r := range records {
+		for _, text := range r.Texts {
+			key := fmt.Sprintf("%x", sha256.Sum256([]byte(text)))
+			if _, ok := texts[key]; !ok {
+				order = append(order, key)
+				texts[key] = text
+			}
+			if !slices.Contains(users[key], r.Model) {
+				users[key] = append(users[key], r.Model)
+			}
+		}
+	}
+	if len(order) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n## License texts\n")
+	for i, key := range order {
+		text := strings.TrimRight(texts[key], "\n")
+		fence := markdownFence(text)
+		fmt.Fprintf(w, "\n### Text %d: %s\n\nApplies to: %s\n\n%stext\n%s\n%s\n", i+1, classifyLicense(text).ID, strings.Join(users[key], ", "), fence, text, fence)
+	}
+}
+
+// writeLicenseReport writes a license report as markdown or json
+func writeLicenseReport(w io.Writer, records []licenseRecord, format string) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	}
+	writeLicenseMarkdown(w, records, time.Now())
+	return nil
+}
+
+var licensesCommand = &Command{
+	Name:    "licenses",
+	Usage:   "report [-format markdown|json] [-out FILE] [-all]",
+	Summary: "Write one document listing every local model with its license and source, for legal review",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		format := fs.String("format", "markdown", "Report format: markdown or json")
+		out := fs.String("out", "", "Write the report to this file instead of stdout")
+		all := fs.Bool("all", false, "Include the ledgers of every project")
+		return func(args []string) error {
+			if len(args) != 1 || args[0] != "report" {
+				return errors.New("usage: licenses report [-format markdown|json] [-out FILE] [-all]")
+			}
+			if *format != "markdown" && *format != "json" {
+				return fmt.Errorf("unknown -format %q (use markdown or json)", *format)
+			}
+			var entries []LedgerEntry
+			var err error
+			if *all {
+				entries, err = allLedgerEntries()
+			} else {
+				entries, err = loadLedger()
+			}
+			if err != nil {
+				return err
+			}
+			records := licenseReport(context.Background(), entries)
+
+			if *out == "" {
+				return writeLicenseReport(os.Stdout, records, *format)
+			}
+			f, err := os.Create(*out + ".tmp")
+			if err != nil {
+				return err
+			}
+			if err := writeLicenseReport(f, records, *format); err != nil {
+				f.Close()
+				os.Remove(f.Name())
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+			if err := os.Rename(f.Name(), *out); err != nil {
+				return err
+			}
+			fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] Wrote the licenses of %d models to %s", len(records), *out))
+			return nil
+		}
+	},
+}
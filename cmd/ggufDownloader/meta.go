@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// maxMetadataLayerSize guards against mislabeled layers pulling gigabytes into memory
+const maxMetadataLayerSize = 16 << 20
+
+// fetchBlobBytes downloads a small blob into memory, reporting progress to bar if it is not nil
+func fetchBlobBytes(ctx context.Context, modelName, digest string, bar progressWriter) ([]byte, error) {
+	resp, err := httpGet(ctx, blobURL(modelName, digest))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("failed to download blob: " + resp.Status)
+	}
+	if resp.ContentLength > maxMetadataLayerSize {
+		return nil, fmt.Errorf("blob %s is %s, too large for a metadata layer", digest, formatBytes(resp.ContentLength))
+	}
+	body := io.LimitReader(resp.Body, maxMetadataLayerSize)
+	if bar == nil {
+		return io.ReadAll(body)
+	}
+	data, err := io.ReadAll(io.TeeReader(body, bar))
+	if err != nil {
+		return nil, err
+	}
+	return data, bar.Finish()
+}
+
+// uniqueName returns name, or name with a numeric suffix if it was already used
+func uniqueName(used map[string]int, name string) string {
+	used[name]++
+	if n := used[name]; n > 1 {
+		ext := filepath.Ext(name)
+		return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), n, ext)
+	}
+	return name
+}
+
+var metaCommand = &Command{
+	Name:    "meta",
+	Usage:   "MODEL:TAG | get FILE [KEY]... | set [-o OUT] FILE KEY=VALUE...",
+	Summary: "Download only the template, params, license and config of a model, or read and edit a GGUF file's metadata",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		outDir := fs.String("out", "", "Directory to write the metadata files to (default MODEL:TAG-meta)")
+		outFile := fs.String("o", "", "meta set: write a corrected copy to this path instead of editing in place (keeping FILE.bak)")
+		typeName := fs.String("type", "", "meta set: value type of the keys being set, e.g. uint32 or bool (default: the key's current type, or string)")
+		var unset []string
+		fs.Func("unset", "meta set: remove this key (repeatable)", func(s string) error {
+			unset = append(unset, s)
+			return nil
+		})
+		return func(args []string) error {
+			if len(args) > 0 {
+				switch args[0] {
+				case "get":
+					return runMetaGet(args[1:])
+				case "set":
+					return runMetaSet(args[1:], *outFile, *typeName, unset)
+				}
+			}
+			if len(args) != 1 {
+				return errors.New("usage: meta [-out DIR] MODEL:TAG")
+			}
+			return fetchMetadata(args[0], *outDir)
+		}
+	},
+}
+
+// fetchMetadata downloads only the metadata layers of a model (template, params, license, config)
+func fetchMetadata(ref, outDir string) error {
+	modelName, tag, err := parseModelRef(ref)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	manifest, err := fetchManifest(ctx, modelName, tag)
+	if err != nil {
+		return err
+	}
+
+	dir := outDir
+	if dir == "" {
+		if dir, err = outputPath(fmt.Sprintf("%s:%s-meta", localName(modelName), tag)); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	type metaFile struct {
+		name   string
+		digest string
+		size   int64
+	}
+	files := []metaFile{}
+	if manifest.Config.Digest != "" {
+		files = append(files, metaFile{"config.json", manifest.Config.Digest, manifest.Config.Size})
+	}
+	used := make(map[string]int)
+	for _, layer := range manifest.Layers {
+		if name, ok := layer.MediaType.MetadataFile(); ok {
+			files = append(files, metaFile{uniqueName(used, name), layer.Digest, layer.Size})
+		}
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] %s has no metadata layers", ref))
+		return nil
+	}
+
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+	progress := newBundleProgress(len(files), total)
+	for _, f := range files {
+		data, err := fetchBlobBytes(ctx, modelName, f.digest, progress.layer(f.name, f.size, 0))
+		if err != nil {
+			return fmt.Errorf("failed to fetch %s: %w", f.name, err)
+		}
+		path := filepath.Join(dir, f.name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] Metadata for %s written to %s", ref, dir))
+	return nil
+}
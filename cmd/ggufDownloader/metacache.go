@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// manifestTTL is how long a manifest fetched by tag is reused; manifests and configs
+// fetched by digest never change and are reused indefinitely
+const manifestTTL = 5 * time.Minute
+
+// freshMetadata makes commands fetch and parse metadata again instead of reusing the store
+var freshMetadata bool
+
+// metadataRecord is one entry of the metadata store
+type metadataRecord struct {
+	Key      string          `json:"key"`
+	StoredAt time.Time       `json:"stored_at"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// metadataDir returns the directory of the metadata store, shared by every project
+func metadataDir() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "metadata"), nil
+}
+
+// metadataPath returns the file holding the entry for key in the given kind of entries
+func metadataPath(kind, key, ext string) (string, error) {
+	dir, err := metadataDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(dir, kind, fmt.Sprintf("%x%s", sum[:16], ext)), nil
+}
+
+// loadMetadata decodes the stored entry for key into v, reporting false when it is
+// missing, older than maxAge (0 never expires) or -fresh was given
+func loadMetadata(kind, key string, maxAge time.Duration, v any) bool {
+	if freshMetadata {
+		return false
+	}
+	path, err := metadataPath(kind, key, ".json")
+	if err != nil {
+		return false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	var rec metadataRecord
+	if json.Unmarshal(data, &rec) != nil || rec.Key != key {
+		return false
+	}
+	if maxAge > 0 && time.Since(rec.StoredAt) > maxAge {
+		return false
+	}
+	return json.Unmarshal(rec.Data, v) == nil
+}
+
+// storeMetadata records v as the entry for key; the store is an optimization, so failures are ignored
+func storeMetadata(kind, key string, v any) {
+	path, err := metadataPath(kind, key, ".json")
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	writeJSONFile(path, metadataRecord{Key: key, StoredAt: time.Now(), Data: data})
+}
+
+// manifestRecord keeps the manifest digest, which the manifest itself does not serialize
+type manifestRecord struct {
+	Manifest *Manifest `json:"manifest"`
+	Digest   string    `json:"digest"`
+	Raw      []byte    `json:"raw,omitempty"`
+}
+
+// fetchManifest returns the manifest of model:ref, reusing a recently fetched copy
+func fetchManifest(ctx context.Context, modelName, ref string) (*Manifest, error) {
+	key := registryURL(repoPath(modelName) + "/manifests/" + ref)
+	ttl := manifestTTL
+	if isDigest(ref) {
+		ttl = 0
+	}
+
+	var rec manifestRecord
+	if loadMetadata("manifests", key, ttl, &rec) && rec.Manifest != nil {
+		rec.Manifest.Digest, rec.Manifest.Raw = rec.Digest, rec.Raw
+		addProvenance(ctx, ProvenanceHop{Step: "manifest", URL: key, Digest: rec.Digest, Detail: "reused from the metadata cache"})
+		return rec.Manifest, nil
+	}
+	mctx, cancel := withPhaseTimeout(ctx, "resolving "+modelName+":"+ref, "-manifest-timeout", manifestTimeout)
+	defer cancel()
+	manifest, err := downloadManifest(mctx, modelName, ref)
+	if err != nil {
+		return nil, timeoutCause(mctx, err)
+	}
+	storeMetadata("manifests", key, manifestRecord{Manifest: manifest, Digest: manifest.Digest, Raw: manifest.Raw})
+	return manifest, nil
+}
+
+// ggufCacheEntry is the parsed header of a local GGUF file without its arrays, which
+// run to megabytes for tokenizers and are read from the file when asked for
+type ggufCacheEntry struct {
+	Path        string
+	File        hashCacheEntry
+	Version     uint32
+	TensorCount uint64
+	Scalars     map[string]any
+	Arrays      []string
+}
+
+// openGGUFMetadata reads the metadata of a local GGUF file, reusing the stored header of
+// an unchanged file unless the caller wants arrays
+func openGGUFMetadata(path string, keep func(key string) bool) (*GGUFMetadata, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, err
+	}
+	file := hashCacheKey(info)
+	cachePath, cacheErr := metadataPath("gguf", abs, ".gob")
+
+	if entry, ok := loadGGUFEntry(cachePath, abs, file); ok && !wantsArray(entry.Arrays, keep) {
+		meta := &GGUFMetadata{Version: entry.Version, TensorCount: entry.TensorCount, KV: entry.Scalars}
+		return filterGGUFStrings(meta, keep), nil
+	}
+
+	f, err := os.Open(abs)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// Every scalar is kept so the stored header can answer any later caller
+	var arrays []string
+	meta, err := parseGGUFMetadata(f, func(key string, t uint32) bool {
+		if t == ggufTypeArray {
+			arrays = append(arrays, key)
+			return keep == nil || keep(key)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if cacheErr == nil {
+		entry := ggufCacheEntry{Path: abs, File: file, Version: meta.Version, TensorCount: meta.TensorCount, Scalars: make(map[string]any), Arrays: arrays}
+		for k, v := range meta.KV {
+			if _, isArray := v.([]any); !isArray {
+				entry.Scalars[k] = v
+			}
+		}
+		storeGGUFEntry(cachePath, &entry)
+	}
+	return filterGGUFStrings(meta, keep), nil
+}
+
+// wantsArray reports whether keep asks for any of the array keys
+func wantsArray(arrays []string, keep func(key string) bool) bool {
+	for _, k := range arrays {
+		if keep == nil || keep(k) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterGGUFStrings drops the strings keep does not ask for, matching what readGGUFMetadata returns
+func filterGGUFStrings(meta *GGUFMetadata, keep func(key string) bool) *GGUFMetadata {
+	if keep == nil {
+		return meta
+	}
+	kv := make(map[string]any, len(meta.KV))
+	for k, v := range meta.KV {
+		if _, isString := v.(string); isString && !keep(k) {
+			continue
+		}
+		kv[k] = v
+	}
+	return &GGUFMetadata{Version: meta.Version, TensorCount: meta.TensorCount, KV: kv}
+}
+
+// loadGGUFEntry reads the stored header of a file, if it was stored for this version of the file
+func loadGGUFEntry(cachePath, abs string, file hashCacheEntry) (*ggufCacheEntry, bool) {
+	if freshMetadata {
+		return nil, false
+	}
+	f, err := os.Open(cachePath)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+
+	var entry ggufCacheEntry
+	if gob.NewDecoder(f).Decode(&entry) != nil || entry.Path != abs || entry.File != file {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// storeGGUFEntry writes the header of a file to the store; failures are ignored
+func storeGGUFEntry(cachePath string, entry *ggufCacheEntry) {
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0o755); err != nil {
+		return
+	}
+	tmp := cachePath + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return
+	}
+	err = gob.NewEncoder(f).Encode(entry)
+	if cerr := f.Close(); err != nil || cerr != nil {
+		os.Remove(tmp)
+		return
+	}
+	os.Rename(tmp, cachePath)
+}
+
+// clearMetadata removes the whole metadata store
+func clearMetadata() error {
+	dir, err := metadataDir()
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
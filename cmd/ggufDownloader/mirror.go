@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// mirrorStateFile records, inside a mirror directory, which digest each tag was mirrored at
+const mirrorStateFile = ".mirror.json"
+
+// MirrorConfig is a set of models kept in step with the registry by "mirror NAME"
+type MirrorConfig struct {
+	// Models are MODEL[:TAG] patterns: MODEL may be a glob over the catalog's model
+	// names, TAG a glob over the model's tags, and no tag means every tag
+	Models modelList `json:"models"`
+	// Dir is the directory the mirror is kept in
+	Dir string `json:"dir"`
+}
+
+// mirrorEntry is what a mirror knows about one tag it holds
+type mirrorEntry struct {
+	Digest string `json:"digest"`
+	// File is the file's name within the mirror directory
+	File      string    `json:"file"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// mirrorState is the content of a mirror directory's state file, keyed by "model:tag"
+type mirrorState struct {
+	Tags map[string]mirrorEntry `json:"tags"`
+}
+
+// loadMirrorState reads the state file of dir; a directory never mirrored into has an empty state
+func loadMirrorState(dir string) (*mirrorState, error) {
+	state := &mirrorState{Tags: make(map[string]mirrorEntry)}
+	data, err := os.ReadFile(filepath.Join(dir, mirrorStateFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("invalid mirror state %s: %w", filepath.Join(dir, mirrorStateFile), err)
+	}
+	if state.Tags == nil {
+		state.Tags = make(map[string]mirrorEntry)
+	}
+	return state, nil
+}
+
+// mirrorRef is one tag a mirror should hold
+type mirrorRef struct {
+	Model, Tag string
+}
+
+func (r mirrorRef) String() string {
+	return r.Model + ":" + r.Tag
+}
+
+// expandMirrorPatterns resolves MODEL[:TAG] patterns to the tags the registry serves now.
+// A model whose tags cannot be listed is reported and counted in failed, so one bad
+// model does not hold up the rest of the mirror.
+func expandMirrorPatterns(ctx context.Context, patterns []string) (refs []mirrorRef, failed int, err error) {
+	var catalog []ModelInfo
+	seen := make(map[mirrorRef]bool)
+	for _, pattern := range patterns {
+		model, tag, found := strings.Cut(pattern, ":")
+		if !found || tag == "" {
+			tag = "*"
+		}
+		if _, err := path.Match(model, ""); err != nil {
+			return nil, 0, fmt.Errorf("invalid model pattern %q: %w", model, err)
+		}
+
+		models := []string{model}
+		if isTagPattern(model) {
+			if catalog == nil {
+				// New models should join the mirror on the night they are published
+				cache, err := searchableCatalog(true)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, color.YellowString("[WARN] %s; matching the cached catalog", err))
+					if cache, err = searchableCatalog(false); err != nil {
+						return nil, 0, err
+					}
+				}
+				catalog = cache.Models
+			}
+			models = models[:0]
+			for _, m := range catalog {
+				if ok, _ := path.Match(strings.ToLower(model), strings.ToLower(m.Name)); ok {
+					models = append(models, m.Name)
+				}
+			}
+			if len(models) == 0 {
+				return nil, 0, fmt.Errorf("no models in the catalog match %q", model)
+			}
+		}
+
+		for _, m := range models {
+			tags := []string{tag}
+			if isTagPattern(tag) {
+				var err error
+				if tags, err = matchTags(ctx, m, tag); err != nil {
+					fmt.Fprintln(os.Stderr, color.RedString("[ERROR] %s: %s", m, err))
+					failed++
+					continue
+				}
+			}
+			for _, t := range tags {
+				if ref := (mirrorRef{m, t}); !seen[ref] {
+					seen[ref] = true
+					refs = append(refs, ref)
+				}
+			}
+		}
+	}
+	return refs, failed, nil
+}
+
+var mirrorCommand = &Command{
+	Name:    "mirror",
+	Usage:   "NAME | -dir DIR PATTERN...",
+	Summary: "Bring a directory up to date with every tag matching some patterns",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		pull := addPullFlags(fs)
+		dir := fs.String("dir", "", "Directory to mirror into, overriding a configured mirror's")
+		return func(args []string) error {
+			if len(args) == 0 {
+				return errors.New("usage: mirror NAME | mirror -dir DIR PATTERN...")
+			}
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			patterns := args
+			if m, ok := cfg.Mirrors[args[0]]; ok && len(args) == 1 {
+				patterns = m.Models
+				if *dir == "" {
+					*dir = m.Dir
+				}
+			}
+			if *dir == "" {
+				return errors.New("mirror needs a directory: set -dir or configure the mirror's \"dir\"")
+			}
+			if len(patterns) == 0 {
+				return fmt.Errorf("mirror %s lists no models", args[0])
+			}
+			return runMirror(interruptContext(), *dir, patterns, pull.options())
+		}
+	},
+}
+
+// runMirror downloads every tag matching patterns that dir does not hold at its current
+// digest. Files of tags that are gone upstream are reported but kept; removing a model
+// from a mirror is left to whoever runs it.
+func runMirror(ctx context.Context, dir string, patterns []string, opts PullOptions) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	state, err := loadMirrorState(dir)
+	if err != nil {
+		return err
+	}
+	// A mirror is only as current as the metadata it compares against
+	freshMetadata = true
+	refs, failed, err := expandMirrorPatterns(ctx, patterns)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Mirroring %d tag(s) into %s", len(refs), dir))
+
+	var added, updated, current int
+	wanted := make(map[string]bool)
+	for _, ref := range refs {
+		if ctx.Err() != nil {
+			return fmt.Errorf("mirror of %s interrupted after %d new and %d updated", dir, added, updated)
+		}
+		wanted[ref.String()] = true
+		manifest, err := fetchManifest(ctx, ref.Model, ref.Tag)
+		if err == nil && manifest.ModelLayer() == nil {
+			err = errors.New("model digest not found in manifest")
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("[ERROR] %s: %s", ref, err))
+			failed++
+			continue
+		}
+		layer := manifest.ModelLayer()
+
+		prev, known := state.Tags[ref.String()]
+		if known && prev.Digest == layer.Digest {
+			if info, err := os.Stat(filepath.Join(dir, prev.File)); err == nil && info.Size() == layer.Size {
+				current++
+				continue
+			}
+		}
+
+		o := opts
+		o.Manifest, o.Digest = manifest.Digest, layer.Digest
+		// An interrupted download of the same digest is picked up where it stopped; a file
+		// of an older digest is replaced
+		o.IfExists = "resume"
+		if known && prev.Digest != layer.Digest {
+			o.IfExists = "overwrite"
+		}
+		config, _ := fetchModelConfig(ctx, ref.Model, manifest)
+		o.OutputFile = filepath.Join(dir, o.fileName(NamedModel{Model: ref.Model, Tag: ref.Tag, Digest: layer.Digest, Config: config}))
+
+		file, err := pullModel(ctx, ref.Model, ref.Tag, o)
+		recordPullOutcome(ref.Model, ref.Tag, err)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("[ERROR] %s: %s", ref, err))
+			failed++
+			continue
+		}
+		if known {
+			updated++
+		} else {
+			added++
+		}
+		state.Tags[ref.String()] = mirrorEntry{Digest: layer.Digest, File: filepath.Base(file), UpdatedAt: time.Now().UTC()}
+		// Saved after every tag so an interrupted run does not download it again
+		if err := writeJSONFile(filepath.Join(dir, mirrorStateFile), state); err != nil {
+			return fmt.Errorf("failed to save the mirror state: %w", err)
+		}
+	}
+
+	var gone []string
+	for ref := range state.Tags {
+		if !wanted[ref] {
+			gone = append(gone, ref)
+		}
+	}
+	sort.Strings(gone)
+	for _, ref := range gone {
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] %s is no longer published or matched; keeping %s", ref, state.Tags[ref].File))
+	}
+
+	summary := fmt.Sprintf("%d new, %d updated, %d current", added, updated, current)
+	if failed > 0 {
+		return fmt.Errorf("mirror of %s: %s, %d failed", dir, summary, failed)
+	}
+	fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] Mirror of %s: %s", dir, summary))
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// modelfilePath returns where the Modelfile of the model saved as filename goes:
+// MODEL:TAG.Modelfile next to it, or Modelfile inside the directory of a -full pull
+func modelfilePath(filename string, full bool) string {
+	if full {
+		return filepath.Join(filepath.Dir(filename), "Modelfile")
+	}
+	return strings.TrimSuffix(filename, ".gguf") + ".Modelfile"
+}
+
+// modelfileString quotes a Modelfile value, switching to triple quotes for text that
+// spans lines or holds a quote, which Ollama reads without escapes
+func modelfileString(s string) string {
+	if strings.ContainsAny(s, "\"\n") {
+		return `"""` + s + `"""`
+	}
+	return `"` + s + `"`
+}
+
+// modelfileParameters renders a params layer as PARAMETER lines, sorted by name; a list
+// such as stop becomes one line per value
+func modelfileParameters(data []byte) ([]string, error) {
+	var params map[string]any
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil, fmt.Errorf("invalid params layer: %w", err)
+	}
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var lines []string
+	for _, name := range names {
+		values, ok := params[name].([]any)
+		if !ok {
+			values = []any{params[name]}
+		}
+		for _, v := range values {
+			if s, ok := v.(string); ok {
+				lines = append(lines, fmt.Sprintf("PARAMETER %s %s", name, modelfileString(s)))
+			} else {
+				lines = append(lines, fmt.Sprintf("PARAMETER %s %v", name, v))
+			}
+		}
+	}
+	return lines, nil
+}
+
+// writeModelfile writes an Ollama Modelfile for the model saved as filename, built from
+// the template, system prompt, params and license layers of its manifest, so
+// "ollama create NAME -f FILE" imports the download as the registry publishes it.
+// FROM names the weights and projectors relative to the Modelfile, which Ollama
+// resolves against the Modelfile's directory.
+func writeModelfile(ctx context.Context, modelName, tag string, manifest *Manifest, filename string, projectors []ProjectorFile, full bool) error {
+	path := modelfilePath(filename, full)
+	dir := filepath.Dir(path)
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s:%s, generated by ggufDownloader\n", modelName, tag)
+	for _, file := range append([]string{filename}, projectorFiles(projectors)...) {
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			rel = file
+		}
+		fmt.Fprintf(&b, "FROM ./%s\n", filepath.ToSlash(rel))
+	}
+
+	for _, layer := range manifest.Layers {
+		var keyword string
+		switch layer.MediaType {
+		case MediaTypeTemplate:
+			keyword = "TEMPLATE"
+		case MediaTypeSystem:
+			keyword = "SYSTEM"
+		case MediaTypeLicense:
+			keyword = "LICENSE"
+		case MediaTypeParams:
+		default:
+			continue
+		}
+		data, err := fetchBlobBytes(ctx, modelName, layer.Digest, nil)
+		if err == nil {
+			err = checkBlobBytes(data, layer.Digest)
+		}
+		if err != nil {
+			return fmt.Errorf("Modelfile: %s: %w", fullLayerName(layer.MediaType), err)
+		}
+		if keyword != "" {
+			fmt.Fprintf(&b, "%s %s\n", keyword, modelfileString(string(data)))
+			continue
+		}
+		lines, err := modelfileParameters(data)
+		if err != nil {
+			return fmt.Errorf("Modelfile: %w", err)
+		}
+		for _, line := range lines {
+			b.WriteString(line + "\n")
+		}
+	}
+
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		return fmt.Errorf("Modelfile: %w", err)
+	}
+	fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] Wrote %s; import it with: ollama create %s:%s -f %s", path, modelName, tag, path))
+	return nil
+}
+
+// projectorFiles returns the paths of the projectors pulled with a model
+func projectorFiles(projectors []ProjectorFile) []string {
+	files := make([]string, len(projectors))
+	for i, p := range projectors {
+		files[i] = p.File
+	}
+	return files
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// lowMemory streams listings and catalogs instead of holding them in memory, for small machines
+var lowMemory bool
+
+// maxScrapePageSize bounds how much of an ollama.com page is read
+const maxScrapePageSize = 16 << 20
+
+// errPageTooLarge is returned when a scraped page exceeds maxScrapePageSize
+var errPageTooLarge = fmt.Errorf("page exceeds %s", formatBytes(maxScrapePageSize))
+
+// boundedReader fails, rather than silently truncating, once more than n bytes are read
+type boundedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (b *boundedReader) Read(p []byte) (int, error) {
+	if b.n <= 0 {
+		return 0, errPageTooLarge
+	}
+	if int64(len(p)) > b.n {
+		p = p[:b.n]
+	}
+	n, err := b.r.Read(p)
+	b.n -= int64(n)
+	return n, err
+}
+
+// voidElements never have an end tag, so they do not open a nesting level
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// hasAttr reports whether a tag carries the attribute key
+func hasAttr(tok html.Token, key string) bool {
+	for _, a := range tok.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// hasClasses reports whether a tag's class attribute contains every class in want
+func hasClasses(tok html.Token, want ...string) bool {
+	for _, a := range tok.Attr {
+		if a.Key != "class" {
+			continue
+		}
+		classes := strings.Fields(a.Val)
+		for _, w := range want {
+			found := false
+			for _, c := range classes {
+				if c == w {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// modelField names the ModelInfo field an element inside a model entry holds, if any
+func modelField(tok html.Token) string {
+	switch {
+	case tok.Data == "span" && hasAttr(tok, "x-test-search-response-title"):
+		return "name"
+	case tok.Data == "p" && hasClasses(tok, "max-w-lg", "break-words", "text-neutral-800"):
+		return "description"
+	case tok.Data == "span" && hasAttr(tok, "x-test-size"):
+		return "size"
+	case tok.Data == "span" && hasAttr(tok, "x-test-capability"):
+		return "capability"
+	case tok.Data == "span" && hasAttr(tok, "x-test-pull-count"):
+		return "pulls"
+	case tok.Data == "span" && hasAttr(tok, "x-test-tag-count"):
+		return "tags"
+	case tok.Data == "span" && hasAttr(tok, "x-test-updated"):
+		return "updated"
+	}
+	return ""
+}
+
+// setField stores the text of a captured element
+func (m *ModelInfo) setField(field, text string) {
+	if text == "" {
+		return
+	}
+	switch field {
+	case "name":
+		m.Name = text
+	case "description":
+		m.Description = text
+	case "size":
+		m.Parameters = append(m.Parameters, text)
+	case "capability":
+		m.Capabilities = append(m.Capabilities, text)
+	case "pulls":
+		m.PullCount = text
+	case "tags":
+		m.TagCount = text
+	case "updated":
+		m.UpdatedAt = text
+	}
+}
+
+// parseModelList parses ollama.com search results into a document and calls fn with
+// each model entry
+func parseModelList(r io.Reader, fn func(ModelInfo) error) error {
+	doc, err := goquery.NewDocumentFromReader(&boundedReader{r: r, n: maxScrapePageSize})
+	if err != nil {
+		return err
+	}
+
+	var models []ModelInfo
+	doc.Find("li[x-test-model]").Each(func(i int, li *goquery.Selection) {
+		model := ModelInfo{}
+
+		// Extract model name
+		titleSpan := li.Find("span[x-test-search-response-title]")
+		model.Name = strings.TrimSpace(titleSpan.Text())
+
+		// Extract description
+		descPara := li.Find("p.max-w-lg.break-words.text-neutral-800")
+		model.Description = strings.TrimSpace(descPara.Text())
+
+		// Extract parameter options (sizes)
+		li.Find("span[x-test-size]").Each(func(_ int, param *goquery.Selection) {
+			paramText := strings.TrimSpace(param.Text())
+			if paramText != "" {
+				model.Parameters = append(model.Parameters, paramText)
+			}
+		})
+
+		// Extract capabilities
+		li.Find("span[x-test-capability]").Each(func(_ int, cap *goquery.Selection) {
+			capText := strings.TrimSpace(cap.Text())
+			if capText != "" {
+				model.Capabilities = append(model.Capabilities, capText)
+			}
+		})
+
+		// Extract metadata
+		pullCountSpan := li.Find("span[x-test-pull-count]")
+		model.PullCount = strings.TrimSpace(pullCountSpan.Text())
+
+		tagCountSpan := li.Find("span[x-test-tag-count]")
+		model.TagCount = strings.TrimSpace(tagCountSpan.Text())
+
+		updatedAtSpan := li.Find("span[x-test-updated]")
+		model.UpdatedAt = strings.TrimSpace(updatedAtSpan.Text())
+
+		if model.Name != "" {
+			models = append(models, model)
+		}
+	})
+
+	for _, model := range models {
+		if err := fn(model); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanModelList parses ollama.com search results as a stream for -low-memory, calling
+// fn as soon as each model entry ends, so memory use does not grow with the size of
+// the page. Like the HTML parsing rules, an end tag closes the elements left open
+// inside it and a new entry closes the one before.
+func scanModelList(r io.Reader, fn func(ModelInfo) error) error {
+	z := html.NewTokenizer(&boundedReader{r: r, n: maxScrapePageSize})
+	var model *ModelInfo
+	// open holds the elements open inside the model entry, its li first
+	var open []string
+	// fields are the elements being captured, each with the depth it opened at
+	type capture struct {
+		field string
+		depth int
+		text  strings.Builder
+	}
+	var fields []*capture
+
+	// finish ends the elements from depth on, and the entry with its li
+	finish := func(depth int) error {
+		for len(fields) > 0 && fields[len(fields)-1].depth > depth {
+			c := fields[len(fields)-1]
+			model.setField(c.field, strings.TrimSpace(c.text.String()))
+			fields = fields[:len(fields)-1]
+		}
+		open = open[:depth]
+		if depth > 0 {
+			return nil
+		}
+		m := *model
+		model = nil
+		if m.Name == "" {
+			return nil
+		}
+		return fn(m)
+	}
+
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if errors.Is(z.Err(), io.EOF) {
+				if model != nil {
+					return finish(0)
+				}
+				return nil
+			}
+			return z.Err()
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			entry := tok.Data == "li" && tt == html.StartTagToken && hasAttr(tok, "x-test-model")
+			if model != nil && entry {
+				if err := finish(0); err != nil {
+					return err
+				}
+			}
+			if model == nil {
+				if entry {
+					model = &ModelInfo{}
+					open = append(open, "li")
+				}
+				continue
+			}
+			if tt == html.SelfClosingTagToken || voidElements[tok.Data] {
+				continue
+			}
+			open = append(open, tok.Data)
+			if field := modelField(tok); field != "" {
+				fields = append(fields, &capture{field: field, depth: len(open)})
+			}
+
+		case html.EndTagToken:
+			if model == nil {
+				continue
+			}
+			name := z.Token().Data
+			// An end tag without a matching open element is ignored
+			for depth := len(open) - 1; depth >= 0; depth-- {
+				if open[depth] == name {
+					if err := finish(depth); err != nil {
+						return err
+					}
+					break
+				}
+			}
+
+		case html.TextToken:
+			if model != nil {
+				text := z.Text()
+				for _, c := range fields {
+					c.text.Write(text)
+				}
+			}
+		}
+	}
+}
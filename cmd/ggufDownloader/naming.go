@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// NamedModel is what a naming policy may use to name a downloaded model
+type NamedModel struct {
+	Model  string
+	Tag    string
+	Digest string
+	Config *ModelConfig
+}
+
+// NamingPolicy decides the file name a model is saved under, since downstream tools
+// disagree on what a model file should be called
+type NamingPolicy interface {
+	FileName(m NamedModel) string
+}
+
+// OllamaNaming names files after the model reference, "llama3:8b.gguf"; models outside
+// the library namespace become "team_model:tag.gguf"
+type OllamaNaming struct{}
+
+func (OllamaNaming) FileName(m NamedModel) string {
+	return fmt.Sprintf("%s:%s.gguf", localName(m.Model), m.Tag)
+}
+
+// HuggingFaceNaming follows the convention of GGUF repositories on Hugging Face,
+// "llama3-8b-instruct-Q4_K_M.gguf": no colons, and the quantization always named
+type HuggingFaceNaming struct{}
+
+func (HuggingFaceNaming) FileName(m NamedModel) string {
+	parts := []string{path.Base(m.Model)}
+	if m.Tag != "latest" {
+		parts = append(parts, m.Tag)
+	}
+	if quant := m.quantization(); quant != "" && !strings.Contains(strings.ToLower(m.Tag), strings.ToLower(quant)) {
+		parts = append(parts, quant)
+	}
+	return strings.Join(parts, "-") + ".gguf"
+}
+
+// DigestNaming names files after their content, "sha256-<hex>.gguf", like a blob store;
+// the sidecar still records which model:tag the file is
+type DigestNaming struct{}
+
+func (DigestNaming) FileName(m NamedModel) string {
+	return strings.Replace(m.Digest, ":", "-", 1) + ".gguf"
+}
+
+// TemplateNaming fills a pattern such as "{family}-{params}-{quant}.gguf" from the
+// model's config layer, which states these more precisely than the tag; a value the
+// config lacks is taken from the tag, or is "unknown"
+type TemplateNaming struct {
+	Pattern string
+}
+
+// templateFields are the placeholders a naming template may use
+var templateFields = map[string]func(m NamedModel) string{
+	"model":  func(m NamedModel) string { return localName(m.Model) },
+	"name":   func(m NamedModel) string { return path.Base(m.Model) },
+	"tag":    func(m NamedModel) string { return m.Tag },
+	"digest": func(m NamedModel) string { return strings.Replace(m.Digest, ":", "-", 1) },
+	"family": func(m NamedModel) string {
+		if m.Config != nil && m.Config.Family() != "" {
+			return m.Config.Family()
+		}
+		return path.Base(m.Model)
+	},
+	"params": func(m NamedModel) string {
+		if m.Config != nil && m.Config.Parameters() != "" {
+			return paramSize(m.Config.Parameters())
+		}
+		return "unknown"
+	},
+	"quant": func(m NamedModel) string {
+		if quant := m.quantization(); quant != "" {
+			return quant
+		}
+		return "unknown"
+	},
+}
+
+// templatePlaceholder matches a {field} in a naming template
+var templatePlaceholder = regexp.MustCompile(`\{([a-z]+)\}`)
+
+func (t TemplateNaming) FileName(m NamedModel) string {
+	name := templatePlaceholder.ReplaceAllStringFunc(t.Pattern, func(p string) string {
+		// A value must not add directories to the name
+		return strings.NewReplacer("/", "_", string(filepath.Separator), "_").Replace(templateFields[p[1:len(p)-1]](m))
+	})
+	if !strings.EqualFold(filepath.Ext(name), ".gguf") {
+		name += ".gguf"
+	}
+	return name
+}
+
+// quantization returns the quantization from the config layer, or failing that from the tag
+func (m NamedModel) quantization() string {
+	if m.Config != nil && m.Config.Quantization() != "" {
+		return m.Config.Quantization()
+	}
+	return fileQuant(m.Tag)
+}
+
+// namingPolicies are the policies selectable with -naming
+var namingPolicies = map[string]NamingPolicy{
+	"ollama":      OllamaNaming{},
+	"huggingface": HuggingFaceNaming{},
+	"digest":      DigestNaming{},
+}
+
+// namingPolicyNames lists the selectable policies for usage messages
+func namingPolicyNames() string {
+	names := make([]string, 0, len(namingPolicies))
+	for name := range namingPolicies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// templateFieldNames lists the placeholders of naming templates for messages
+func templateFieldNames() string {
+	names := make([]string, 0, len(templateFields))
+	for name := range templateFields {
+		names = append(names, "{"+name+"}")
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// parseNamingPolicy returns the policy with the given name, or a TemplateNaming when
+// name contains placeholders
+func parseNamingPolicy(name string) (NamingPolicy, error) {
+	if strings.Contains(name, "{") {
+		for _, m := range templatePlaceholder.FindAllStringSubmatch(name, -1) {
+			if templateFields[m[1]] == nil {
+				return nil, fmt.Errorf("unknown naming template field {%s} (use %s)", m[1], templateFieldNames())
+			}
+		}
+		return TemplateNaming{Pattern: name}, nil
+	}
+	policy, ok := namingPolicies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown naming policy %q (use %s)", name, namingPolicyNames())
+	}
+	return policy, nil
+}
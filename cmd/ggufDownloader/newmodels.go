@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// seenModels records which models the "new" command has already shown
+type seenModels struct {
+	CheckedAt time.Time `json:"checked_at"`
+	Models    []string  `json:"models"`
+}
+
+// seenModelsPath returns the location of the seen-models state of the active project
+func seenModelsPath() (string, error) {
+	dir, err := projectDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "seen-models.json"), nil
+}
+
+// loadSeenModels reads the seen-models state, returning nil if "new" has never run
+func loadSeenModels(path string) (*seenModels, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var seen seenModels
+	if err := json.Unmarshal(data, &seen); err != nil {
+		return nil, fmt.Errorf("invalid seen-models file %s: %w", path, err)
+	}
+	return &seen, nil
+}
+
+var newCommand = &Command{
+	Name:    "new",
+	Summary: "List the newest models, highlighting those added since the last run",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		limit := fs.Int("n", 20, "Number of newest models to show")
+		details := fs.Bool("details", false, "Show capabilities, downloads and update dates")
+		return func(args []string) error {
+			if *limit < 1 {
+				return errors.New("-n must be at least 1")
+			}
+			return listNewModels(*limit, *details)
+		}
+	},
+}
+
+// listNewModels prints the newest models from ollama.com and marks those not seen by a previous run
+func listNewModels(limit int, showDetails bool) error {
+	path, err := seenModelsPath()
+	if err != nil {
+		return err
+	}
+	seen, err := loadSeenModels(path)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool)
+	if seen != nil {
+		for _, name := range seen.Models {
+			known[name] = true
+		}
+	}
+
+	var models []ModelInfo
+	err = scanSearchResults("newest", "", func(m ModelInfo) error {
+		if len(models) < limit {
+			models = append(models, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(models) == 0 {
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] No models found."))
+		return nil
+	}
+
+	t := modelTable{nameWidth: 20, showDetails: showDetails, markWidth: 6}
+	t.fit(models)
+	t.printHeader()
+
+	added := 0
+	for _, m := range models {
+		if seen != nil && !known[m.Name] {
+			added++
+			fmt.Printf(color.New(color.FgYellow, color.Bold).Sprintf("%-*s", t.markWidth, "NEW"))
+		} else {
+			fmt.Printf("%*s", t.markWidth, "")
+		}
+		t.printRow(m)
+		known[m.Name] = true
+	}
+
+	fmt.Println()
+	switch {
+	case seen == nil:
+		fmt.Fprintln(os.Stderr, color.CyanString("[INFO] First run: models added from now on will be highlighted next time"))
+	case added == 0:
+		fmt.Fprintln(os.Stderr, color.CyanString("[INFO] No new models since %s", seen.CheckedAt.Local().Format("2006-01-02 15:04")))
+	default:
+		fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] %d new model(s) since %s", added, seen.CheckedAt.Local().Format("2006-01-02 15:04")))
+	}
+
+	// Keep every name ever seen so a model dropping out of the first page is not reported again later
+	names := make([]string, 0, len(known))
+	for name := range known {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return writeJSONFile(path, seenModels{CheckedAt: time.Now().UTC(), Models: names})
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// ollamaClient talks to the local Ollama server; registry TLS and dial settings do not apply to it
+var ollamaClient = &http.Client{}
+
+// ollamaBaseURL returns the local Ollama API address, honoring OLLAMA_HOST like the ollama CLI does
+func ollamaBaseURL() string {
+	host := os.Getenv("OLLAMA_HOST")
+	if host == "" {
+		return "http://127.0.0.1:11434"
+	}
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	return strings.TrimSuffix(host, "/")
+}
+
+// ollamaBlobExists reports whether the local Ollama already stores the blob
+func ollamaBlobExists(ctx context.Context, base, digest string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, base+"/api/blobs/"+digest, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := ollamaClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("cannot reach Ollama at %s: %w", base, err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK, nil
+}
+
+// uploadOllamaBlob streams a file into the local Ollama blob store
+func uploadOllamaBlob(ctx context.Context, base, path, digest string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	bar := newProgress(info.Size(), "Uploading to Ollama")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+"/api/blobs/"+digest, io.TeeReader(f, bar))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+
+	resp, err := ollamaClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("blob upload failed: %s %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return bar.Finish()
+}
+
+// createOllamaModel asks Ollama to create a model from an uploaded GGUF blob
+func createOllamaModel(ctx context.Context, base, name, filename, digest string) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"model": name,
+		"files": map[string]string{filename: digest},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+"/api/create", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := ollamaClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Ollama streams newline-delimited status objects and reports failures inline
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var status struct {
+			Status string `json:"status"`
+			Error  string `json:"error"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &status); err != nil {
+			continue
+		}
+		if status.Error != "" {
+			return errors.New(status.Error)
+		}
+		if status.Status != "" {
+			fmt.Fprintln(os.Stderr, color.WhiteString("  %s", status.Status))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("model creation failed: " + resp.Status)
+	}
+	return nil
+}
+
+// registerWithOllama uploads a downloaded GGUF to the local Ollama and creates a model from it
+func registerWithOllama(ctx context.Context, path, digest, name string) error {
+	base := ollamaBaseURL()
+
+	exists, err := ollamaBlobExists(ctx, base, digest)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Uploading %s to Ollama at %s...", filepath.Base(path), base))
+		if err := uploadOllamaBlob(ctx, base, path, digest); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Creating Ollama model %s...", name))
+	return createOllamaModel(ctx, base, name, filepath.Base(path), digest)
+}
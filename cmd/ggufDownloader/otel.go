@@ -0,0 +1,428 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+
+	"ggufDownloader/pkg/ollamareg"
+)
+
+// OpenTelemetry export over OTLP/HTTP with JSON encoding, configured with the standard
+// OTEL_* environment variables. Every command run is one trace: a root span for the
+// command, spans for the registry client's operations, and a client span for each HTTP
+// request, lasting until its body is closed.
+
+// OTLP enum values used below
+const (
+	otlpSpanKindInternal = 1
+	otlpSpanKindClient   = 3
+	otlpStatusError      = 2
+	otlpCumulative       = 2
+)
+
+// otlpFlushSize is how many finished spans are buffered before they are exported
+const otlpFlushSize = 256
+
+// otlpAttr is a key-value attribute in OTLP JSON
+type otlpAttr struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+// attr builds a string, int or bool attribute
+func attr(key string, v any) otlpAttr {
+	switch v := v.(type) {
+	case int:
+		return otlpAttr{key, map[string]any{"intValue": strconv.Itoa(v)}}
+	case int64:
+		return otlpAttr{key, map[string]any{"intValue": strconv.FormatInt(v, 10)}}
+	case bool:
+		return otlpAttr{key, map[string]any{"boolValue": v}}
+	}
+	return otlpAttr{key, map[string]any{"stringValue": fmt.Sprint(v)}}
+}
+
+// otlpSpan is a finished span in OTLP JSON
+type otlpSpan struct {
+	TraceID      string         `json:"traceId"`
+	SpanID       string         `json:"spanId"`
+	ParentSpanID string         `json:"parentSpanId,omitempty"`
+	Name         string         `json:"name"`
+	Kind         int            `json:"kind"`
+	Start        string         `json:"startTimeUnixNano"`
+	End          string         `json:"endTimeUnixNano"`
+	Attributes   []otlpAttr     `json:"attributes,omitempty"`
+	Status       map[string]any `json:"status,omitempty"`
+}
+
+// telemetryExporter buffers spans and request metrics and posts them to a collector
+type telemetryExporter struct {
+	tracesURL  string
+	metricsURL string
+	headers    map[string]string
+	resource   []otlpAttr
+	client     *http.Client
+
+	traceID string
+	rootID  string
+	started time.Time
+
+	mu       sync.Mutex
+	spans    []otlpSpan
+	requests map[string]int64 // by host and status class
+	bytes    map[string]int64 // by host
+}
+
+// telemetry is nil unless an OTLP endpoint is configured
+var telemetry *telemetryExporter
+
+// newID returns n random bytes as hex, the OTLP JSON encoding of trace and span ids
+func newID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// otlpEndpoint returns the URL for a signal from OTEL_EXPORTER_OTLP_<SIGNAL>_ENDPOINT,
+// or OTEL_EXPORTER_OTLP_ENDPOINT with the signal's path appended
+func otlpEndpoint(signal string) string {
+	if url := os.Getenv("OTEL_EXPORTER_OTLP_" + strings.ToUpper(signal) + "_ENDPOINT"); url != "" {
+		return url
+	}
+	if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); base != "" {
+		return strings.TrimSuffix(base, "/") + "/v1/" + signal
+	}
+	return ""
+}
+
+// parseOTLPHeaders parses OTEL_EXPORTER_OTLP_HEADERS ("key=value,key2=value2")
+func parseOTLPHeaders(s string) map[string]string {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return headers
+}
+
+// setupTelemetry enables export when an OTLP endpoint is configured
+func setupTelemetry() {
+	if os.Getenv("OTEL_SDK_DISABLED") == "true" {
+		return
+	}
+	traces, metrics := otlpEndpoint("traces"), otlpEndpoint("metrics")
+	if traces == "" && metrics == "" {
+		return
+	}
+	if privacyMode {
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Not exporting telemetry in privacy mode"))
+		return
+	}
+	if p := os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"); p != "" && p != "http/json" {
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] OTEL_EXPORTER_OTLP_PROTOCOL=%s is not supported; exporting http/json", p))
+	}
+	service := os.Getenv("OTEL_SERVICE_NAME")
+	if service == "" {
+		service = "ggufDownloader"
+	}
+	host, _ := os.Hostname()
+	telemetry = &telemetryExporter{
+		tracesURL:  traces,
+		metricsURL: metrics,
+		headers:    parseOTLPHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")),
+		resource:   []otlpAttr{attr("service.name", service), attr("service.version", UserAgent), attr("host.name", host)},
+		// The exporter's own requests must not be traced
+		client:   &http.Client{Timeout: 10 * time.Second},
+		traceID:  newID(16),
+		rootID:   newID(8),
+		started:  time.Now(),
+		requests: make(map[string]int64),
+		bytes:    make(map[string]int64),
+	}
+}
+
+// withTelemetry runs a command inside the root span of its trace and exports everything afterwards
+func withTelemetry(name string, fn func() error) error {
+	setupTelemetry()
+	if telemetry == nil {
+		return fn()
+	}
+	err := fn()
+	root := otlpSpan{
+		TraceID: telemetry.traceID, SpanID: telemetry.rootID, Name: "ggufDownloader " + name, Kind: otlpSpanKindInternal,
+		Start: unixNano(telemetry.started), End: unixNano(time.Now()),
+		Attributes: []otlpAttr{attr("ggufdownloader.command", name)},
+	}
+	if err != nil {
+		root.Status = map[string]any{"code": otlpStatusError, "message": redact(err.Error())}
+	}
+	telemetry.finish(root)
+	telemetry.flush()
+	return err
+}
+
+// unixNano renders a time in OTLP's string-encoded nanoseconds
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// requestSpan is an HTTP request whose span is still open
+type requestSpan struct {
+	span  otlpSpan
+	host  string
+	start time.Time
+	bytes int64
+	once  sync.Once
+}
+
+// spanParentKey is the context key of the span that work under the context belongs to
+type spanParentKey struct{}
+
+// parentSpan returns the id of the span in ctx, or the command's root span
+func (t *telemetryExporter) parentSpan(ctx context.Context) string {
+	if id, ok := ctx.Value(spanParentKey{}).(string); ok {
+		return id
+	}
+	return t.rootID
+}
+
+// registryTracer exports the spans of the registry client's operations, so the request
+// spans of a download nest under it
+type registryTracer struct {
+	t *telemetryExporter
+}
+
+func (r registryTracer) Start(ctx context.Context, name string, attrs ...ollamareg.Attr) (context.Context, ollamareg.Span) {
+	s := &operationSpan{t: r.t, start: time.Now()}
+	s.span = otlpSpan{TraceID: r.t.traceID, SpanID: newID(8), ParentSpanID: r.t.parentSpan(ctx), Name: name, Kind: otlpSpanKindInternal}
+	s.SetAttributes(attrs...)
+	return context.WithValue(ctx, spanParentKey{}, s.span.SpanID), s
+}
+
+// operationSpan is a registry client operation whose span is still open; the parallel
+// connections of a download add to it at once
+type operationSpan struct {
+	t     *telemetryExporter
+	mu    sync.Mutex
+	span  otlpSpan
+	start time.Time
+}
+
+func (s *operationSpan) SetAttributes(attrs ...ollamareg.Attr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range attrs {
+		s.span.Attributes = append(s.span.Attributes, attr(a.Key, a.Value))
+	}
+}
+
+func (s *operationSpan) End(err error) {
+	s.mu.Lock()
+	s.span.Start, s.span.End = unixNano(s.start), unixNano(time.Now())
+	if err != nil {
+		s.span.Status = map[string]any{"code": otlpStatusError, "message": redact(err.Error())}
+	}
+	span := s.span
+	s.mu.Unlock()
+	s.t.finish(span)
+}
+
+// tracer returns the registry client's Tracer, nil unless telemetry is exported
+func (t *telemetryExporter) tracer() ollamareg.Tracer {
+	if t == nil {
+		return nil
+	}
+	return registryTracer{t}
+}
+
+// startRequest opens a client span for req, returning a copy of the request that
+// propagates the span with a traceparent header
+func (t *telemetryExporter) startRequest(req *http.Request) (*http.Request, *requestSpan) {
+	if t == nil {
+		return req, nil
+	}
+	s := &requestSpan{host: req.URL.Hostname(), start: time.Now()}
+	s.span = otlpSpan{
+		TraceID: t.traceID, SpanID: newID(8), ParentSpanID: t.parentSpan(req.Context()), Name: req.Method, Kind: otlpSpanKindClient,
+		Attributes: []otlpAttr{
+			attr("http.request.method", req.Method),
+			attr("url.full", redactURL(req.URL)),
+			attr("server.address", req.URL.Hostname()),
+		},
+	}
+	if r := req.Header.Get("Range"); r != "" {
+		s.span.Attributes = append(s.span.Attributes, attr("http.request.header.range", r))
+	}
+	// A RoundTripper must not modify the caller's request
+	req = req.Clone(req.Context())
+	req.Header.Set("traceparent", "00-"+t.traceID+"-"+s.span.SpanID+"-01")
+	return req, s
+}
+
+// response records the outcome of the request; the span ends once the body is consumed
+func (s *requestSpan) response(resp *http.Response, err error) {
+	if s == nil {
+		return
+	}
+	if err != nil {
+		s.span.Status = map[string]any{"code": otlpStatusError, "message": redact(err.Error())}
+		s.end("error")
+		return
+	}
+	s.span.Attributes = append(s.span.Attributes, attr("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= 400 {
+		s.span.Status = map[string]any{"code": otlpStatusError}
+	}
+	resp.Body = &spanBody{ReadCloser: resp.Body, span: s, class: fmt.Sprintf("%dxx", resp.StatusCode/100)}
+}
+
+// end finishes the span once, recording the transferred bytes
+func (s *requestSpan) end(class string) {
+	s.once.Do(func() {
+		s.span.Start, s.span.End = unixNano(s.start), unixNano(time.Now())
+		s.span.Attributes = append(s.span.Attributes, attr("http.response.body.size", s.bytes))
+		telemetry.record(s.host, class, s.bytes)
+		telemetry.finish(s.span)
+	})
+}
+
+// spanBody ends its request's span when the body is drained or closed
+type spanBody struct {
+	io.ReadCloser
+	span  *requestSpan
+	class string
+}
+
+func (b *spanBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.span.bytes += int64(n)
+	if err == io.EOF {
+		b.span.end(b.class)
+	}
+	return n, err
+}
+
+func (b *spanBody) Close() error {
+	b.span.end(b.class)
+	return b.ReadCloser.Close()
+}
+
+// record adds a request to the metrics
+func (t *telemetryExporter) record(host, class string, n int64) {
+	t.mu.Lock()
+	t.requests[host+" "+class]++
+	t.bytes[host] += n
+	t.mu.Unlock()
+}
+
+// finish buffers a span, exporting the buffer in the background once it is full
+func (t *telemetryExporter) finish(span otlpSpan) {
+	t.mu.Lock()
+	t.spans = append(t.spans, span)
+	var batch []otlpSpan
+	if len(t.spans) >= otlpFlushSize {
+		batch, t.spans = t.spans, nil
+	}
+	t.mu.Unlock()
+	if batch != nil {
+		go t.exportSpans(batch)
+	}
+}
+
+// flush exports the buffered spans and the request metrics
+func (t *telemetryExporter) flush() {
+	t.mu.Lock()
+	batch := t.spans
+	t.spans = nil
+	t.mu.Unlock()
+	t.exportSpans(batch)
+	t.exportMetrics()
+}
+
+// exportSpans posts spans to the traces endpoint
+func (t *telemetryExporter) exportSpans(spans []otlpSpan) {
+	if t.tracesURL == "" || len(spans) == 0 {
+		return
+	}
+	t.post(t.tracesURL, map[string]any{"resourceSpans": []any{map[string]any{
+		"resource":   map[string]any{"attributes": t.resource},
+		"scopeSpans": []any{map[string]any{"scope": map[string]any{"name": "ggufDownloader"}, "spans": spans}},
+	}}})
+}
+
+// exportMetrics posts cumulative request and byte counters to the metrics endpoint
+func (t *telemetryExporter) exportMetrics() {
+	if t.metricsURL == "" {
+		return
+	}
+	start, now := unixNano(t.started), unixNano(time.Now())
+	t.mu.Lock()
+	var requests, received []any
+	for key, n := range t.requests {
+		host, class, _ := strings.Cut(key, " ")
+		requests = append(requests, map[string]any{"asInt": strconv.FormatInt(n, 10), "startTimeUnixNano": start, "timeUnixNano": now,
+			"attributes": []otlpAttr{attr("server.address", host), attr("http.response.status_class", class)}})
+	}
+	for host, n := range t.bytes {
+		received = append(received, map[string]any{"asInt": strconv.FormatInt(n, 10), "startTimeUnixNano": start, "timeUnixNano": now,
+			"attributes": []otlpAttr{attr("server.address", host)}})
+	}
+	t.mu.Unlock()
+	if len(requests) == 0 {
+		return
+	}
+
+	sum := func(name, unit, description string, points []any) map[string]any {
+		return map[string]any{"name": name, "unit": unit, "description": description,
+			"sum": map[string]any{"dataPoints": points, "aggregationTemporality": otlpCumulative, "isMonotonic": true}}
+	}
+	t.post(t.metricsURL, map[string]any{"resourceMetrics": []any{map[string]any{
+		"resource": map[string]any{"attributes": t.resource},
+		"scopeMetrics": []any{map[string]any{"scope": map[string]any{"name": "ggufDownloader"}, "metrics": []any{
+			sum("ggufdownloader.http.requests", "{request}", "HTTP requests to the registry, blob storage and ollama.com", requests),
+			sum("ggufdownloader.http.response.bytes", "By", "Response body bytes received", received),
+		}}},
+	}}})
+}
+
+// post sends one OTLP request; telemetry never fails a command, so errors are only warned about
+func (t *telemetryExporter) post(url string, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Invalid OTLP endpoint %s: %s", url, err))
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Could not export telemetry: %s", err))
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Could not export telemetry to %s: %s", url, resp.Status))
+	}
+}
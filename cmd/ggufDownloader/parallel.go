@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"ggufDownloader/pkg/ollamareg"
+)
+
+// downloadConnections overrides the automatic number of connections per download, set
+// with -connections; 0 chooses from the blob size and the measured round trip
+var downloadConnections int
+
+// downloadChunkSize overrides the automatic size of the ranges a split download
+// requests, set with -chunk-size; 0 chooses from the blob size
+var downloadChunkSize int64
+
+// connectionBudget bounds the transfer connections open at once across every download
+// in the process, set with -max-connections; nil leaves them unbounded
+var connectionBudget *ollamareg.ConnectionBudget
+
+// setConnectionBudget allows n transfer connections at once; 0 removes the bound
+func setConnectionBudget(n int) {
+	connectionBudget = ollamareg.NewConnectionBudget(n)
+}
+
+// singleStream is set once a registry response shows that an intermediary rewrites
+// downloads; from then on partial files are neither resumed nor kept resumable, and
+// every download is verified against its digest
+var singleStream atomic.Bool
+
+// recordDigest stores the digest a download computed while writing filename in the
+// checksum cache, so verifying the file afterwards is instant
+func recordDigest(filename, digest string) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return
+	}
+	if info, err := os.Stat(abs); err == nil {
+		storeDigest(abs, info, digest)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/fatih/color"
+)
+
+// hashLimiter caps how fast files are read for hashing, bounding the CPU it takes; nil means unlimited
+var hashLimiter *processLimiter
+
+// throttleHashing applies the hashing limit to r, if one is set
+func throttleHashing(r io.Reader) io.Reader {
+	if hashLimiter == nil {
+		return r
+	}
+	return &rateLimitedReader{r: r, limiter: hashLimiter}
+}
+
+// setupResourceLimits lowers the process priority when background is set and caps hashing at hashRate
+func setupResourceLimits(background bool, hashRate string) error {
+	if hashRate != "" {
+		bytesPerSec, err := parseSize(hashRate)
+		if err != nil {
+			return err
+		}
+		if bytesPerSec <= 0 {
+			return fmt.Errorf("invalid hash rate %q", hashRate)
+		}
+		hashLimiter = &processLimiter{rate: float64(bytesPerSec)}
+	}
+	if background {
+		// A failure only costs responsiveness, so it is not worth aborting for
+		if err := lowerPriority(); err != nil {
+			fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Could not lower process priority: %s", err))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+//go:build darwin
+
+package main
+
+import "golang.org/x/sys/unix"
+
+// Darwin's background band, from sys/resource.h
+const (
+	prioDarwinProcess = 4
+	prioDarwinBG      = 0x1000
+)
+
+// lowerPriority moves the process into the background band, which throttles both its CPU and disk I/O
+func lowerPriority() error {
+	return unix.Setpriority(prioDarwinProcess, 0, prioDarwinBG)
+}
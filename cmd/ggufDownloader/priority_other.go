@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !windows
+
+package main
+
+import "errors"
+
+// lowerPriority is not implemented on this platform
+func lowerPriority() error {
+	return errors.New("lowering priority is not supported on this platform")
+}
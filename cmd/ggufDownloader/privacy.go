@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// privacyMode limits every request to the registry host, set by -privacy or "privacy"
+// in the profile; searching ollama.com, Hugging Face lookups and telemetry are refused
+var privacyMode bool
+
+// PrivacyError reports a request that privacy mode refused to send
+type PrivacyError struct {
+	Host string
+}
+
+func (e *PrivacyError) Error() string {
+	return fmt.Sprintf("privacy mode: not contacting %s (only %s is allowed)", e.Host, RegistryHost)
+}
+
+// privacyTransport refuses requests to any host but the registry. Redirects the registry
+// itself answers with, such as blobs served from a CDN, are followed.
+type privacyTransport struct {
+	next http.RoundTripper
+}
+
+func (t *privacyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	origin := req
+	for origin.Response != nil && origin.Response.Request != nil {
+		origin = origin.Response.Request
+	}
+	if origin.URL.Host != RegistryHost {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, &PrivacyError{Host: req.URL.Host}
+	}
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/schollz/progressbar/v3"
+	"golang.org/x/term"
+)
+
+// plainOutput selects line-based progress for terminals that garble carriage returns
+var plainOutput bool
+
+// consoleErr is the process's real standard error, kept for the final error message
+// while -q sends everything else written to os.Stderr to the null device
+var consoleErr = os.Stderr
+
+// quietOutput is set by -q: no status messages, warnings, progress or prompts
+var quietOutput bool
+
+// plainProgressInterval is how often the plain renderer prints a progress line
+const plainProgressInterval = 5 * time.Second
+
+// progressWriter counts bytes written to it and renders progress
+type progressWriter interface {
+	io.Writer
+	Finish() error
+}
+
+// renderers are the accepted values of -renderer
+var renderers = []string{"auto", "plain", "bar", "tui"}
+
+// rendererMode is the renderer in use once "auto" is resolved: "plain" prints lines,
+// "bar" redraws progress bars, and "tui" also allows full-screen interfaces like find -i
+var rendererMode = "tui"
+
+// detectRenderer picks a renderer the console can display. Output that is not a
+// terminal, TERM=dumb or unset (serial consoles), and CI runners get plain lines, since
+// redrawn bars turn into walls of carriage returns in their logs.
+func detectRenderer() string {
+	if !term.IsTerminal(int(os.Stderr.Fd())) || os.Getenv("CI") != "" {
+		return "plain"
+	}
+	if t := os.Getenv("TERM"); t == "dumb" || (t == "" && runtime.GOOS != "windows") {
+		return "plain"
+	}
+	return "tui"
+}
+
+// setupConsole selects the renderer and prepares the terminal for colored output,
+// falling back to plain rendering when ANSI escapes cannot be enabled
+func setupConsole(renderer string) error {
+	if !slices.Contains(renderers, renderer) {
+		return fmt.Errorf("unknown -renderer %q (use %s)", renderer, strings.Join(renderers, ", "))
+	}
+	if renderer == "auto" {
+		renderer = detectRenderer()
+	}
+	rendererMode = renderer
+	plainOutput = renderer == "plain"
+	// The bar itself only needs carriage returns, which legacy consoles handle fine
+	if plainOutput || !enableVirtualTerminal() {
+		color.NoColor = true
+	}
+	return nil
+}
+
+// silenceStatus discards what is written to os.Stderr from now on, like wget -q
+func silenceStatus() error {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	quietOutput = true
+	os.Stderr = devNull
+	return nil
+}
+
+// interactive reports whether the user can be asked questions; with -q the prompt
+// would be invisible, so the non-interactive answer is used instead
+func interactive() bool {
+	return !quietOutput && term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// newProgress returns the progress renderer for a transfer of total bytes (-1 if unknown)
+func newProgress(total int64, description string) progressWriter {
+	if plainOutput {
+		return observeProgress(&plainProgress{description: description, total: total, started: time.Now()}, total, 0, description)
+	}
+	return observeProgress(progressbar.DefaultBytes(total, description), total, 0, description)
+}
+
+// newProgressAt returns the progress renderer for a transfer that resumes at offset bytes
+func newProgressAt(total, offset int64, description string) progressWriter {
+	if offset <= 0 {
+		return newProgress(total, description)
+	}
+	if plainOutput {
+		return observeProgress(&plainProgress{description: description, total: total, current: offset, base: offset, started: time.Now()}, total, offset, description)
+	}
+	r := &resumedProgress{description: description, total: total, current: offset, base: offset, started: time.Now()}
+	// The bar's own rate and ETA would count the resumed bytes as if they had just
+	// arrived, so it only draws the percentage and the label carries the figures
+	r.bar = progressbar.NewOptions64(total,
+		progressbar.OptionSetDescription(r.label()),
+		progressbar.OptionSetWriter(os.Stderr),
+		progressbar.OptionSetWidth(10),
+		progressbar.OptionThrottle(65*time.Millisecond),
+		progressbar.OptionSetPredictTime(false),
+		progressbar.OptionSetElapsedTime(false),
+		progressbar.OptionOnCompletion(func() {
+			fmt.Fprint(os.Stderr, "\n")
+		}),
+		progressbar.OptionSpinnerType(14),
+		progressbar.OptionFullWidth(),
+	)
+	r.bar.Set64(offset)
+	return observeProgress(r, total, offset, description)
+}
+
+// resumedProgressInterval is how often a resumed bar's rate and ETA are refreshed
+const resumedProgressInterval = 500 * time.Millisecond
+
+// resumedProgress draws a bar that starts at the resumed offset, with the rate and ETA
+// computed from the bytes transferred in this session only
+type resumedProgress struct {
+	bar         *progressbar.ProgressBar
+	description string
+	total       int64
+	current     int64
+	base        int64
+	started     time.Time
+	labelled    time.Time
+}
+
+func (r *resumedProgress) Write(b []byte) (int, error) {
+	r.current += int64(len(b))
+	if time.Since(r.labelled) >= resumedProgressInterval {
+		r.bar.Describe(r.label())
+	}
+	return r.bar.Write(b)
+}
+
+// Describe changes the label shown before the figures
+func (r *resumedProgress) Describe(description string) {
+	r.description = description
+	r.bar.Describe(r.label())
+}
+
+// Finish completes the bar with the final figures
+func (r *resumedProgress) Finish() error {
+	r.bar.Describe(r.label())
+	return r.bar.Finish()
+}
+
+// label renders the description with the byte counts, the session rate and the ETA
+func (r *resumedProgress) label() string {
+	r.labelled = time.Now()
+	figures := fmt.Sprintf("%s / %s", formatBytes(r.current), formatBytes(r.total))
+	if rate := sessionRate(r.current-r.base, r.started); rate > 0 {
+		figures += fmt.Sprintf(", %s/s%s", formatBytes(int64(rate)), etaSuffix(r.total-r.current, rate))
+	}
+	return fmt.Sprintf("%s (%s)", r.description, figures)
+}
+
+// sessionRate returns the bytes per second transferred since started
+func sessionRate(transferred int64, started time.Time) float64 {
+	elapsed := time.Since(started).Seconds()
+	if elapsed <= 0 || transferred <= 0 {
+		return 0
+	}
+	return float64(transferred) / elapsed
+}
+
+// etaSuffix renders the time left for remaining bytes at rate, or "" when unknown
+func etaSuffix(remaining int64, rate float64) string {
+	if remaining <= 0 || rate <= 0 {
+		return ""
+	}
+	eta := time.Duration(float64(remaining) / rate * float64(time.Second))
+	return ", ETA " + eta.Round(time.Second).String()
+}
+
+// bundleProgress tracks a download made of several layers, giving each layer its own
+// named bar and keeping the overall percentage in the bar's description
+type bundleProgress struct {
+	total int64
+	done  int64
+	count int
+	index int
+}
+
+// newBundleProgress returns the progress tracker for count layers totalling total bytes
+func newBundleProgress(count int, total int64) *bundleProgress {
+	return &bundleProgress{total: total, count: count}
+}
+
+// layer returns the progress renderer for the next layer, whose first offset bytes are
+// already present
+func (b *bundleProgress) layer(name string, size, offset int64) *layerProgress {
+	b.index++
+	b.done += offset
+	l := &layerProgress{bundle: b, name: name, percent: -1, counted: offset}
+	l.bar = newProgressAt(size, offset, l.description())
+	return l
+}
+
+// skip leaves out a layer that needed no download, such as one served from the blob cache
+func (b *bundleProgress) skip(size int64) {
+	b.count--
+	b.total -= size
+	b.summarize()
+}
+
+// summarize prints the overall totals once the last layer is complete
+func (b *bundleProgress) summarize() {
+	if b.index == b.count && b.index > 0 {
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Downloaded %d layers, %s in total", b.count, formatBytes(b.done)))
+	}
+}
+
+// layerProgress renders one layer of a bundle and feeds the overall total
+type layerProgress struct {
+	bundle  *bundleProgress
+	bar     progressWriter
+	name    string
+	percent int
+	// counted is what the layer has added to the bundle's done bytes
+	counted int64
+}
+
+// restart renders the layer again from offset, for a download that was re-dialed or
+// started over
+func (l *layerProgress) restart(size, offset int64) {
+	l.bundle.done += offset - l.counted
+	l.counted = offset
+	l.bar = newProgressAt(size, offset, l.description())
+}
+
+// description labels the bar with the layer's position, name and the overall progress
+func (l *layerProgress) description() string {
+	b := l.bundle
+	if b.total <= 0 {
+		return fmt.Sprintf("[%d/%d] %s", b.index, b.count, l.name)
+	}
+	l.percent = int(b.done * 100 / b.total)
+	return fmt.Sprintf("[%d/%d] %s (overall %d%%)", b.index, b.count, l.name, l.percent)
+}
+
+func (l *layerProgress) Write(p []byte) (int, error) {
+	l.bundle.done += int64(len(p))
+	l.counted += int64(len(p))
+	if b := l.bundle; b.total > 0 && int(b.done*100/b.total) != l.percent {
+		if d, ok := l.bar.(interface{ Describe(string) }); ok {
+			d.Describe(l.description())
+		}
+	}
+	return l.bar.Write(p)
+}
+
+// Finish completes the layer's bar, printing the overall totals after the last layer
+func (l *layerProgress) Finish() error {
+	err := l.bar.Finish()
+	l.bundle.summarize()
+	return err
+}
+
+// bundleLayerKey is the context key of the bundle layer a download renders into
+type bundleLayerKey struct{}
+
+// bundleLayer is a layer of a bundle downloaded through pullFile, which renders its
+// progress into the bundle instead of a bar of its own
+type bundleLayer struct {
+	bundle   *bundleProgress
+	name     string
+	progress *layerProgress
+}
+
+// withBundleLayer makes the download made under the returned context render as the next
+// layer of b, named name
+func withBundleLayer(ctx context.Context, b *bundleProgress, name string) (context.Context, *bundleLayer) {
+	l := &bundleLayer{bundle: b, name: name}
+	return context.WithValue(ctx, bundleLayerKey{}, l), l
+}
+
+// render returns the layer's renderer for a transfer of total bytes starting at offset
+func (l *bundleLayer) render(total, offset int64) progressWriter {
+	if l.progress == nil {
+		l.progress = l.bundle.layer(l.name, total, offset)
+	} else {
+		l.progress.restart(total, offset)
+	}
+	return l.progress
+}
+
+// plainProgress prints a full line every few seconds instead of redrawing in place
+type plainProgress struct {
+	description string
+	total       int64
+	current     int64
+	base        int64
+	started     time.Time
+	lastPrint   time.Time
+}
+
+func (p *plainProgress) Write(b []byte) (int, error) {
+	p.current += int64(len(b))
+	if time.Since(p.lastPrint) >= plainProgressInterval {
+		p.print()
+	}
+	return len(b), nil
+}
+
+// Describe changes the label printed on each line
+func (p *plainProgress) Describe(description string) {
+	p.description = description
+}
+
+// Finish prints the final totals
+func (p *plainProgress) Finish() error {
+	p.print()
+	return nil
+}
+
+func (p *plainProgress) print() {
+	p.lastPrint = time.Now()
+
+	// Resumed bytes count toward the percentage but not the rate or ETA
+	bytesPerSecond := sessionRate(p.current-p.base, p.started)
+	rate := ""
+	if bytesPerSecond > 0 {
+		rate = fmt.Sprintf(", %s/s", formatBytes(int64(bytesPerSecond)))
+	}
+
+	if p.total > 0 {
+		percent := float64(p.current) * 100 / float64(p.total)
+		fmt.Fprintf(os.Stderr, "%s: %s / %s (%.1f%%%s%s)\n", p.description,
+			formatBytes(p.current), formatBytes(p.total), percent, rate, etaSuffix(p.total-p.current, bytesPerSecond))
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s: %s%s\n", p.description, formatBytes(p.current), rate)
+}
+
+// parseSize parses sizes such as "512M", "20G" or "1.5TiB" into bytes using binary units
+func parseSize(s string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	str = strings.TrimSuffix(strings.TrimSuffix(str, "B"), "I")
+
+	multiplier := int64(1)
+	if str != "" {
+		if i := strings.IndexByte("KMGTPE", str[len(str)-1]); i >= 0 {
+			multiplier = int64(1) << (10 * (i + 1))
+			str = str[:len(str)-1]
+		}
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q (examples: 500M, 20G)", s)
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+// formatBytes renders a byte count with a binary unit suffix
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/fatih/color"
+
+	"ggufDownloader/pkg/ollamareg"
+)
+
+// ProjectorFile is a vision projector downloaded alongside a model's weights
+type ProjectorFile struct {
+	// File is the projector's path, next to the model
+	File   string `json:"file"`
+	Digest string `json:"digest"`
+	Size   int64  `json:"size"`
+}
+
+// projectorPath returns where the nth vision projector of the model saved as filename
+// goes: llama.cpp's model.mmproj.gguf next to model.gguf, numbered from the second on
+func projectorPath(filename string, n int) string {
+	base := strings.TrimSuffix(filename, ".gguf")
+	if n > 0 {
+		return fmt.Sprintf("%s.mmproj-%d.gguf", base, n+1)
+	}
+	return base + ".mmproj.gguf"
+}
+
+// projectorPattern matches the names projectorPath gives, and their partial downloads
+var projectorPattern = regexp.MustCompile(`\.mmproj(-\d+)?\.gguf(\.part)?$`)
+
+// isProjector reports whether path is a vision projector saved next to a model's weights
+func isProjector(path string) bool {
+	return projectorPattern.MatchString(path)
+}
+
+// modelFiles returns the .gguf files in dir, leaving out the vision projectors saved
+// next to the weights, which are no models of their own
+func modelFiles(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.gguf"))
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(files, isProjector), nil
+}
+
+// projectorsBeside returns the vision projectors, complete or partial, saved next to the
+// model at path
+func projectorsBeside(path string) []string {
+	prefix := filepath.Base(strings.TrimSuffix(path, ".gguf")) + ".mmproj"
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), prefix) && isProjector(e.Name()) && !e.IsDir() {
+			files = append(files, filepath.Join(filepath.Dir(path), e.Name()))
+		}
+	}
+	return files
+}
+
+// pullProjectors downloads the vision projectors of a multimodal model next to its
+// weights saved as filename. They go through the same FILE.part, resume, blob cache,
+// verification and scan steps as the weights.
+func pullProjectors(ctx context.Context, modelName string, manifest *Manifest, filename string, opts PullOptions, scanHook string) ([]ProjectorFile, error) {
+	var pulled []ProjectorFile
+	for n, layer := range manifest.LayersByType(MediaTypeProjector) {
+		path := projectorPath(filename, n)
+		if _, err := os.Stat(path); err == nil && opts.IfExists == "skip" {
+			fmt.Fprintln(os.Stderr, color.YellowString("[WARN] %s already exists, skipping", path))
+			pulled = append(pulled, ProjectorFile{File: path, Digest: layer.Digest, Size: layer.Size})
+			continue
+		}
+		if err := pullLayerFile(ctx, modelName, layer, path, "vision projector", ollamareg.CheckGGUF, opts, scanHook); err != nil {
+			return pulled, fmt.Errorf("vision projector %s: %w", path, err)
+		}
+		pulled = append(pulled, ProjectorFile{File: path, Digest: layer.Digest, Size: layer.Size})
+	}
+	return pulled, nil
+}
+
+// pullLayerFile places a large layer other than the weights, such as a projector, at
+// path, from the blob cache when it holds the layer and from the registry otherwise.
+// what names the layer in messages; check inspects the first bytes like for downloadChecked.
+func pullLayerFile(ctx context.Context, modelName string, layer Layer, path, what string, check func(*http.Response, []byte) error, opts PullOptions, scanHook string) error {
+	return pullFile(ctx, blobURL(modelName, layer.Digest), layer.Digest, path, what, check, opts, scanHook)
+}
+
+// pullFile places the file at url with the given digest at path, from the blob cache
+// when it holds the digest, resuming path.part when an earlier pull left one. Without a
+// digest the file is neither cached nor verified. The file is scanned with scanHook,
+// when set, before it gets its name.
+func pullFile(ctx context.Context, url, digest, path, what string, check func(*http.Response, []byte) error, opts PullOptions, scanHook string) error {
+	partial := partialPath(path)
+	if digest != "" {
+		cached, err := materializeBlob(digest, path, opts.CrossDevice)
+		if err != nil {
+			return err
+		}
+		if cached {
+			fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Using cached blob for %s", path))
+			discardPartial(ctx, partial)
+			if err := scanFile(path, digest, scanHook); err != nil {
+				return err
+			}
+			copyFinished(path, copyPaths(ctx, path))
+			return nil
+		}
+	}
+
+	var resumeFrom int64
+	if info, err := os.Stat(partial); err == nil {
+		if !ollamareg.CanResume(partial, url) {
+			discardPartial(ctx, partial)
+		} else {
+			resumeFrom = info.Size()
+		}
+	}
+	if resumeFrom > 0 {
+		fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Resuming %s %s at %s...", what, path, formatBytes(resumeFrom)))
+	} else {
+		fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Downloading %s %s...", what, path))
+	}
+	if err := downloadChecked(ctx, url, partial, nil, resumeFrom, check); err != nil {
+		_, stateErr := os.Stat(ollamareg.ResumeStatePath(partial))
+		keepPartial(ctx, partial, stateErr == nil)
+		return err
+	}
+	if opts.NoVerify {
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Skipping verification of %s (-no-verify); run verify on it later", path))
+	} else if digest == "" {
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] No digest is published for %s, so it cannot be verified", path))
+	} else if err := verifyFile(partial, digest); err != nil {
+		discardPartial(ctx, partial)
+		return fmt.Errorf("%w; the download was discarded, so the next pull starts over", err)
+	}
+	if err := scanFile(partial, digest, scanHook); err != nil {
+		discardPartial(ctx, partial)
+		return err
+	}
+	if err := finishPartial(ctx, partial, path); err != nil {
+		return err
+	}
+	if !opts.NoVerify && digest != "" {
+		cacheBlob(path, digest)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptrace"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxProvenanceHops bounds the chain kept for one pull
+const maxProvenanceHops = 100
+
+// ProvenanceHop is one link in the chain from a model reference to the bytes on disk:
+// a request that was made, or metadata that was reused instead
+type ProvenanceHop struct {
+	// Step is "search", "manifest", "blob", "redirect", "request" or "cache"
+	Step string    `json:"step"`
+	Time time.Time `json:"time"`
+	// URL has its query string redacted, as signed CDN URLs carry credentials
+	URL     string `json:"url,omitempty"`
+	Status  string `json:"status,omitempty"`
+	Digest  string `json:"digest,omitempty"`
+	Address string `json:"address,omitempty"`
+	Detail  string `json:"detail,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// provenance collects the hops of one pull; requests made concurrently, like the
+// ranges of a split download, all land here
+type provenance struct {
+	mu   sync.Mutex
+	hops []ProvenanceHop
+}
+
+// provenanceKey carries the provenance of a pull down to its requests
+type provenanceKey struct{}
+
+// withProvenance starts recording the requests made under ctx. The chain continues any
+// recorded by an enclosing pull, so the tag search that chose a tag leads each pull it starts.
+func withProvenance(ctx context.Context) context.Context {
+	p := &provenance{}
+	if parent := provenanceFrom(ctx); parent != nil {
+		p.hops = parent.chain()
+	}
+	return context.WithValue(ctx, provenanceKey{}, p)
+}
+
+func provenanceFrom(ctx context.Context) *provenance {
+	p, _ := ctx.Value(provenanceKey{}).(*provenance)
+	return p
+}
+
+// addProvenance records a hop that made no request, such as a manifest reused from the
+// metadata cache; without a recording pull it does nothing
+func addProvenance(ctx context.Context, hop ProvenanceHop) {
+	if p := provenanceFrom(ctx); p != nil {
+		hop.Time = time.Now().UTC()
+		p.add(hop)
+	}
+}
+
+// add appends a hop unless an identical one is already recorded
+func (p *provenance) add(hop ProvenanceHop) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, h := range p.hops {
+		if h.Step == hop.Step && h.URL == hop.URL && h.Status == hop.Status && h.Address == hop.Address && h.Error == hop.Error {
+			return
+		}
+	}
+	if len(p.hops) < maxProvenanceHops {
+		p.hops = append(p.hops, hop)
+	}
+}
+
+// chain returns a copy of the hops recorded so far
+func (p *provenance) chain() []ProvenanceHop {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]ProvenanceHop(nil), p.hops...)
+}
+
+// provenanceChain returns the hops recorded under ctx, or nil if none are
+func provenanceChain(ctx context.Context) []ProvenanceHop {
+	if p := provenanceFrom(ctx); p != nil {
+		return p.chain()
+	}
+	return nil
+}
+
+// traceProvenance prepares req for recording when it belongs to a pull, returning the
+// request to send and a function that records its outcome
+func traceProvenance(req *http.Request) (*http.Request, func(*http.Response, error)) {
+	p := provenanceFrom(req.Context())
+	if p == nil {
+		return req, func(*http.Response, error) {}
+	}
+	hop := ProvenanceHop{Step: hopStep(req), Time: time.Now().UTC(), URL: redactURL(req.URL)}
+	if base := path.Base(req.URL.Path); isDigest(base) {
+		hop.Digest = base
+	}
+	var mu sync.Mutex
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			mu.Lock()
+			hop.Address = info.Conn.RemoteAddr().String()
+			mu.Unlock()
+		},
+	}))
+	return req, func(resp *http.Response, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			hop.Error = redact(err.Error())
+		} else {
+			hop.Status = resp.Status
+			if d := resp.Header.Get("Docker-Content-Digest"); d != "" {
+				hop.Digest = d
+			}
+		}
+		p.add(hop)
+	}
+}
+
+// hopStep names what a request was for from its URL
+func hopStep(req *http.Request) string {
+	p := req.URL.Path
+	switch {
+	case req.Response != nil:
+		return "redirect"
+	case strings.Contains(p, "/manifests/"):
+		return "manifest"
+	case strings.HasSuffix(p, "/tags/list"), strings.HasPrefix(p, "/api/models") && req.URL.Query().Has("search"):
+		return "search"
+	case strings.Contains(p, "/blobs/"):
+		return "blob"
+	}
+	return "request"
+}
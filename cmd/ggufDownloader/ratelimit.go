@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// How often a rate-limited process announces itself, and when a silent peer no longer counts
+const (
+	rateHeartbeatInterval = 2 * time.Second
+	ratePeerTimeout       = 3 * rateHeartbeatInterval
+)
+
+// downloadLimiter caps the download rate of every process on the machine together; nil means unlimited
+var downloadLimiter *machineLimiter
+
+// machineLimiter splits a machine-wide byte rate evenly between the processes that are
+// currently downloading. Each one touches a heartbeat file in a shared directory and
+// takes total/peers as its own budget, so no daemon or lock server is needed.
+type machineLimiter struct {
+	total     float64
+	dir       string
+	path      string
+	mu        sync.Mutex
+	share     float64
+	bucket    tokenBucket
+	refreshed time.Time
+}
+
+// tokenBucket paces a byte stream to a rate, allowing at most one second of burst
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take spends n bytes at rate bytes per second and returns how long to sleep to stay within it
+func (b *tokenBucket) take(n int, rate float64) time.Duration {
+	now := time.Now()
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * rate
+	}
+	if b.tokens > rate {
+		b.tokens = rate
+	}
+	b.last = now
+
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / rate * float64(time.Second))
+}
+
+// newMachineLimiter returns a limiter for bytesPerSec shared across the machine
+func newMachineLimiter(bytesPerSec int64) (*machineLimiter, error) {
+	base, err := dataDir()
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(base, "ratelimit")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &machineLimiter{
+		total: float64(bytesPerSec),
+		dir:   dir,
+		path:  filepath.Join(dir, fmt.Sprintf("%d", os.Getpid())),
+		share: float64(bytesPerSec),
+	}, nil
+}
+
+// refresh renews this process's heartbeat and recomputes its share from the live peers
+func (l *machineLimiter) refresh(now time.Time) {
+	l.refreshed = now
+	if err := os.WriteFile(l.path, nil, 0o644); err != nil {
+		return
+	}
+
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return
+	}
+	peers := 0
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if now.Sub(info.ModTime()) > ratePeerTimeout {
+			// A process that crashed never removes its file
+			os.Remove(filepath.Join(l.dir, e.Name()))
+			continue
+		}
+		peers++
+	}
+	if peers < 1 {
+		peers = 1
+	}
+	l.share = l.total / float64(peers)
+}
+
+// wait blocks until n more bytes fit within this process's share
+func (l *machineLimiter) wait(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now := time.Now(); now.Sub(l.refreshed) >= rateHeartbeatInterval {
+		l.refresh(now)
+	}
+	time.Sleep(l.bucket.take(n, l.share))
+}
+
+// close withdraws this process so the others take over its share
+func (l *machineLimiter) close() {
+	os.Remove(l.path)
+}
+
+// byteLimiter paces a stream of bytes
+type byteLimiter interface {
+	wait(n int)
+}
+
+// processLimiter caps a byte rate within this process only
+type processLimiter struct {
+	rate   float64
+	mu     sync.Mutex
+	bucket tokenBucket
+}
+
+// wait blocks until n more bytes fit within the rate
+func (l *processLimiter) wait(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	time.Sleep(l.bucket.take(n, l.rate))
+}
+
+// rateLimitedReader throttles reads through a limiter
+type rateLimitedReader struct {
+	r       io.Reader
+	limiter byteLimiter
+}
+
+// rateChunk keeps individual waits short so the rate stays smooth
+const rateChunk = 32 << 10
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	if len(p) > rateChunk {
+		p = p[:rateChunk]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.limiter.wait(n)
+	}
+	return n, err
+}
+
+// throttle applies the machine-wide download limit to r, if one is set
+func throttle(r io.Reader) io.Reader {
+	if downloadLimiter == nil {
+		return r
+	}
+	return &rateLimitedReader{r: r, limiter: downloadLimiter}
+}
+
+// setupRateLimit configures the machine-wide download limit from a size such as "10M"
+func setupRateLimit(limit string) error {
+	if limit == "" {
+		return nil
+	}
+	bytesPerSec, err := parseSize(limit)
+	if err != nil {
+		return err
+	}
+	if bytesPerSec <= 0 {
+		return fmt.Errorf("invalid rate limit %q", limit)
+	}
+	downloadLimiter, err = newMachineLimiter(bytesPerSec)
+	return err
+}
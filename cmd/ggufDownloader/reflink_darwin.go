@@ -0,0 +1,10 @@
+//go:build darwin
+
+package main
+
+import "golang.org/x/sys/unix"
+
+// reflinkFile makes dst a copy-on-write clone of src, which APFS supports
+func reflinkFile(src, dst string) error {
+	return unix.Clonefile(src, dst, 0)
+}
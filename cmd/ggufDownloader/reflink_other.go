@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package main
+
+import "errors"
+
+// reflinkFile is not available on this platform
+func reflinkFile(src, dst string) error {
+	return errors.ErrUnsupported
+}
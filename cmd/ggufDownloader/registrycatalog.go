@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// nextLinkPattern extracts the next page from an RFC 5988 Link header
+var nextLinkPattern = regexp.MustCompile(`<([^>]+)>\s*;\s*rel="?next"?`)
+
+// fetchRepositories pages through the registry's /v2/_catalog, calling fn with each repository
+func fetchRepositories(ctx context.Context, pageSize int, fn func(repo string) error) error {
+	next := registryURL(fmt.Sprintf("_catalog?n=%d", pageSize))
+	for next != "" {
+		resp, err := httpGet(ctx, next)
+		if err != nil {
+			return err
+		}
+		var page struct {
+			Repositories []string `json:"repositories"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return &StatusError{Op: "list repositories", StatusCode: resp.StatusCode, Status: resp.Status}
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return errors.New("invalid JSON response")
+		}
+
+		for _, repo := range page.Repositories {
+			if err := fn(repo); err != nil {
+				return err
+			}
+		}
+
+		next = ""
+		if m := nextLinkPattern.FindStringSubmatch(resp.Header.Get("Link")); m != nil {
+			// The link is usually relative to the registry
+			if u, err := resp.Request.URL.Parse(m[1]); err == nil {
+				next = u.String()
+			}
+		}
+	}
+	return nil
+}
+
+// modelRef turns a repository path back into the name accepted by pull
+func modelRef(repo string) string {
+	return strings.TrimPrefix(repo, defaultNamespace+"/")
+}
+
+var registryCatalogCommand = &Command{
+	Name:    "catalog",
+	Summary: "List the model repositories of a registry (for private OCI registries)",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		withTags := fs.Bool("tags", false, "Also list the tags of every repository")
+		filter := fs.String("filter", "", "Only show repositories whose name contains this text")
+		pageSize := fs.Int("n", 100, "Repositories to request per page")
+		return func(args []string) error {
+			if len(args) != 0 {
+				return errors.New("usage: catalog [-tags] [-filter TEXT] [-n N]")
+			}
+			if *pageSize < 1 {
+				return errors.New("-n must be at least 1")
+			}
+			ctx := context.Background()
+			fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Listing repositories on %s", RegistryHost))
+
+			count := 0
+			err := fetchRepositories(ctx, *pageSize, func(repo string) error {
+				name := modelRef(repo)
+				if *filter != "" && !strings.Contains(strings.ToLower(name), strings.ToLower(*filter)) {
+					return nil
+				}
+				count++
+				if !*withTags {
+					fmt.Println(color.GreenString(name))
+					return nil
+				}
+				tags, err := fetchTags(ctx, name)
+				if err != nil {
+					fmt.Printf("%s  %s\n", color.GreenString(name), color.YellowString("(%s)", err))
+					return nil
+				}
+				fmt.Printf("%s  %s\n", color.GreenString(name), strings.Join(tags, ", "))
+				return nil
+			})
+			var status *StatusError
+			if errors.As(err, &status) && RegistryHost == DefaultRegistryHost {
+				return fmt.Errorf("%w; %s does not publish a catalog, point -registry-host at a private registry", err, DefaultRegistryHost)
+			}
+			if err != nil {
+				return err
+			}
+			if count == 0 {
+				fmt.Fprintln(os.Stderr, color.YellowString("[WARN] No repositories found"))
+			}
+			return nil
+		}
+	},
+}
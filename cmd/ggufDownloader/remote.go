@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/fatih/color"
+
+	"ggufDownloader/pkg/ollamareg"
+)
+
+// remoteTarget is an ssh://[user@]host[:port]/path destination for -output
+type remoteTarget struct {
+	Dest string // user@host as ssh expects it
+	Port string
+	Path string // relative paths are below the remote home directory
+}
+
+// isRemoteOutput reports whether an output names a remote machine rather than a file
+func isRemoteOutput(output string) bool {
+	return strings.HasPrefix(output, "ssh://")
+}
+
+// parseRemoteTarget parses an ssh:// URL; "ssh://host/~/models/" is relative to the
+// remote home directory and a path ending in "/" is a directory
+func parseRemoteTarget(output string) (*remoteTarget, error) {
+	u, err := url.Parse(output)
+	if err != nil || u.Scheme != "ssh" || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid -output %q (expected ssh://[user@]host[:port]/path)", output)
+	}
+	t := &remoteTarget{Dest: u.Hostname(), Port: u.Port(), Path: u.Path}
+	if u.User != nil {
+		t.Dest = u.User.Username() + "@" + t.Dest
+	}
+	switch {
+	case t.Path == "" || t.Path == "/" || t.Path == "/~" || t.Path == "/~/":
+		t.Path = ""
+	case strings.HasPrefix(t.Path, "/~/"):
+		t.Path = strings.TrimPrefix(t.Path, "/~/")
+	}
+	return t, nil
+}
+
+// file returns the remote path of name, placing it inside the target when it is a directory
+func (t *remoteTarget) file(name string) string {
+	if t.Path == "" {
+		return name
+	}
+	if strings.HasSuffix(t.Path, "/") {
+		return t.Path + name
+	}
+	return t.Path
+}
+
+// remoteHashCommands are the shell commands that hash a file with each digest
+// algorithm, trying the coreutils tool first and the Perl one found on macOS second
+var remoteHashCommands = map[string]string{
+	"sha256": "sha256sum -- FILE 2>/dev/null || shasum -a 256 FILE",
+	"sha384": "sha384sum -- FILE 2>/dev/null || shasum -a 384 FILE",
+	"sha512": "sha512sum -- FILE 2>/dev/null || shasum -a 512 FILE",
+	"blake3": "b3sum -- FILE",
+}
+
+// receiveCommand runs a script on the remote machine that stores stdin as dest, keeping
+// it only if its hash matches digest
+func (t *remoteTarget) receiveCommand(ctx context.Context, dest, digest string) *exec.Cmd {
+	tmp := dest + ".part"
+	algo := digestAlgorithm(digest)
+	script := strings.Join([]string{
+		"set -e",
+		"mkdir -p -- " + shellQuote(path.Dir(dest)),
+		"cat > " + shellQuote(tmp),
+		"sum=$( (" + strings.ReplaceAll(remoteHashCommands[algo], "FILE", shellQuote(tmp)) + ") | cut -d' ' -f1)",
+		`if [ "` + algo + `:$sum" != ` + shellQuote(digest) + ` ]; then rm -f -- ` + shellQuote(tmp) +
+			`; echo "digest mismatch: got ` + algo + `:$sum" >&2; exit 3; fi`,
+		"mv -f -- " + shellQuote(tmp) + " " + shellQuote(dest),
+	}, "\n")
+
+	args := []string{}
+	if t.Port != "" {
+		args = append(args, "-p", t.Port)
+	}
+	args = append(args, "--", t.Dest, "sh -c "+shellQuote(script))
+	cmd := exec.CommandContext(ctx, "ssh", args...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+// pullToRemote streams a model blob to a remote machine over SSH without storing it
+// locally; the remote side verifies the digest before the file takes its final name
+func pullToRemote(ctx context.Context, modelName, modelParameters string, opts PullOptions) (string, error) {
+	switch {
+	case opts.Transform != nil:
+		return "", errors.New("-output ssh:// cannot be combined with -transform")
+	case opts.RegisterAs != "":
+		return "", errors.New("-output ssh:// cannot be combined with -register-ollama")
+	case opts.IfExists != "" && opts.IfExists != "overwrite":
+		return "", errors.New("-output ssh:// always replaces the remote file; -if-exists is not supported")
+	}
+	target, err := parseRemoteTarget(opts.Output)
+	if err != nil {
+		return "", err
+	}
+
+	manifest, err := fetchPinnedManifest(ctx, modelName, modelParameters, opts.Manifest)
+	if err != nil {
+		return "", err
+	}
+	layer := manifest.ModelLayer()
+	if layer == nil {
+		return "", errors.New("model digest not found in manifest")
+	}
+	if opts.Digest != "" && layer.Digest != opts.Digest {
+		return "", fmt.Errorf("%s:%s now serves %s, not the pinned %s", modelName, modelParameters, layer.Digest, opts.Digest)
+	}
+	config, _ := fetchModelConfig(ctx, modelName, manifest)
+	dest := target.file(opts.fileName(NamedModel{Model: modelName, Tag: modelParameters, Digest: layer.Digest, Config: config}))
+	remote := "ssh://" + target.Dest
+	if target.Port != "" {
+		remote += ":" + target.Port
+	}
+	if strings.HasPrefix(dest, "/") {
+		remote += dest
+	} else {
+		remote += "/~/" + dest
+	}
+
+	resp, err := httpGet(ctx, blobURL(modelName, layer.Digest))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", &StatusError{Op: "download file", StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	registryClient().NoteStrippedHeaders(resp)
+	body := bufio.NewReaderSize(throttle(resp.Body), ollamareg.SniffLength)
+	prefix, _ := body.Peek(ollamareg.SniffLength)
+	if err := ollamareg.CheckGGUF(resp, prefix); err != nil {
+		return "", err
+	}
+
+	fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Streaming %s:%s to %s...", modelName, modelParameters, remote))
+	cmd := target.receiveCommand(ctx, dest, layer.Digest)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("could not start ssh: %w", err)
+	}
+
+	bar := newProgress(resp.ContentLength, "Uploading")
+	if _, err := io.Copy(io.MultiWriter(stdin, bar), body); err != nil {
+		// Closing stdin would let the remote side check a truncated file, so cut it off instead
+		cmd.Process.Kill()
+		cmd.Wait()
+		return "", fmt.Errorf("streaming to %s failed: %w", remote, err)
+	}
+	stdin.Close()
+	if err := cmd.Wait(); err != nil {
+		return "", fmt.Errorf("remote copy to %s failed: %w", remote, err)
+	}
+	if err := bar.Finish(); err != nil {
+		return "", err
+	}
+	fmt.Fprintln(os.Stderr, color.CyanString("[INFO] %s verified %s", target.Dest, layer.Digest))
+	return remote, nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+
+	"ggufDownloader/pkg/ollamareg"
+)
+
+// repairChunkSize is how much of the file is compared with the registry at a time
+const repairChunkSize = 16 << 20
+
+// byteRange is a half-open range [Start, End) of a file
+type byteRange struct {
+	Start, End int64
+}
+
+// repairSource finds where a damaged file can be fetched again and the digest it must
+// end up with: from MODEL:TAG when given, otherwise from its sidecar or the ledger
+func repairSource(ctx context.Context, path, ref, digest string) (url, want string, err error) {
+	if ref != "" {
+		modelName, tag, err := parseModelRef(ref)
+		if err != nil {
+			return "", "", err
+		}
+		if digest == "" {
+			manifest, err := fetchManifest(ctx, modelName, tag)
+			if err != nil {
+				return "", "", err
+			}
+			layer := manifest.ModelLayer()
+			if layer == nil {
+				return "", "", errors.New("model digest not found in manifest")
+			}
+			digest = layer.Digest
+		}
+		return blobURL(modelName, digest), digest, nil
+	}
+
+	var sidecar Sidecar
+	if data, err := os.ReadFile(sidecarPath(path)); err == nil && json.Unmarshal(data, &sidecar) == nil &&
+		sidecar.Digest != "" && strings.HasPrefix(sidecar.Source, "http") && (digest == "" || digest == sidecar.Digest) {
+		return sidecar.Source, sidecar.Digest, nil
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", "", err
+	}
+	entries, err := loadLedger()
+	if err != nil {
+		return "", "", err
+	}
+	for _, e := range entries {
+		if e.Path == abs && e.Digest != "" && e.Params != "safetensors" && e.Params != "gguf" {
+			if digest == "" {
+				digest = e.Digest
+			}
+			// A Hugging Face file has no blob in the registry to fetch again
+			if e.Source == sourceHF {
+				return e.URL, digest, nil
+			}
+			return blobURL(e.Model, digest), digest, nil
+		}
+	}
+	return "", "", fmt.Errorf("do not know where %s came from; pass MODEL:TAG", path)
+}
+
+// repairFile compares path with the blob at url chunk by chunk, rewriting only the chunks
+// that differ and fetching whatever is missing, and returns the ranges it patched
+func repairFile(ctx context.Context, path, url string) ([]byteRange, int64, error) {
+	resp, err := httpRequest(ctx, http.MethodHead, url)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, &StatusError{Op: "repair", StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	size := resp.ContentLength
+	if size <= 0 {
+		return nil, 0, errors.New("the registry did not report the size of the file")
+	}
+	// Every range must come from the version of the file this response describes
+	version := resp
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	bar := newProgress(size, "Comparing")
+	local := make([]byte, repairChunkSize)
+	remote := make([]byte, repairChunkSize)
+	var patched []byteRange
+	for start := int64(0); start < size; start += repairChunkSize {
+		end := min(start+repairChunkSize, size)
+		n := int(end - start)
+
+		resp, err := registryClient().GetRange(ctx, url, start, end-1, version)
+		if err != nil {
+			return patched, size, err
+		}
+		if err := ollamareg.CheckContentRange(resp, start, end-1, size); err != nil {
+			resp.Body.Close()
+			return patched, size, err
+		}
+		_, err = io.ReadFull(io.TeeReader(throttle(resp.Body), bar), remote[:n])
+		resp.Body.Close()
+		if err != nil {
+			return patched, size, fmt.Errorf("bytes %d-%d: %w", start, end-1, err)
+		}
+
+		got, err := f.ReadAt(local[:n], start)
+		if err != nil && err != io.EOF {
+			return patched, size, err
+		}
+		if got < n || !bytes.Equal(local[:n], remote[:n]) {
+			if _, err := f.WriteAt(remote[:n], start); err != nil {
+				return patched, size, err
+			}
+			if k := len(patched); k > 0 && patched[k-1].End == start {
+				patched[k-1].End = end
+			} else {
+				patched = append(patched, byteRange{start, end})
+			}
+		}
+	}
+	if err := bar.Finish(); err != nil {
+		return patched, size, err
+	}
+	// Anything past the blob's end is garbage from an earlier, different download
+	if err := f.Truncate(size); err != nil {
+		return patched, size, err
+	}
+	return patched, size, f.Close()
+}
+
+var repairCommand = &Command{
+	Name:    "repair",
+	Usage:   "FILE [MODEL:TAG]",
+	Summary: "Fix a corrupted or partial file by re-downloading only the damaged ranges",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		digest := fs.String("digest", "", "Expected digest (algorithm:hex, e.g. sha256:<hex>); defaults to the sidecar, the ledger or MODEL:TAG")
+		return func(args []string) error {
+			if len(args) < 1 || len(args) > 2 {
+				return errors.New("usage: repair [-digest D] FILE [MODEL:TAG]")
+			}
+			path, ref := resolveFile(args[0]), ""
+			if len(args) == 2 {
+				ref = args[1]
+			}
+			ctx := context.Background()
+			url, want, err := repairSource(ctx, path, ref, *digest)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Comparing %s with the registry copy...", path))
+			patched, size, err := repairFile(ctx, path, url)
+			if err != nil {
+				return err
+			}
+			var bad int64
+			for _, r := range patched {
+				bad += r.End - r.Start
+				fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Replaced bytes %d-%d (%s)", r.Start, r.End-1, formatBytes(r.End-r.Start)))
+			}
+
+			fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Verifying %s...", path))
+			if err := verifyFile(path, want); err != nil {
+				return fmt.Errorf("%w; the registry may serve a different build than expected", err)
+			}
+			if len(patched) == 0 {
+				fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] %s was intact and matches %s", path, want))
+			} else {
+				fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] Repaired %s: rewrote %s of %s in %d range(s); it now matches %s",
+					path, formatBytes(bad), formatBytes(size), len(patched), want))
+			}
+			return nil
+		}
+	},
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// Resolution describes exactly what a pull of model:tag would download
+type Resolution struct {
+	Model          string       `json:"model"`
+	Tag            string       `json:"tag"`
+	ManifestDigest string       `json:"manifest_digest"`
+	BlobDigest     string       `json:"blob_digest"`
+	Size           int64        `json:"size"`
+	URL            string       `json:"url"`
+	FinalURL       string       `json:"final_url"`
+	Config         *ModelConfig `json:"config,omitempty"`
+}
+
+// resolveModel fetches the manifest and follows the blob URL's redirects without downloading the blob
+func resolveModel(ctx context.Context, modelName, tag string) (*Resolution, error) {
+	manifest, err := fetchManifest(ctx, modelName, tag)
+	if err != nil {
+		return nil, err
+	}
+	layer := manifest.ModelLayer()
+	if layer == nil {
+		return nil, errors.New("model digest not found in manifest")
+	}
+
+	url := blobURL(modelName, layer.Digest)
+	resp, err := httpRequest(ctx, http.MethodHead, url)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("failed to resolve blob: " + resp.Status)
+	}
+
+	// The config is informational, so a registry without one still resolves
+	config, _ := fetchModelConfig(ctx, modelName, manifest)
+
+	return &Resolution{
+		Model:          modelName,
+		Tag:            tag,
+		ManifestDigest: manifest.Digest,
+		BlobDigest:     layer.Digest,
+		Size:           layer.Size,
+		URL:            url,
+		FinalURL:       resp.Request.URL.String(),
+		Config:         config,
+	}, nil
+}
+
+var resolveCommand = &Command{
+	Name:    "resolve",
+	Usage:   "MODEL:TAG",
+	Summary: "Print the manifest digest, blob digest, size and URL as JSON without downloading",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		return func(args []string) error {
+			if len(args) != 1 {
+				return errors.New("usage: resolve MODEL:TAG")
+			}
+			modelName, tag, err := parseModelRef(args[0])
+			if err != nil {
+				return err
+			}
+
+			res, err := resolveModel(context.Background(), modelName, tag)
+			if err != nil {
+				return fmt.Errorf("failed to resolve %s: %w", args[0], err)
+			}
+
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(res)
+		}
+	},
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// defaultContextSize caps the suggested context so the KV cache fits on ordinary machines
+const defaultContextSize = 8192
+
+// chatTemplateMarkers identify llama.cpp's built-in chat templates from the model's own template
+var chatTemplateMarkers = []struct{ marker, llamacpp, kobold string }{
+	{"<|start_header_id|>", "llama3", "Llama-3"},
+	{"<|im_start|>", "chatml", "ChatML"},
+	{"<start_of_turn>", "gemma", "Gemma-2"},
+	{"<|end|>", "phi3", "Phi-3-Mini"},
+	{"[INST]", "llama2", "Llama-2-Chat"},
+}
+
+// runHint is what a runner needs to know to serve a model sensibly
+type runHint struct {
+	Path        string
+	Context     int64
+	HasTemplate bool
+	// Template names the built-in llama.cpp template matching the model's, if any
+	Template string
+	// KoboldAdapter names the koboldcpp chat adapter preset matching the model's template
+	KoboldAdapter string
+	// Projector is the vision projector saved next to the model, if any
+	Projector string
+}
+
+// deriveRunHint reads the context length and chat template of a GGUF file
+func deriveRunHint(path string) (*runHint, error) {
+	meta, err := openGGUFMetadata(path, func(key string) bool {
+		return key == "general.architecture" || key == "tokenizer.chat_template" || strings.HasSuffix(key, ".context_length")
+	})
+	if err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	hint := &runHint{Path: abs, Context: defaultContextSize, KoboldAdapter: "AutoGuess"}
+	if _, err := os.Stat(projectorPath(abs, 0)); err == nil {
+		hint.Projector = projectorPath(abs, 0)
+	}
+	if trained, ok := meta.Int(meta.String("general.architecture") + ".context_length"); ok && trained > 0 && trained < hint.Context {
+		hint.Context = trained
+	}
+	if tmpl := meta.String("tokenizer.chat_template"); tmpl != "" {
+		hint.HasTemplate = true
+		for _, m := range chatTemplateMarkers {
+			if strings.Contains(tmpl, m.marker) {
+				hint.Template, hint.KoboldAdapter = m.llamacpp, m.kobold
+				break
+			}
+		}
+	}
+	return hint, nil
+}
+
+// shellQuote quotes s for a POSIX shell when it contains anything but safe characters
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-.,:/+=@") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// commands returns ready-to-run command lines for llama.cpp, llamafile and koboldcpp
+func (h *runHint) commands() []struct{ runner, line string } {
+	model := shellQuote(h.Path)
+	ctx := fmt.Sprint(h.Context)
+
+	// llama.cpp applies the template embedded in the GGUF with --jinja
+	llamacpp := "llama-cli -m " + model + " -c " + ctx + " -ngl 99 -cnv"
+	if h.Projector != "" {
+		// Images go through llama.cpp's multimodal CLI, which chats by default
+		llamacpp = "llama-mtmd-cli -m " + model + " --mmproj " + shellQuote(h.Projector) + " -c " + ctx + " -ngl 99"
+	}
+	if h.HasTemplate {
+		llamacpp += " --jinja"
+	}
+	// llamafile predates --jinja, so name the matching built-in template instead
+	llamafile := "llamafile -m " + model + " -c " + ctx + " -ngl 9999"
+	if h.Template != "" {
+		llamafile += " --chat-template " + h.Template
+	}
+	kobold := "koboldcpp --model " + model + " --contextsize " + ctx + " --gpulayers 99 --chatcompletionsadapter " + h.KoboldAdapter
+	if h.Projector != "" {
+		llamafile += " --mmproj " + shellQuote(h.Projector)
+		kobold += " --mmproj " + shellQuote(h.Projector)
+	}
+
+	return []struct{ runner, line string }{
+		{"llama.cpp", llamacpp},
+		{"llamafile", llamafile},
+		{"koboldcpp", kobold},
+	}
+}
+
+// runScriptPath returns where the run script for a model file is written
+func runScriptPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".sh"
+}
+
+// writeRunScript writes a shell script running the model with llama.cpp, with the other runners commented out
+func (h *runHint) writeRunScript(path string) error {
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+	fmt.Fprintf(&b, "# Run %s (context %d)\n", filepath.Base(h.Path), h.Context)
+	for i, c := range h.commands() {
+		fmt.Fprintf(&b, "\n# %s\n", c.runner)
+		if i > 0 {
+			b.WriteString("# ")
+		} else {
+			b.WriteString("exec ")
+		}
+		b.WriteString(c.line + " \"$@\"\n")
+	}
+	return os.WriteFile(path, []byte(b.String()), 0o755)
+}
+
+// suggestRunCommands prints how to run a downloaded model and optionally writes them to a script;
+// files that are not GGUF, such as transformed downloads, are skipped quietly
+func suggestRunCommands(path string, script bool) {
+	hint, err := deriveRunHint(path)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Run it with:"))
+	for _, c := range hint.commands() {
+		fmt.Fprintf(os.Stderr, "  %s %s\n", color.GreenString("%-10s", c.runner), c.line)
+	}
+	if !script {
+		return
+	}
+	scriptPath := runScriptPath(path)
+	if err := hint.writeRunScript(scriptPath); err != nil {
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Could not write run script: %s", err))
+		return
+	}
+	fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] Run script written to %s", scriptPath))
+}
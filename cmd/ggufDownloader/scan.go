@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// scanHookFor returns the scan command for a pull: the -scan-hook flag, or else the
+// "scan_hook" setting of the config file
+func scanHookFor(opts PullOptions) (string, error) {
+	if opts.ScanHook != "" {
+		return opts.ScanHook, nil
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return "", err
+	}
+	return cfg.ScanHook, nil
+}
+
+// scanFile runs the scan hook on a downloaded file before anything else uses it, which
+// for a fresh download is while it is still FILE.part. The hook's {path} (or {{.Path}})
+// is replaced with the file's quoted path. A scanner that rejects the file, or cannot be
+// run at all, fails the download: the file is moved aside to FILE.rejected and its blob
+// is dropped from the cache, so no later pull can pick up the same bytes.
+func scanFile(path, digest, hook string) error {
+	if hook == "" {
+		return nil
+	}
+	fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Scanning %s...", path))
+	quoted := shellQuote(path)
+	cmd := shellCommand(strings.NewReplacer("{path}", quoted, "{{.Path}}", quoted).Replace(hook))
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	if err == nil {
+		fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] Scan of %s passed", path))
+		return nil
+	}
+
+	rejected := strings.TrimSuffix(path, ".part") + ".rejected"
+	where := "moved to " + rejected
+	if os.Rename(path, rejected) != nil {
+		os.Remove(path)
+		where = "deleted"
+	}
+	if cachePath, cacheErr := blobCachePath(digest); cacheErr == nil && digest != "" {
+		os.Remove(cachePath)
+	}
+	return fmt.Errorf("scan hook %q rejected %s (%v); the file was %s", hook, path, err, where)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// plannedJob is a job on the daemon's schedule, with when it is expected to run
+type plannedJob struct {
+	Job
+	Start time.Time
+	// End is zero when the size or the rate is unknown
+	End  time.Time
+	Size int64
+	Note string
+}
+
+// upcoming returns the running job, when it started, and the queued jobs in the order
+// the worker takes them
+func (m *jobManager) upcoming() (*Job, time.Time, []Job) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var running *Job
+	var queued []Job
+	for _, job := range m.jobs {
+		switch {
+		case job.ID == m.running && job.State == JobRunning:
+			copied := *job
+			running = &copied
+		case job.State == JobQueued:
+			queued = append(queued, *job)
+		}
+	}
+	return running, m.runningSince, queued
+}
+
+// scheduleRate returns the rate downloads are expected to run at: what the daemon has
+// measured, or the -limit-rate cap until it has measured anything; 0 when neither is known
+func scheduleRate() float64 {
+	if rate := events.measuredRate(); rate > 0 {
+		return rate
+	}
+	if downloadLimiter != nil {
+		return downloadLimiter.total
+	}
+	return 0
+}
+
+// jobSize returns the bytes a job downloads, from its manifest; 0 when it cannot be resolved
+func jobSize(ctx context.Context, job Job) int64 {
+	manifest, err := fetchManifest(ctx, job.Model, job.Params)
+	if err != nil {
+		return 0
+	}
+	var size int64
+	for _, layer := range manifest.Layers {
+		size += layer.Size
+	}
+	return size
+}
+
+// plan lays the running and queued jobs out one after another from now, at rate. A job
+// whose duration is unknown takes no time, so the jobs after it are planned for the
+// earliest they can start.
+func (m *jobManager) plan(ctx context.Context, rate float64) []plannedJob {
+	running, since, queued := m.upcoming()
+	now := time.Now()
+	var planned []plannedJob
+	next := now
+
+	if running != nil {
+		p := plannedJob{Job: *running, Start: since, Size: jobSize(ctx, *running)}
+		remaining := p.Size
+		if progress, ok := events.runningProgress(running.ID); ok && progress.Total > 0 {
+			remaining = progress.Total - progress.Current
+			p.Note = fmt.Sprintf("%s of %s left (%s)", formatBytes(remaining), formatBytes(progress.Total), progress.Description)
+		}
+		if rate > 0 && remaining > 0 {
+			p.End = now.Add(time.Duration(float64(remaining) / rate * float64(time.Second)))
+			next = p.End
+		}
+		planned = append(planned, p)
+	}
+	for _, job := range queued {
+		p := plannedJob{Job: job, Start: next, Size: jobSize(ctx, job)}
+		if rate > 0 && p.Size > 0 {
+			p.End = next.Add(time.Duration(float64(p.Size) / rate * float64(time.Second)))
+			next = p.End
+		}
+		planned = append(planned, p)
+	}
+	return planned
+}
+
+// icalTime formats t as an iCalendar UTC date-time
+func icalTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// icalText escapes a value for an iCalendar text property
+var icalText = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+// writeICalLine writes a content line, folded at 75 octets as RFC 5545 requires
+func writeICalLine(b *strings.Builder, line string) {
+	// Continuation lines start with a space, which counts towards their length
+	for limit := 75; len(line) > limit; limit = 74 {
+		cut := limit
+		// Never split a UTF-8 sequence
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+	}
+	b.WriteString(line + "\r\n")
+}
+
+// scheduleCalendar renders planned jobs as an iCalendar feed, one event per job
+func scheduleCalendar(planned []plannedJob, rate float64, now time.Time) string {
+	var b strings.Builder
+	writeICalLine(&b, "BEGIN:VCALENDAR")
+	writeICalLine(&b, "VERSION:2.0")
+	writeICalLine(&b, "PRODID:-//ggufDownloader//Download schedule//EN")
+	writeICalLine(&b, "X-WR-CALNAME:ggufDownloader downloads")
+	for _, p := range planned {
+		summary := fmt.Sprintf("Download %s:%s", p.Model, p.Params)
+		if p.Size > 0 {
+			summary += " (" + formatBytes(p.Size) + ")"
+		}
+		var details []string
+		if p.State == JobRunning {
+			details = append(details, "Running since "+p.Start.Format(time.RFC3339))
+		} else {
+			details = append(details, "Queued; starts when the jobs before it finish")
+		}
+		if p.Note != "" {
+			details = append(details, p.Note)
+		}
+		switch {
+		case p.End.IsZero() && rate == 0:
+			details = append(details, "Completion unknown until the daemon has measured its transfer rate")
+		case p.End.IsZero():
+			details = append(details, "Completion unknown: the size could not be resolved")
+		default:
+			details = append(details, fmt.Sprintf("Expected to complete at %s/s", formatBytes(int64(rate))))
+		}
+
+		writeICalLine(&b, "BEGIN:VEVENT")
+		writeICalLine(&b, "UID:"+p.ID+"@ggufDownloader")
+		writeICalLine(&b, "DTSTAMP:"+icalTime(now))
+		writeICalLine(&b, "DTSTART:"+icalTime(p.Start))
+		if !p.End.IsZero() {
+			writeICalLine(&b, "DTEND:"+icalTime(p.End))
+		}
+		writeICalLine(&b, "SUMMARY:"+icalText.Replace(summary))
+		writeICalLine(&b, "DESCRIPTION:"+icalText.Replace(strings.Join(details, "\n")))
+		if p.State == JobRunning {
+			writeICalLine(&b, "STATUS:CONFIRMED")
+		} else {
+			writeICalLine(&b, "STATUS:TENTATIVE")
+		}
+		// Downloads occupy bandwidth, not people
+		writeICalLine(&b, "TRANSP:TRANSPARENT")
+		writeICalLine(&b, "END:VEVENT")
+	}
+	writeICalLine(&b, "END:VCALENDAR")
+	return b.String()
+}
+
+// handleSchedule serves /schedule.ics: the running and queued jobs as calendar events
+// from their expected start to their expected completion
+func (m *jobManager) handleSchedule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	rate := scheduleRate()
+	calendar := scheduleCalendar(m.plan(r.Context(), rate), rate, time.Now())
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Write([]byte(calendar))
+}
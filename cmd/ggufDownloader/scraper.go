@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for fetching pages from ollama.com
+const (
+	defaultCrawlConcurrency = 2
+	defaultCrawlDelay       = 500 * time.Millisecond
+	maxRetryAfter           = time.Minute
+)
+
+// CrawlOptions control how politely pages are scraped from ollama.com
+type CrawlOptions struct {
+	Concurrency int
+	Delay       time.Duration
+}
+
+// scraper fetches every ollama.com page; it is configured by setupHTTPClient
+var scraper = newPoliteFetcher(CrawlOptions{})
+
+// politeFetcher limits requests per host to a number in flight and a minimum spacing,
+// backs off when the server asks it to, and skips paths robots.txt disallows
+type politeFetcher struct {
+	opts  CrawlOptions
+	mu    sync.Mutex
+	hosts map[string]*hostLimiter
+}
+
+// hostLimiter is the politeness state for one host
+type hostLimiter struct {
+	slots  chan struct{}
+	mu     sync.Mutex
+	next   time.Time
+	delay  time.Duration
+	robots sync.Once
+	rules  *robotsRules
+}
+
+// newPoliteFetcher returns a fetcher, filling unset options with the defaults
+func newPoliteFetcher(opts CrawlOptions) *politeFetcher {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultCrawlConcurrency
+	}
+	if opts.Delay <= 0 {
+		opts.Delay = defaultCrawlDelay
+	}
+	return &politeFetcher{opts: opts, hosts: make(map[string]*hostLimiter)}
+}
+
+// host returns the limiter for a host, creating it on first use
+func (f *politeFetcher) host(name string) *hostLimiter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	h, ok := f.hosts[name]
+	if !ok {
+		h = &hostLimiter{slots: make(chan struct{}, f.opts.Concurrency), delay: f.opts.Delay}
+		f.hosts[name] = h
+	}
+	return h
+}
+
+// wait blocks until the host may be sent another request
+func (h *hostLimiter) wait(ctx context.Context) error {
+	h.mu.Lock()
+	now := time.Now()
+	start := h.next
+	if start.Before(now) {
+		start = now
+	}
+	h.next = start.Add(h.delay)
+	h.mu.Unlock()
+
+	select {
+	case <-time.After(time.Until(start)):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// backOff delays every later request to the host by d
+func (h *hostLimiter) backOff(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if next := time.Now().Add(d); next.After(h.next) {
+		h.next = next
+	}
+}
+
+// get fetches rawURL politely; the returned body must be closed to free the slot
+func (f *politeFetcher) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	h := f.host(u.Host)
+
+	h.robots.Do(func() {
+		h.rules = fetchRobots(ctx, u)
+		if h.rules != nil && h.rules.crawlDelay > h.delay {
+			h.delay = h.rules.crawlDelay
+		}
+	})
+	if h.rules != nil && !h.rules.allowed(u.Path) {
+		return nil, fmt.Errorf("%s disallows fetching %s (robots.txt)", u.Host, u.Path)
+	}
+
+	select {
+	case h.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := h.wait(ctx); err != nil {
+			<-h.slots
+			return nil, err
+		}
+		resp, err := httpGet(ctx, rawURL)
+		if err != nil {
+			<-h.slots
+			return nil, err
+		}
+
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+		if !retry || attempt == 2 {
+			resp.Body = &releaseOnClose{ReadCloser: resp.Body, slots: h.slots}
+			return resp, nil
+		}
+		resp.Body.Close()
+		h.backOff(retryAfter(resp.Header.Get("Retry-After"), h.delay<<(attempt+1)))
+	}
+}
+
+// retryAfter parses a Retry-After header, falling back to def and capping the wait
+func retryAfter(header string, def time.Duration) time.Duration {
+	d := def
+	if secs, err := strconv.Atoi(header); err == nil {
+		d = time.Duration(secs) * time.Second
+	} else if t, err := http.ParseTime(header); err == nil {
+		d = time.Until(t)
+	}
+	if d > maxRetryAfter {
+		d = maxRetryAfter
+	}
+	return d
+}
+
+// releaseOnClose frees a host slot when the response body is closed
+type releaseOnClose struct {
+	io.ReadCloser
+	slots chan struct{}
+	once  sync.Once
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(func() { <-r.slots })
+	return err
+}
+
+// robotsRules are the robots.txt rules that apply to this tool
+type robotsRules struct {
+	disallow   []string
+	allow      []string
+	crawlDelay time.Duration
+}
+
+// allowed reports whether path may be fetched; the longest matching rule wins
+func (r *robotsRules) allowed(path string) bool {
+	longest := func(prefixes []string) int {
+		n := -1
+		for _, p := range prefixes {
+			if strings.HasPrefix(path, p) && len(p) > n {
+				n = len(p)
+			}
+		}
+		return n
+	}
+	return longest(r.allow) >= longest(r.disallow)
+}
+
+// fetchRobots reads the robots.txt of u's host; a missing or unreadable file imposes no rules
+func fetchRobots(ctx context.Context, u *url.URL) *robotsRules {
+	resp, err := httpGet(ctx, u.Scheme+"://"+u.Host+"/robots.txt")
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	return parseRobots(io.LimitReader(resp.Body, 512<<10))
+}
+
+// parseRobots keeps the rules of the groups addressed to every agent or to this tool
+func parseRobots(r io.Reader) *robotsRules {
+	rules := &robotsRules{}
+	applies, inAgents := false, false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		if key == "user-agent" {
+			// Consecutive user-agent lines share one group
+			if !inAgents {
+				applies = false
+			}
+			inAgents = true
+			agent := strings.ToLower(value)
+			if agent == "*" || (agent != "" && strings.Contains(strings.ToLower(UserAgent), agent)) {
+				applies = true
+			}
+			continue
+		}
+		inAgents = false
+		if !applies {
+			continue
+		}
+
+		switch key {
+		case "disallow":
+			if value != "" {
+				rules.disallow = append(rules.disallow, value)
+			}
+		case "allow":
+			rules.allow = append(rules.allow, value)
+		case "crawl-delay":
+			if secs, err := strconv.ParseFloat(value, 64); err == nil {
+				rules.crawlDelay = time.Duration(secs * float64(time.Second))
+			}
+		}
+	}
+	return rules
+}
@@ -0,0 +1,449 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// JobState is the lifecycle state of a download job
+type JobState string
+
+const (
+	JobQueued  JobState = "queued"
+	JobRunning JobState = "running"
+	JobPaused  JobState = "paused"
+	JobFailed  JobState = "failed"
+	JobDone    JobState = "done"
+)
+
+// jobTransitions lists the states each state may move to
+var jobTransitions = map[JobState][]JobState{
+	JobQueued:  {JobRunning, JobPaused, JobFailed},
+	JobRunning: {JobDone, JobFailed, JobPaused},
+	JobPaused:  {JobQueued, JobFailed},
+	JobFailed:  {JobQueued},
+	JobDone:    {},
+}
+
+// Job is a single model download tracked by the server
+type Job struct {
+	ID        string    `json:"id"`
+	Model     string    `json:"model"`
+	Params    string    `json:"params"`
+	State     JobState  `json:"state"`
+	Output    string    `json:"output,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// jobManager owns the job list, persists it and runs queued jobs one at a time
+type jobManager struct {
+	mu     sync.Mutex
+	path   string
+	jobs   []*Job
+	cancel map[string]context.CancelFunc
+	wake   chan struct{}
+	opts   PullOptions
+	// running is the job the worker is on and when it started, for health checks
+	running      string
+	runningSince time.Time
+	saveErr      error
+}
+
+// newJobManager loads persisted jobs, requeueing any that were running when the process stopped
+func newJobManager(path string, opts PullOptions) (*jobManager, error) {
+	m := &jobManager{
+		path:   path,
+		cancel: make(map[string]context.CancelFunc),
+		wake:   make(chan struct{}, 1),
+		opts:   opts,
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &m.jobs); err != nil {
+			return nil, fmt.Errorf("invalid job file %s: %w", path, err)
+		}
+	}
+
+	for _, job := range m.jobs {
+		if job.State == JobRunning {
+			job.State = JobQueued
+			job.UpdatedAt = time.Now()
+		}
+	}
+	return m, m.save()
+}
+
+// save writes the job list to disk; callers must hold m.mu or own m exclusively
+func (m *jobManager) save() error {
+	jobs := m.jobs
+	if jobs == nil {
+		jobs = []*Job{}
+	}
+	m.saveErr = writeJSONFile(m.path, jobs)
+	return m.saveErr
+}
+
+// newJobID returns a random identifier for a job
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// find returns the job with the given ID; callers must hold m.mu
+func (m *jobManager) find(id string) *Job {
+	for _, job := range m.jobs {
+		if job.ID == id {
+			return job
+		}
+	}
+	return nil
+}
+
+// transition moves a job to a new state if the state machine allows it; callers must hold m.mu
+func (m *jobManager) transition(job *Job, to JobState, errMsg string) error {
+	allowed := false
+	for _, s := range jobTransitions[job.State] {
+		if s == to {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("cannot move job %s from %s to %s", job.ID, job.State, to)
+	}
+
+	job.State = to
+	job.Error = errMsg
+	job.UpdatedAt = time.Now()
+	if err := m.save(); err != nil {
+		fmt.Fprintln(os.Stderr, color.RedString("[ERROR] failed to persist jobs: %s", err))
+	}
+	if events != nil {
+		events.publish(job.ID, jobEvent{"state", *job})
+	}
+	return nil
+}
+
+// notify wakes the worker without blocking
+func (m *jobManager) notify() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// add queues a new download job
+func (m *jobManager) add(model, params string) (*Job, error) {
+	now := time.Now()
+	job := &Job{ID: newJobID(), Model: model, Params: params, State: JobQueued, CreatedAt: now, UpdatedAt: now}
+
+	m.mu.Lock()
+	m.jobs = append(m.jobs, job)
+	err := m.save()
+	copied := *job
+	m.mu.Unlock()
+
+	m.notify()
+	return &copied, err
+}
+
+// list returns a snapshot of every job
+func (m *jobManager) list() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs := make([]Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, *job)
+	}
+	return jobs
+}
+
+// get returns a snapshot of a single job
+func (m *jobManager) get(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job := m.find(id)
+	if job == nil {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// setState applies a user-requested transition, interrupting the transfer if the job is running
+func (m *jobManager) setState(id string, to JobState, errMsg string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job := m.find(id)
+	if job == nil {
+		return Job{}, errJobNotFound
+	}
+	if err := m.transition(job, to, errMsg); err != nil {
+		return *job, err
+	}
+	if cancel, ok := m.cancel[id]; ok {
+		cancel()
+	}
+	if to == JobQueued {
+		m.notify()
+	}
+	return *job, nil
+}
+
+var errJobNotFound = errors.New("job not found")
+
+// next marks the oldest queued job as running and returns it with its context
+func (m *jobManager) next(parent context.Context) (*Job, context.Context) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, job := range m.jobs {
+		if job.State != JobQueued {
+			continue
+		}
+		if events != nil {
+			events.start(job.ID)
+		}
+		m.transition(job, JobRunning, "")
+		ctx, cancel := context.WithCancel(parent)
+		m.cancel[job.ID] = cancel
+		m.running, m.runningSince = job.ID, time.Now()
+		copied := *job
+		return &copied, ctx
+	}
+	return nil, nil
+}
+
+// finish records the outcome of a job unless the user already moved it elsewhere
+func (m *jobManager) finish(id, output string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if cancel, ok := m.cancel[id]; ok {
+		cancel()
+		delete(m.cancel, id)
+	}
+	if m.running == id {
+		m.running = ""
+	}
+	if events != nil {
+		defer events.end(id)
+	}
+
+	job := m.find(id)
+	if job == nil || job.State != JobRunning {
+		return
+	}
+	if err != nil {
+		m.transition(job, JobFailed, err.Error())
+		return
+	}
+	job.Output = output
+	m.transition(job, JobDone, "")
+}
+
+// run processes queued jobs until ctx is canceled
+func (m *jobManager) run(ctx context.Context) {
+	for {
+		job, jobCtx := m.next(ctx)
+		if job == nil {
+			select {
+			case <-m.wake:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		output, err := pullModel(jobCtx, job.Model, job.Params, m.opts)
+		m.finish(job.ID, output, err)
+	}
+}
+
+// writeJSON encodes v as the response body
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError reports an API error as JSON
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// handleJobs serves the collection endpoint: GET lists jobs, POST creates one
+func (m *jobManager) handleJobs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, m.list())
+	case http.MethodPost:
+		var req struct {
+			Model  string `json:"model"`
+			Params string `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, errors.New("invalid JSON body"))
+			return
+		}
+		if req.Model == "" || req.Params == "" {
+			writeError(w, http.StatusBadRequest, errors.New("model and params are required"))
+			return
+		}
+		// Jobs are checked the way the CLI checks its arguments before they are saved
+		model, err := normalizeModelName(req.Model)
+		if err == nil {
+			err = validateTag(req.Params)
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		job, err := m.add(model, req.Params)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, job)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleJob serves /jobs/{id}, its event stream and its pause, resume and cancel actions
+func (m *jobManager) handleJob(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/")
+	id := parts[0]
+	action := ""
+	if len(parts) > 1 {
+		action = parts[1]
+	}
+	if r.Method == http.MethodGet && action == "events" {
+		m.handleEvents(w, r, id)
+		return
+	}
+
+	var job Job
+	var err error
+	switch {
+	case r.Method == http.MethodGet && action == "":
+		var ok bool
+		if job, ok = m.get(id); !ok {
+			err = errJobNotFound
+		}
+	case r.Method == http.MethodDelete && action == "",
+		r.Method == http.MethodPost && action == "cancel":
+		job, err = m.setState(id, JobFailed, "canceled")
+	case r.Method == http.MethodPost && action == "pause":
+		job, err = m.setState(id, JobPaused, "")
+	case r.Method == http.MethodPost && action == "resume":
+		job, err = m.setState(id, JobQueued, "")
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case errors.Is(err, errJobNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case err != nil:
+		writeError(w, http.StatusConflict, err)
+	default:
+		writeJSON(w, http.StatusOK, job)
+	}
+}
+
+// defaultServeAddr is where the daemon listens when no address is given
+const defaultServeAddr = "127.0.0.1:8080"
+
+var serveCommand = &Command{
+	Name:    "serve",
+	Usage:   "[ADDR]",
+	Summary: "Run as a daemon exposing the download job API (default " + defaultServeAddr + ")",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		pull := addPullFlags(fs)
+		daemon := defaultDaemonOptions()
+		fs.DurationVar(&daemon.FeedInterval, "feed-interval", daemon.FeedInterval, "How often to snapshot the catalog for /feed (0 disables)")
+		fs.StringVar(&daemon.HealthFile, "health-file", "", "Keep this file fresh while healthy and remove it when not, for non-HTTP supervisors")
+		fs.DurationVar(&daemon.StallTimeout, "stall-timeout", daemon.StallTimeout, "Report unhealthy when a running job receives nothing for this long (0 disables)")
+		return func(args []string) error {
+			addr := defaultServeAddr
+			if len(args) > 0 {
+				addr = args[0]
+			}
+			return serve(addr, pull.options(), daemon)
+		}
+	},
+}
+
+// daemonOptions configure the daemon's background work and health reporting
+type daemonOptions struct {
+	// FeedInterval is how often the catalog is snapshotted for /feed; 0 disables it
+	FeedInterval time.Duration
+	// HealthFile is kept fresh while the daemon is healthy, for supervisors without HTTP
+	HealthFile string
+	// StallTimeout is how long a running job may receive nothing before /healthz fails
+	StallTimeout time.Duration
+}
+
+// defaultDaemonOptions returns the settings used when no flags are given
+func defaultDaemonOptions() *daemonOptions {
+	return &daemonOptions{FeedInterval: defaultFeedInterval, StallTimeout: defaultStallTimeout}
+}
+
+// serve runs the download daemon on addr until the process exits
+func serve(addr string, opts PullOptions, daemon *daemonOptions) error {
+	dir, err := dataDir()
+	if err != nil {
+		return err
+	}
+	events = newEventHub()
+	if err := events.captureLogs(); err != nil {
+		return err
+	}
+	m, err := newJobManager(filepath.Join(dir, "jobs.json"), opts)
+	if err != nil {
+		return err
+	}
+	go m.run(context.Background())
+	if daemon.FeedInterval > 0 {
+		go watchFeed(daemon.FeedInterval)
+	}
+	if daemon.HealthFile != "" {
+		go m.writeHealthFile(daemon.HealthFile, daemon.StallTimeout)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jobs", m.handleJobs)
+	mux.HandleFunc("/jobs/", m.handleJob)
+	mux.HandleFunc("/schedule.ics", m.handleSchedule)
+	mux.HandleFunc("/feed", handleFeed)
+	mux.HandleFunc("/feed.rss", handleFeed)
+	mux.HandleFunc("/healthz", healthHandler(func() (healthStatus, bool) { return m.health(daemon.StallTimeout) }))
+	mux.HandleFunc("/readyz", healthHandler(m.ready))
+
+	fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Serving download API on %s", addr))
+	return http.ListenAndServe(addr, mux)
+}
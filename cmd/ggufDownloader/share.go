@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/fatih/color"
+)
+
+// shareSpecVersion is bumped when the spec format changes incompatibly
+const shareSpecVersion = 1
+
+// ShareSpec pins a model to exact digests so a teammate downloads byte-identical files
+type ShareSpec struct {
+	Version        int    `json:"version"`
+	Model          string `json:"model"`
+	Tag            string `json:"tag"`
+	ManifestDigest string `json:"manifest_digest"`
+	BlobDigest     string `json:"blob_digest"`
+	Size           int64  `json:"size"`
+}
+
+// newShareSpec pins the current version of model:tag
+func newShareSpec(ctx context.Context, modelName, tag string) (*ShareSpec, error) {
+	manifest, err := fetchManifest(ctx, modelName, tag)
+	if err != nil {
+		return nil, err
+	}
+	layer := manifest.ModelLayer()
+	if layer == nil {
+		return nil, errors.New("model digest not found in manifest")
+	}
+	return &ShareSpec{
+		Version:        shareSpecVersion,
+		Model:          modelName,
+		Tag:            tag,
+		ManifestDigest: manifest.Digest,
+		BlobDigest:     layer.Digest,
+		Size:           layer.Size,
+	}, nil
+}
+
+// readShareSpec reads a spec from a file, or from stdin when path is "-"
+func readShareSpec(path string) (*ShareSpec, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var spec ShareSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("invalid spec: %w", err)
+	}
+	if spec.Version != shareSpecVersion {
+		return nil, fmt.Errorf("unsupported spec version %d (expected %d)", spec.Version, shareSpecVersion)
+	}
+	if spec.Model == "" || spec.Tag == "" || spec.BlobDigest == "" {
+		return nil, errors.New("invalid spec: model, tag and blob_digest are required")
+	}
+	return &spec, nil
+}
+
+// options pins opts to the spec's digests
+func (s *ShareSpec) options(opts PullOptions) (PullOptions, error) {
+	if opts.Transform != nil {
+		return opts, errors.New("-spec cannot be combined with -transform, which changes the file's bytes")
+	}
+	if opts.HFFallback {
+		return opts, errors.New("-spec cannot be combined with -hf-fallback, which downloads a different build")
+	}
+	opts.Manifest, opts.Digest = s.ManifestDigest, s.BlobDigest
+	return opts, nil
+}
+
+var shareCommand = &Command{
+	Name:    "share",
+	Usage:   "MODEL:TAG",
+	Summary: "Print a spec pinning a model to exact digests, for \"pull -spec\"",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		asCommand := fs.Bool("command", false, "Print a ready-to-run pull command instead of the JSON spec")
+		out := fs.String("out", "", "Write the spec to this file instead of stdout")
+		return func(args []string) error {
+			if len(args) != 1 {
+				return errors.New("usage: share [-command] [-out FILE] MODEL:TAG")
+			}
+			modelName, tag, err := parseModelRef(args[0])
+			if err != nil {
+				return err
+			}
+			spec, err := newShareSpec(context.Background(), modelName, tag)
+			if err != nil {
+				return fmt.Errorf("failed to resolve %s: %w", args[0], err)
+			}
+
+			if *asCommand {
+				data, err := json.Marshal(spec)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("echo %s | ggufDownloader pull -spec -\n", shellQuote(string(data)))
+				return nil
+			}
+
+			data, err := json.MarshalIndent(spec, "", "  ")
+			if err != nil {
+				return err
+			}
+			data = append(data, '\n')
+			if *out == "" {
+				_, err = os.Stdout.Write(data)
+				return err
+			}
+			if err := os.WriteFile(*out, data, 0o644); err != nil {
+				return err
+			}
+			fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] Spec for %s:%s written to %s; download it with: ggufDownloader pull -spec %s", modelName, tag, *out, *out))
+			return nil
+		}
+	},
+}
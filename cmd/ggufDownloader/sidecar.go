@@ -0,0 +1,30 @@
+package main
+
+import "time"
+
+// Sidecar describes a downloaded model; it is written next to the model as FILE.json
+type Sidecar struct {
+	Model        string       `json:"model"`
+	Tag          string       `json:"tag"`
+	Digest       string       `json:"digest,omitempty"`
+	Size         int64        `json:"size"`
+	Source       string       `json:"source"`
+	Config       *ModelConfig `json:"config,omitempty"`
+	DownloadedAt time.Time    `json:"downloaded_at"`
+	// Notes explain anything unusual about where the file came from
+	Notes []string `json:"notes,omitempty"`
+	// Provenance is every request, and reused record, that led to the file's bytes
+	Provenance []ProvenanceHop `json:"provenance,omitempty"`
+	// Projectors are the vision projectors downloaded next to a multimodal model
+	Projectors []ProjectorFile `json:"projectors,omitempty"`
+}
+
+// sidecarPath returns where the sidecar of a model file is stored
+func sidecarPath(modelPath string) string {
+	return modelPath + ".json"
+}
+
+// writeSidecar stores the sidecar of a model file
+func writeSidecar(modelPath string, s *Sidecar) error {
+	return writeJSONFile(sidecarPath(modelPath), s)
+}
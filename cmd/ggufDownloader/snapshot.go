@@ -0,0 +1,40 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+)
+
+// snapshotData is a compact catalog of popular models built into the binary, so listings
+// work offline on the first run or when the ollama.com page layout changes
+//
+//go:embed snapshot.json
+var snapshotData []byte
+
+// embeddedCatalog returns the built-in catalog snapshot
+func embeddedCatalog() (*catalogCache, error) {
+	var cache catalogCache
+	if err := json.Unmarshal(snapshotData, &cache); err != nil {
+		return nil, fmt.Errorf("invalid built-in catalog snapshot: %w", err)
+	}
+	return &cache, nil
+}
+
+// offlineCatalog returns the best listing available without the network after fetching
+// failed: the cached catalog if there is one, otherwise the built-in snapshot
+func offlineCatalog(fetchErr error) (*catalogCache, error) {
+	if cache, err := loadCatalog(); err == nil && cache != nil && len(cache.Models) > 0 {
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Could not fetch the model list (%s); showing the catalog cached %s", fetchErr, formatAge(cache.FetchedAt)))
+		return cache, nil
+	}
+	cache, err := embeddedCatalog()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the model list: %w; %w", fetchErr, err)
+	}
+	fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Could not fetch the model list (%s); showing the built-in snapshot of %s", fetchErr, cache.FetchedAt.Format("2006-01-02")))
+	return cache, nil
+}
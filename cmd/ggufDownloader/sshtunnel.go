@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// sshTunnelTimeout bounds how long the bastion may take to accept the connection
+const sshTunnelTimeout = 30 * time.Second
+
+// sshTunnel is the ssh process holding a dynamic port forward open for this run
+var sshTunnel *exec.Cmd
+
+// startSSHTunnel starts the system ssh client with a dynamic (SOCKS) port forward through
+// dest, "[user@]host[:port]" or an ssh:// URL, and returns the proxy URL to send
+// requests to. The ssh client's own config, keys and agent are used as they are.
+func startSSHTunnel(dest string) (string, error) {
+	host, port := dest, ""
+	if strings.HasPrefix(dest, "ssh://") {
+		u, err := url.Parse(dest)
+		if err != nil || u.Hostname() == "" {
+			return "", fmt.Errorf("invalid -ssh-tunnel %q (expected [user@]host[:port])", dest)
+		}
+		host, port = u.Hostname(), u.Port()
+		if u.User != nil {
+			host = u.User.Username() + "@" + host
+		}
+	} else if h, p, err := net.SplitHostPort(dest); err == nil {
+		host, port = h, p
+	}
+
+	// Reserve a free port; ssh binds it again right after
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	local := l.Addr().String()
+	l.Close()
+
+	args := []string{"-N", "-D", local,
+		"-o", "ExitOnForwardFailure=yes", "-o", "ServerAliveInterval=30", "-o", "BatchMode=yes"}
+	if port != "" {
+		args = append(args, "-p", port)
+	}
+	args = append(args, "--", host)
+	cmd := exec.Command("ssh", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("could not start ssh: %w", err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Opening SSH tunnel through %s...", host))
+	deadline := time.Now().Add(sshTunnelTimeout)
+	for {
+		select {
+		case err := <-exited:
+			msg := strings.TrimSpace(stderr.String())
+			if msg == "" && err != nil {
+				msg = err.Error()
+			}
+			return "", fmt.Errorf("SSH tunnel through %s failed: %s", host, msg)
+		case <-time.After(100 * time.Millisecond):
+		}
+		if conn, err := net.DialTimeout("tcp", local, time.Second); err == nil {
+			conn.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			cmd.Process.Kill()
+			return "", fmt.Errorf("SSH tunnel through %s was not ready after %s", host, sshTunnelTimeout)
+		}
+	}
+	sshTunnel = cmd
+	return "socks5://" + local, nil
+}
+
+// closeSSHTunnel stops the tunnel started for this run, if any
+func closeSSHTunnel() {
+	if sshTunnel == nil || sshTunnel.Process == nil {
+		return
+	}
+	if err := sshTunnel.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Could not stop the SSH tunnel: %s", err))
+	}
+	sshTunnel = nil
+}
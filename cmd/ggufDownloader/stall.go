@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/fatih/color"
+
+	"ggufDownloader/pkg/ollamareg"
+)
+
+// idleTimeout is the -idle-timeout setting; 0 waits on a silent connection forever
+var idleTimeout = ollamareg.DefaultIdleTimeout
+
+// preferIPv4 makes dual-stack dials use IPv4 after a stall, since a connection that
+// hangs mid-transfer is most often an IPv6 path that drops large packets
+var preferIPv4 atomic.Bool
+
+// noteStall reports a stalled download of filename before it is re-dialed, switching
+// new connections to IPv4 unless a family was forced
+func noteStall(filename string, err error, redial int) {
+	msg := fmt.Sprintf("[WARN] Download of %s stalled (%s); re-dialing", filepath.Base(filename), err)
+	if transportSettings.Family == "" && transportSettings.Proxy == "" && !preferIPv4.Swap(true) {
+		msg += " over IPv4"
+	}
+	fmt.Fprintln(os.Stderr, color.YellowString("%s (%d/%d)", msg, redial, ollamareg.MaxStallRedials))
+}
+
+// warnTransfer prints a problem a download recovers from
+func warnTransfer(format string, args ...any) {
+	fmt.Fprintln(os.Stderr, color.YellowString("[WARN] "+format, args...))
+}
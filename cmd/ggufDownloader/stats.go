@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// ledgerStats summarizes the download ledger
+type ledgerStats struct {
+	Models int
+	// OnDisk is the apparent size of the files; Allocated is the space they occupy,
+	// which is less for sparse partial downloads
+	OnDisk    int64
+	Allocated int64
+	// Partial counts unfinished downloads that can be resumed
+	Partial    int
+	ThisMonth  int64
+	CacheHits  int
+	Largest    []LedgerEntry
+	Missing    int
+	TotalPulls int
+}
+
+// partialDownloads returns the interrupted downloads, FILE.gguf.part, waiting in dirs
+func partialDownloads(dirs []string) []string {
+	var parts []string
+	for _, dir := range dirs {
+		if matches, err := filepath.Glob(filepath.Join(dir, "*.gguf.part")); err == nil {
+			parts = append(parts, matches...)
+		}
+	}
+	return parts
+}
+
+// downloadDirs returns the directories the downloads of entries went to, and the one
+// the active project downloads to, where interrupted downloads wait
+func downloadDirs(entries []LedgerEntry) []string {
+	dir := "."
+	if activeProject != "" {
+		if project, err := projectDir(); err == nil {
+			dir = filepath.Join(project, "models")
+		}
+	}
+	var dirs []string
+	if abs, err := filepath.Abs(dir); err == nil {
+		dirs = append(dirs, abs)
+	}
+	for _, e := range entries {
+		if dir := filepath.Dir(e.Path); !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// computeStats totals the entries of a ledger and the partial downloads in dirs,
+// keeping the top largest models
+func computeStats(entries []LedgerEntry, dirs []string, top int) ledgerStats {
+	var st ledgerStats
+	now := time.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	// Hard-linked copies of the same blob only take space once
+	counted := make(map[string]bool)
+
+	var present []LedgerEntry
+	for _, e := range entries {
+		st.TotalPulls++
+		if e.Cached {
+			st.CacheHits++
+		} else if !e.DownloadedAt.Before(monthStart) {
+			st.ThisMonth += e.Size
+		}
+
+		info, err := os.Stat(e.Path)
+		if err != nil {
+			st.Missing++
+			continue
+		}
+		st.Models++
+		present = append(present, e)
+		if e.Digest == "" || !counted[e.Digest] {
+			counted[e.Digest] = true
+			st.OnDisk += e.Size
+			st.Allocated += fileDiskUsage(e.Path, info)
+		}
+	}
+	// Unfinished downloads are never in the ledger, but take space all the same; one
+	// split over several connections is sparse until its last chunk arrives
+	for _, part := range partialDownloads(dirs) {
+		if info, err := os.Stat(part); err == nil && info.Mode().IsRegular() {
+			st.Partial++
+			st.OnDisk += info.Size()
+			st.Allocated += fileDiskUsage(part, info)
+		}
+	}
+
+	sort.Slice(present, func(i, j int) bool { return present[i].Size > present[j].Size })
+	if len(present) > top {
+		present = present[:top]
+	}
+	st.Largest = present
+	return st
+}
+
+var statsCommand = &Command{
+	Name:    "stats",
+	Summary: "Print totals from the download ledger for capacity reviews",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		top := fs.Int("top", 5, "Number of largest models to list")
+		all := fs.Bool("all", false, "Include the ledgers of every project")
+		return func(args []string) error {
+			if len(args) != 0 {
+				return errors.New("usage: stats [-top N] [-all]")
+			}
+			if *top < 1 {
+				return errors.New("-top must be at least 1")
+			}
+			var entries []LedgerEntry
+			var err error
+			if *all {
+				entries, err = allLedgerEntries()
+			} else {
+				entries, err = loadLedger()
+			}
+			if err != nil {
+				return err
+			}
+			if len(entries) == 0 {
+				fmt.Fprintln(os.Stderr, color.YellowString("[WARN] The ledger is empty."))
+				return nil
+			}
+
+			st := computeStats(entries, downloadDirs(entries), *top)
+			fmt.Println()
+			fmt.Println(color.CyanString("=== Download statistics ==="))
+			fmt.Printf("%-28s%d\n", "Models on disk:", st.Models)
+			if st.Missing > 0 {
+				fmt.Printf("%-28s%d\n", "Recorded but deleted:", st.Missing)
+			}
+			fmt.Printf("%-28s%s\n", "Total size:", formatBytes(st.OnDisk))
+			fmt.Printf("%-28s%s\n", "Actual disk usage:", formatBytes(st.Allocated))
+			if st.Partial > 0 {
+				fmt.Printf("%-28s%d\n", "Partial downloads:", st.Partial)
+			}
+			fmt.Printf("%-28s%s\n", "Downloaded this month:", formatBytes(st.ThisMonth))
+			fmt.Printf("%-28s%.0f%% (%d of %d pulls)\n", "Blob cache hit ratio:",
+				float64(st.CacheHits)*100/float64(st.TotalPulls), st.CacheHits, st.TotalPulls)
+
+			if len(st.Largest) > 0 {
+				fmt.Println(color.CyanString("\nLargest models:"))
+				for _, e := range st.Largest {
+					fmt.Printf("  %s%s\n", color.YellowString("%-12s", formatBytes(e.Size)), color.GreenString("%s:%s", e.Model, e.Params))
+				}
+			}
+			return nil
+		}
+	},
+}
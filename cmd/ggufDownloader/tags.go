@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+
+	"ggufDownloader/pkg/ollamareg"
+)
+
+// tagList is the registry's response to a tags/list request
+type tagList struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+// fetchTags lists the tags published for a model
+func fetchTags(ctx context.Context, modelName string) ([]string, error) {
+	url := registryURL(repoPath(modelName) + "/tags/list")
+	var cached []string
+	if loadMetadata("tags", url, manifestTTL, &cached) {
+		addProvenance(ctx, ProvenanceHop{Step: "search", URL: url, Detail: "reused from the metadata cache"})
+		return cached, nil
+	}
+	resp, err := httpGet(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{Op: "fetch tags", StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err := ollamareg.CheckJSON(resp, body); err != nil {
+		return nil, err
+	}
+
+	var list tagList
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, errors.New("invalid JSON response")
+	}
+	sort.Strings(list.Tags)
+	storeMetadata("tags", url, list.Tags)
+	return list.Tags, nil
+}
+
+// isTagPattern reports whether a tag contains glob wildcards
+func isTagPattern(tag string) bool {
+	return strings.ContainsAny(tag, "*?[")
+}
+
+// matchTags returns the tags of a model matching a glob pattern, ignoring case
+func matchTags(ctx context.Context, modelName, pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid tag pattern %q: %w", pattern, err)
+	}
+	tags, err := fetchTags(ctx, modelName)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []string
+	for _, tag := range tags {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(tag)); ok {
+			matched = append(matched, tag)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("no tags of %s match %q", modelName, pattern)
+	}
+	return matched, nil
+}
+
+var tagsCommand = &Command{
+	Name:    "tags",
+	Usage:   "MODEL",
+	Summary: "List the tags published for a model",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		sizes := fs.Bool("sizes", false, "Show the size, parameter size and quantization of each tag")
+		hints := fs.Bool("hints", false, "Show the size of each tag and how well it suits this machine")
+		hf := fs.Bool("hf", false, "Also show the model's GGUF repositories on Hugging Face and their quantizations")
+		hardware := addHardwareFlags(fs)
+		return func(args []string) error {
+			if len(args) != 1 {
+				return errors.New("usage: tags [-sizes | -hints] [-hf] MODEL")
+			}
+			ctx := context.Background()
+			tags, err := fetchTags(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			if len(tags) == 0 {
+				fmt.Fprintln(os.Stderr, color.YellowString("[WARN] %s has no tags", args[0]))
+				return nil
+			}
+			switch {
+			case *hints:
+				hw, err := hardware.probe()
+				if err != nil {
+					return err
+				}
+				printTagHints(ctx, args[0], tags, hw)
+			case *sizes:
+				printTagSizes(ctx, args[0], tags)
+			default:
+				for _, tag := range tags {
+					fmt.Println(color.GreenString("%s:%s", args[0], tag))
+				}
+			}
+			if *hf {
+				fmt.Println("\nOn Hugging Face (* not published by Ollama):")
+				printHFCrossReference(ctx, os.Stdout, args[0], tagQuantizations(tags))
+			}
+			return nil
+		}
+	},
+}
+
+// listTagsCommand prints the tags of a model with their sizes for the classic -tags flag
+func listTagsCommand(modelName string) error {
+	ctx := context.Background()
+	tags, err := fetchTags(ctx, modelName)
+	if err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] %s has no tags", modelName))
+		return nil
+	}
+	printTagSizes(ctx, modelName, tags)
+	fmt.Println(color.WhiteString("\nDownload one with: ggufDownloader -model %s -params TAG", modelName))
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/fatih/color"
+
+	"ggufDownloader/pkg/ollamareg"
+)
+
+// fsyncDownloads makes a download's file reach stable storage before it is reported
+// finished or kept for resuming, set with -fsync; without it a crash soon after can
+// still lose the bytes the kernel was holding
+var fsyncDownloads = true
+
+// partialPath returns where filename is downloaded to until it is complete
+func partialPath(filename string) string {
+	return filename + ".part"
+}
+
+// interruptContext returns a context canceled by the first Ctrl-C or SIGTERM, so
+// downloads stop through their error paths and leave their files in a known state. A
+// second signal kills the process as usual.
+func interruptContext() context.Context {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx
+}
+
+// keepPartial finishes with the FILE.part of a failed or interrupted download. Bytes
+// a resume can build on are kept, with their resume state, for the next pull to
+// continue; anything else is removed.
+func keepPartial(ctx context.Context, part string, resumable bool) {
+	info, err := os.Stat(part)
+	if err != nil {
+		discardCopies(ctx, part)
+		return
+	}
+	if !resumable || info.Size() == 0 {
+		discardPartial(ctx, part)
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Removed the incomplete %s", part))
+		return
+	}
+	fmt.Fprintln(os.Stderr, color.YellowString("[WARN] Kept %s of the incomplete download in %s; pull again to resume it", formatBytes(info.Size()), part))
+}
+
+// discardPartial removes a partial download, its resume state and its partial copies
+func discardPartial(ctx context.Context, part string) {
+	os.Remove(part)
+	ollamareg.ClearResumeState(part)
+	discardCopies(ctx, part)
+}
+
+// finishPartial gives a complete download its final name, carrying over the digest the
+// download recorded so verifying it later is still instant
+func finishPartial(ctx context.Context, part, filename string) error {
+	info, err := os.Stat(part)
+	if err != nil {
+		return err
+	}
+	abs, _ := filepath.Abs(part)
+	digest, known := cachedDigest(abs, info)
+	if err := os.Rename(part, filename); err != nil {
+		return err
+	}
+	ollamareg.ClearResumeState(part)
+	finishCopies(ctx, filename)
+	if known {
+		if abs, err := filepath.Abs(filename); err == nil {
+			storeDigest(abs, info, digest)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Default limits of the phases of a request; a stalled transfer is bounded separately
+// by -idle-timeout
+const (
+	defaultConnectTimeout  = 30 * time.Second
+	defaultManifestTimeout = time.Minute
+)
+
+var (
+	// connectTimeout bounds dialing and the TLS handshake of each connection, set with -connect-timeout
+	connectTimeout = defaultConnectTimeout
+	// manifestTimeout bounds resolving a tag to its manifest, set with -manifest-timeout
+	manifestTimeout = defaultManifestTimeout
+	// transferTimeout bounds one download from first request to last byte, including
+	// re-dials after stalls, set with -transfer-timeout; 0 lets a download take as long as it needs
+	transferTimeout time.Duration
+)
+
+// TimeoutError reports a phase of the work that ran past the limit set for it
+type TimeoutError struct {
+	Phase string
+	Limit time.Duration
+	// Flag is the option that sets the limit
+	Flag string
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out after %s (raise it with %s)", e.Phase, e.Limit, e.Flag)
+}
+
+// withPhaseTimeout bounds ctx by limit for one phase; a limit of 0 leaves it unbounded
+func withPhaseTimeout(ctx context.Context, phase, flag string, limit time.Duration) (context.Context, context.CancelFunc) {
+	if limit <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, limit, &TimeoutError{Phase: phase, Limit: limit, Flag: flag})
+}
+
+// timeoutCause returns the TimeoutError of a phase that ran out of time in place of the
+// bare "context deadline exceeded" the request failed with
+func timeoutCause(ctx context.Context, err error) error {
+	var timeout *TimeoutError
+	if err != nil && errors.As(context.Cause(ctx), &timeout) {
+		return timeout
+	}
+	return err
+}
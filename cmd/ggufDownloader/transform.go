@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"ggufDownloader/pkg/ollamareg"
+)
+
+// StreamTransformer rewrites a blob while it is being downloaded, so the
+// original and the converted file never need to exist on disk at the same time
+type StreamTransformer = ollamareg.Transformer
+
+// ExecTransformer pipes the blob through an external command, feeding it on
+// stdin and writing whatever the command prints on stdout to the output file
+type ExecTransformer struct {
+	Command string
+}
+
+// Transform runs the command with src as stdin and dst as stdout
+func (t ExecTransformer) Transform(dst io.Writer, src io.Reader) error {
+	cmd := shellCommand(t.Command)
+	cmd.Stdin = src
+	cmd.Stdout = dst
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("transform command %q failed: %w", t.Command, err)
+	}
+	return nil
+}
+
+// shellCommand wraps a command line in the platform's shell
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"ggufDownloader/pkg/ollamareg"
+)
+
+// DefaultRegistryHost is the public Ollama registry
+const DefaultRegistryHost = "registry.ollama.ai"
+
+// RegistryHost is the host (and port) serving manifests and blobs; a profile or
+// -registry-host points it at a private OCI registry instead
+var RegistryHost = DefaultRegistryHost
+
+// registryScheme is "http" only for private registries reached over plain HTTP
+var registryScheme = "https"
+
+// registryToken is sent as a bearer token with every request to the registry host
+var registryToken string
+
+// setRegistry targets the registry at addr ("host", "host:port" or a URL with an http or https scheme)
+func setRegistry(addr, token string) error {
+	registryToken = token
+	if addr == "" {
+		return nil
+	}
+	scheme := "https"
+	if strings.Contains(addr, "://") {
+		u, err := url.Parse(addr)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid registry %q (expected host[:port] or http(s)://host[:port])", addr)
+		}
+		scheme, addr = u.Scheme, u.Host
+	}
+	RegistryHost, registryScheme = strings.TrimSuffix(addr, "/"), scheme
+	return nil
+}
+
+// registryClient returns a registry client with the tool's current registry settings
+// and shared HTTP client
+func registryClient() *ollamareg.Client {
+	c := &ollamareg.Client{
+		Host:            RegistryHost,
+		Scheme:          registryScheme,
+		Namespace:       defaultNamespace,
+		Token:           registryToken,
+		UserAgent:       UserAgent,
+		HTTPClient:      httpClient,
+		IdleTimeout:     idleTimeout,
+		OnStall:         noteStall,
+		Connections:     downloadConnections,
+		ChunkSize:       downloadChunkSize,
+		Budget:          connectionBudget,
+		SingleStream:    &singleStream,
+		Throttle:        throttle,
+		ThrottleHashing: throttleHashing,
+		OpenOutput:      openOutput,
+		NoSync:          !fsyncDownloads,
+		Hashed:          recordDigest,
+		Logf:            warnTransfer,
+		Tracer:          telemetry.tracer(),
+	}
+	if hfToken != "" {
+		c.Tokens = map[string]string{HuggingFaceHost: hfToken}
+	}
+	return c
+}
+
+// registryURL returns the URL of a registry API path below /v2/
+func registryURL(path string) string {
+	return registryClient().URL(path)
+}
+
+// registryHostname returns RegistryHost without its port
+func registryHostname() string {
+	if host, _, err := net.SplitHostPort(RegistryHost); err == nil {
+		return host
+	}
+	return RegistryHost
+}
+
+// localName turns a model name into a file name component, flattening registry namespaces
+func localName(modelName string) string {
+	return strings.ReplaceAll(modelName, "/", "_")
+}
+
+// defaultNamespace is the registry namespace of bare model names, "library" unless a
+// profile sets "namespace"
+var defaultNamespace = "library"
+
+// setNamespace makes bare model names resolve to namespace; empty keeps "library"
+func setNamespace(namespace string) error {
+	if namespace == "" {
+		return nil
+	}
+	if strings.ContainsAny(namespace, "/:@ ") {
+		return fmt.Errorf("invalid namespace %q (expected a single path component, e.g. acme)", namespace)
+	}
+	defaultNamespace = namespace
+	return nil
+}
+
+// repoPath returns the registry repository of a model; bare names live in the default namespace
+func repoPath(modelName string) string {
+	return registryClient().RepoPath(modelName)
+}
+
+// httpClient is shared by every request the tool makes
+var httpClient = &http.Client{}
+
+// TransportOptions controls how connections are established
+type TransportOptions struct {
+	MinTLS string
+	Pins   []string
+	// Family forces IPv4 ("4") or IPv6 ("6"); empty dials both with happy eyeballs
+	Family string
+	// Registry overrides the registry host from the profile
+	Registry string
+	// SSHTunnel is a bastion, [user@]host[:port], to route every request through
+	SSHTunnel string
+	// Proxy is the proxy URL requests go through, such as the SSH tunnel's SOCKS port
+	Proxy string
+	// Privacy refuses requests to any host but the registry
+	Privacy bool
+	// Record is a cassette file every exchange is written to
+	Record string
+	// Replay is a cassette file requests are answered from instead of the network
+	Replay string
+}
+
+// StatusError is an unexpected HTTP status in response to a request
+type StatusError = ollamareg.StatusError
+
+// PinError is returned when the registry presents a certificate chain matching none of the configured pins
+type PinError struct {
+	Host     string
+	Got      []string
+	Expected []string
+}
+
+func (e *PinError) Error() string {
+	return fmt.Sprintf("certificate pin validation failed for %s: server presented %s, expected one of %s",
+		e.Host, strings.Join(e.Got, ", "), strings.Join(e.Expected, ", "))
+}
+
+// parseTLSVersion converts a version string such as "1.3" into its crypto/tls constant
+func parseTLSVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported minimum TLS version %q (use 1.2 or 1.3)", version)
+	}
+}
+
+// spkiPin returns the pin string for a certificate's public key in "sha256/<base64>" form
+func spkiPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "sha256/" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// normalizePin accepts pins with or without the "sha256/" prefix
+func normalizePin(pin string) (string, error) {
+	pin = strings.TrimPrefix(strings.TrimSpace(pin), "sha256/")
+	raw, err := base64.StdEncoding.DecodeString(pin)
+	if err != nil || len(raw) != sha256.Size {
+		return "", fmt.Errorf("invalid SPKI pin %q (expected base64 SHA-256 digest)", pin)
+	}
+	return "sha256/" + pin, nil
+}
+
+// verifyPins returns a VerifyConnection callback enforcing the pins for the registry host
+func verifyPins(pins []string) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		if cs.ServerName != registryHostname() {
+			return nil
+		}
+
+		var got []string
+		for _, cert := range cs.PeerCertificates {
+			pin := spkiPin(cert)
+			for _, expected := range pins {
+				if pin == expected {
+					return nil
+				}
+			}
+			got = append(got, pin)
+		}
+		return &PinError{Host: cs.ServerName, Got: got, Expected: pins}
+	}
+}
+
+// newHTTPClient builds an HTTP client honoring the given transport options
+func newHTTPClient(opts TransportOptions) (*http.Client, error) {
+	minVersion, err := parseTLSVersion(opts.MinTLS)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{MinVersion: minVersion}
+	if len(opts.Pins) > 0 {
+		var pins []string
+		for _, p := range opts.Pins {
+			pin, err := normalizePin(p)
+			if err != nil {
+				return nil, err
+			}
+			pins = append(pins, pin)
+		}
+		tlsConfig.VerifyConnection = verifyPins(pins)
+	}
+
+	dialer, err := newFamilyDialer(opts.Family)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.DialContext = dialer.DialContext
+	transport.TLSHandshakeTimeout = connectTimeout
+	if opts.Proxy != "" {
+		proxy, err := url.Parse(opts.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %q: %w", opts.Proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	var next http.RoundTripper = transport
+	switch {
+	case opts.Replay != "":
+		if next, err = newReplayTransport(opts.Replay); err != nil {
+			return nil, err
+		}
+	case opts.Record != "":
+		if next, err = newRecordingTransport(transport, opts.Record); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Privacy {
+		next = &privacyTransport{next: next}
+	}
+	return &http.Client{Transport: &tracingTransport{next: next}}, nil
+}
+
+// newRequest builds a request carrying the tool's user agent and the token of the host it goes to
+func newRequest(ctx context.Context, method, url string) (*http.Request, error) {
+	return registryClient().NewRequest(ctx, method, url)
+}
+
+// httpRequest issues a request with the tool's user agent through the shared client
+func httpRequest(ctx context.Context, method, url string) (*http.Response, error) {
+	req, err := newRequest(ctx, method, url)
+	if err != nil {
+		return nil, err
+	}
+	return httpClient.Do(req)
+}
+
+// httpGet issues a GET request through the shared client
+func httpGet(ctx context.Context, url string) (*http.Response, error) {
+	return httpRequest(ctx, http.MethodGet, url)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fatih/color"
+
+	"ggufDownloader/pkg/ollamareg"
+)
+
+// Settings tune tries; every connection count is tried with every chunk size
+var (
+	tuneConnections = []int{1, 2, 4, 8}
+	tuneChunkSizes  = []int64{16 << 20, 64 << 20, 256 << 20}
+)
+
+// tuneTolerance is how close to the fastest trial a cheaper setting must come to be
+// recommended instead: fewer connections are kinder to the server, and larger chunks
+// mean fewer requests
+const tuneTolerance = 0.95
+
+// tuneTrial is the throughput measured for one setting
+type tuneTrial struct {
+	connections int
+	chunk       int64
+	bytes       int64
+	elapsed     time.Duration
+}
+
+func (t tuneTrial) rate() float64 {
+	if t.elapsed <= 0 {
+		return 0
+	}
+	return float64(t.bytes) / t.elapsed.Seconds()
+}
+
+// countingWriter counts the bytes written to it and discards them
+type countingWriter struct {
+	n *atomic.Int64
+}
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	c.n.Add(int64(len(p)))
+	return len(p), nil
+}
+
+// runTuneTrial downloads ranges of the blob at url over connections connections for
+// duration, requesting chunk bytes at a time, and reports how much arrived. Requests are
+// spread over the blob and wrap around its end, so short blobs can be measured too.
+func runTuneTrial(ctx context.Context, url string, size int64, connections int, chunk int64, duration time.Duration) (tuneTrial, error) {
+	trial := tuneTrial{connections: connections, chunk: chunk}
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+
+	var received, next atomic.Int64
+	var once sync.Once
+	var firstErr error
+	var wg sync.WaitGroup
+	started := time.Now()
+	for c := 0; c < connections; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				start := (next.Add(chunk) - chunk) % size
+				end := min(start+chunk, size) - 1
+				resp, err := registryClient().GetRange(ctx, url, start, end, nil)
+				if err == nil {
+					if err = ollamareg.CheckContentRange(resp, start, end, size); err == nil {
+						_, err = io.Copy(countingWriter{&received}, throttle(resp.Body))
+					}
+					resp.Body.Close()
+				}
+				if err != nil && ctx.Err() == nil {
+					once.Do(func() { firstErr = err; cancel() })
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	trial.bytes, trial.elapsed = received.Load(), time.Since(started)
+	return trial, firstErr
+}
+
+// recommendTrial picks the trial to recommend: the one with the fewest connections, then
+// the largest chunks, that comes within tuneTolerance of the fastest
+func recommendTrial(trials []tuneTrial) tuneTrial {
+	var fastest float64
+	for _, t := range trials {
+		fastest = max(fastest, t.rate())
+	}
+	best := trials[0]
+	for _, t := range trials {
+		if t.rate() < fastest*tuneTolerance {
+			continue
+		}
+		if best.rate() < fastest*tuneTolerance || t.connections < best.connections ||
+			(t.connections == best.connections && t.chunk > best.chunk) {
+			best = t
+		}
+	}
+	return best
+}
+
+var tuneCommand = &Command{
+	Name:    "tune",
+	Usage:   "MODEL[:TAG]",
+	Summary: "Measure download speed with several settings and recommend -connections and -chunk-size",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		duration := fs.Duration("duration", 3*time.Second, "How long to measure each setting")
+		return func(args []string) error {
+			if len(args) != 1 {
+				return errors.New("usage: tune [-duration D] MODEL[:TAG]")
+			}
+			return tuneDownloads(args[0], *duration)
+		}
+	},
+}
+
+// tuneDownloads measures how fast the blob of ref downloads over each combination of
+// connections and chunk size, prints the results, and recommends the settings to use
+// on this network path
+func tuneDownloads(ref string, duration time.Duration) error {
+	modelName, tag, err := parseModelRef(ref)
+	if err != nil {
+		return err
+	}
+	if duration <= 0 {
+		return errors.New("-duration must be positive")
+	}
+	ctx := context.Background()
+	manifest, err := fetchManifest(ctx, modelName, tag)
+	if err != nil {
+		return err
+	}
+	layer := manifest.ModelLayer()
+	if layer == nil {
+		return errors.New("model digest not found in manifest")
+	}
+	url := blobURL(modelName, layer.Digest)
+
+	total := time.Duration(len(tuneConnections)*len(tuneChunkSizes)) * duration
+	fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Measuring %s:%s (%s) for about %s; nothing is written to disk",
+		modelName, tag, formatBytes(layer.Size), total.Round(time.Second)))
+
+	fmt.Println(color.CyanString("%-12s %-10s %s", "CONNECTIONS", "CHUNK", "THROUGHPUT"))
+	var trials []tuneTrial
+	for _, connections := range tuneConnections {
+		for _, chunk := range tuneChunkSizes {
+			// A chunk larger than the blob measures the same as the whole blob
+			if chunk > layer.Size && chunk != tuneChunkSizes[0] {
+				continue
+			}
+			trial, err := runTuneTrial(ctx, url, layer.Size, connections, chunk, duration)
+			if err != nil {
+				return fmt.Errorf("%d connection(s), %s chunks: %w", connections, formatBytes(chunk), err)
+			}
+			fmt.Printf("%-12d %-10s %s/s\n", connections, formatBytes(chunk), formatBytes(int64(trial.rate())))
+			trials = append(trials, trial)
+		}
+	}
+
+	best := recommendTrial(trials)
+	if best.bytes == 0 {
+		return errors.New("nothing was received; check the connection with diagnose")
+	}
+	if downloadLimiter != nil {
+		fmt.Fprintln(os.Stderr, color.YellowString("[WARN] -limit-rate capped every trial, so they measure the limit rather than the network"))
+	}
+	fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] Recommended: -connections %d -chunk-size %s (%s/s)",
+		best.connections, sizeFlagValue(best.chunk), formatBytes(int64(best.rate()))))
+	return nil
+}
+
+// sizeFlagValue writes a whole number of MiB the way size flags accept it, e.g. "64M"
+func sizeFlagValue(n int64) string {
+	return fmt.Sprintf("%dM", n>>20)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+
+	"github.com/fatih/color"
+)
+
+// Upstream states of a downloaded model
+const (
+	upstreamCurrent     = "current"
+	upstreamUpdated     = "updated"
+	upstreamDigestGone  = "digest gone"
+	upstreamTagRemoved  = "tag removed"
+	upstreamModelGone   = "model removed"
+	upstreamUnreachable = "unknown"
+)
+
+// upstreamCheck is what the registry says today about a model:tag downloaded earlier
+type upstreamCheck struct {
+	Ref    string
+	Path   string
+	Status string
+	Detail string
+}
+
+// yanked reports whether the model can no longer be downloaded as it was
+func (c upstreamCheck) yanked() bool {
+	switch c.Status {
+	case upstreamDigestGone, upstreamTagRemoved, upstreamModelGone:
+		return true
+	}
+	return false
+}
+
+// isNotFound reports whether err is a 404 from the registry
+func isNotFound(err error) bool {
+	var status *StatusError
+	return errors.As(err, &status) && status.StatusCode == http.StatusNotFound
+}
+
+// checkUpstream asks the registry whether model:tag still exists and still serves digest
+func checkUpstream(ctx context.Context, modelName, tag, digest string) (status, detail string) {
+	// The store may hold a manifest from before the tag was removed, so ask the registry
+	manifest, err := downloadManifest(ctx, modelName, tag)
+	switch {
+	case isNotFound(err):
+		tags, tagsErr := fetchTags(ctx, modelName)
+		if isNotFound(tagsErr) || (tagsErr == nil && len(tags) == 0) {
+			return upstreamModelGone, "the model no longer exists in the registry"
+		}
+		return upstreamTagRemoved, fmt.Sprintf("the tag was removed or renamed; see ggufDownloader tags %s", modelName)
+	case err != nil:
+		return upstreamUnreachable, err.Error()
+	}
+
+	layer := manifest.ModelLayer()
+	switch {
+	case layer == nil:
+		return upstreamUnreachable, "the manifest has no model layer"
+	case digest == "" || layer.Digest == digest:
+		return upstreamCurrent, ""
+	}
+
+	// A new build replaced the old one; check whether the old blob can still be fetched
+	resp, err := httpRequest(ctx, http.MethodHead, blobURL(modelName, digest))
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return upstreamDigestGone, fmt.Sprintf("now %s; the downloaded build %s was deleted upstream", shortDigest(layer.Digest), shortDigest(digest))
+		}
+	}
+	return upstreamUpdated, fmt.Sprintf("now %s", shortDigest(layer.Digest))
+}
+
+// checkLedgerUpstream checks the latest download of every model:tag in the ledger
+func checkLedgerUpstream(entries []LedgerEntry) []upstreamCheck {
+	latest := make(map[string]LedgerEntry)
+	for _, e := range entries {
+		// Converted Hugging Face files have no registry counterpart
+		if e.Params == "safetensors" || e.Params == "gguf" {
+			continue
+		}
+		ref := e.Model + ":" + e.Params
+		if prev, ok := latest[ref]; !ok || e.DownloadedAt.After(prev.DownloadedAt) {
+			latest[ref] = e
+		}
+	}
+
+	ctx := context.Background()
+	checks := make([]upstreamCheck, 0, len(latest))
+	for ref, e := range latest {
+		var status, detail string
+		if e.Source == sourceHF {
+			status, detail = checkHFUpstream(ctx, e.URL, e.Digest)
+		} else {
+			status, detail = checkUpstream(ctx, e.Model, e.Params, e.Digest)
+		}
+		checks = append(checks, upstreamCheck{Ref: ref, Path: e.Path, Status: status, Detail: detail})
+	}
+	sort.Slice(checks, func(i, j int) bool { return checks[i].Ref < checks[j].Ref })
+	return checks
+}
+
+// checkHFUpstream asks Hugging Face whether the file downloaded from url is still
+// published with digest
+func checkHFUpstream(ctx context.Context, url, digest string) (status, detail string) {
+	repo, name, ok := parseHFURL(url)
+	if !ok {
+		return upstreamUnreachable, fmt.Sprintf("%q is not a Hugging Face file URL", url)
+	}
+	files, err := listHFGGUFs(ctx, repo)
+	if err != nil {
+		return upstreamUnreachable, err.Error()
+	}
+	for _, f := range files {
+		switch {
+		case f.Name != name:
+		case digest == "" || f.Digest() == "" || f.Digest() == digest:
+			return upstreamCurrent, ""
+		default:
+			return upstreamUpdated, fmt.Sprintf("now %s", shortDigest(f.Digest()))
+		}
+	}
+	return upstreamTagRemoved, fmt.Sprintf("%s is no longer in %s; see ggufDownloader -source hf -model %s", name, repo, repo)
+}
+
+var updatesCommand = &Command{
+	Name:    "updates",
+	Summary: "Check downloaded models for upstream updates and removed tags",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		all := fs.Bool("all", false, "Include the ledgers of every project")
+		return func(args []string) error {
+			if len(args) != 0 {
+				return errors.New("usage: updates [-all]")
+			}
+			var entries []LedgerEntry
+			var err error
+			if *all {
+				entries, err = allLedgerEntries()
+			} else {
+				entries, err = loadLedger()
+			}
+			if err != nil {
+				return err
+			}
+			if len(entries) == 0 {
+				fmt.Fprintln(os.Stderr, color.YellowString("[WARN] The ledger is empty."))
+				return nil
+			}
+
+			checks := checkLedgerUpstream(entries)
+			fmt.Println()
+			fmt.Println(color.CyanString("%-40s %-14s %s", "MODEL", "UPSTREAM", "DETAILS"))
+			yanked := 0
+			for _, c := range checks {
+				status := color.GreenString("%-14s", c.Status)
+				switch {
+				case c.yanked():
+					status = color.RedString("%-14s", c.Status)
+					yanked++
+				case c.Status != upstreamCurrent:
+					status = color.YellowString("%-14s", c.Status)
+				}
+				fmt.Printf("%-40s %s %s\n", c.Ref, status, c.Detail)
+			}
+			fmt.Println()
+
+			if yanked > 0 {
+				fmt.Fprintln(os.Stderr, color.RedString("[WARN] %d downloaded model(s) can no longer be downloaded as they are; keep or back up these files before rebuilding machines:", yanked))
+				for _, c := range checks {
+					if c.yanked() {
+						fmt.Fprintln(os.Stderr, color.RedString("  %s (%s)", c.Path, c.Ref))
+					}
+				}
+			}
+			return nil
+		}
+	},
+}
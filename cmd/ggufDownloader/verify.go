@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/fatih/color"
+
+	"ggufDownloader/pkg/ollamareg"
+)
+
+// ledgerDigest returns the digest the ledger recorded for path
+func ledgerDigest(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	entries, err := loadLedger()
+	if err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		if e.Path == abs && e.Digest != "" {
+			return e.Digest, nil
+		}
+	}
+	return "", fmt.Errorf("%s is not in the ledger; pass -digest", path)
+}
+
+// verifyFile checks a file's contents against the expected digest, hashing with the
+// digest's own algorithm
+func verifyFile(path, want string) error {
+	algo, _, err := ollamareg.ParseDigest(want)
+	if err != nil {
+		return err
+	}
+	got, err := digestFile(path, algo, "Verifying "+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("digest mismatch for %s: expected %s, got %s", path, want, got)
+	}
+	return nil
+}
+
+var verifyCommand = &Command{
+	Name:    "verify",
+	Usage:   "FILE",
+	Summary: "Check a downloaded file against its digest",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		digest := fs.String("digest", "", "Expected digest (algorithm:hex, e.g. sha256:<hex>); defaults to the one recorded in the ledger")
+		fs.BoolVar(&rehash, "rehash", false, "Hash the file again instead of trusting the checksum cache")
+		return func(args []string) error {
+			if len(args) != 1 {
+				return errors.New("usage: verify [-digest D] [-rehash] FILE")
+			}
+			path, want := resolveFile(args[0]), *digest
+			if want == "" {
+				var err error
+				if want, err = ledgerDigest(path); err != nil {
+					return err
+				}
+			}
+			fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Verifying %s...", path))
+			if err := verifyFile(path, want); err != nil {
+				return err
+			}
+			fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] %s matches %s", path, want))
+			return nil
+		}
+	},
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// verifyResult is the outcome of checking one file in verify-all
+type verifyResult struct {
+	Path   string
+	Status string // "pass", "fail", "missing" or "unknown"
+	Detail string
+}
+
+// expectedDigest finds the digest a file should have, from the ledger or, if
+// registry is set, from the manifest named by its model:tag.gguf filename
+func expectedDigest(ctx context.Context, path string, ledger map[string]string, registry bool) (string, string, error) {
+	if digest, ok := ledger[path]; ok && digest != "" && !registry {
+		return digest, "ledger", nil
+	}
+	if !registry {
+		return "", "", nil
+	}
+
+	// Files saved under another -naming policy are identified by their sidecar
+	modelName, tag, err := parseModelRef(strings.TrimSuffix(filepath.Base(path), ".gguf"))
+	var sidecar Sidecar
+	if data, readErr := os.ReadFile(sidecarPath(path)); readErr == nil && json.Unmarshal(data, &sidecar) == nil && sidecar.Model != "" && sidecar.Tag != "" {
+		modelName, tag, err = sidecar.Model, sidecar.Tag, nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	manifest, err := fetchManifest(ctx, modelName, tag)
+	if err != nil {
+		return "", "", err
+	}
+	layer := manifest.ModelLayer()
+	if layer == nil {
+		return "", "", errors.New("model digest not found in manifest")
+	}
+	if digest, ok := ledger[path]; ok && digest != "" && digest != layer.Digest {
+		return "", "", fmt.Errorf("ledger records %s but the registry now serves %s", digest, layer.Digest)
+	}
+	return layer.Digest, "registry", nil
+}
+
+// verifyOne hashes a file and compares it with its expected digest
+func verifyOne(ctx context.Context, path string, ledger map[string]string, registry bool) verifyResult {
+	want, source, err := expectedDigest(ctx, path, ledger, registry)
+	if err != nil {
+		return verifyResult{path, "fail", err.Error()}
+	}
+
+	got, err := fileDigestAs(path, digestAlgorithm(want))
+	if err != nil {
+		return verifyResult{path, "fail", err.Error()}
+	}
+	switch {
+	case want == "":
+		return verifyResult{path, "unknown", got + " (no recorded digest)"}
+	case got != want:
+		return verifyResult{path, "fail", fmt.Sprintf("expected %s (%s), got %s", want, source, got)}
+	default:
+		return verifyResult{path, "pass", got + " (" + source + ")"}
+	}
+}
+
+// verifyAll hashes every .gguf file in dir with a pool of workers
+func verifyAll(dir string, workers int, registry bool) ([]verifyResult, error) {
+	files, err := modelFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := allLedgerEntries()
+	if err != nil {
+		return nil, err
+	}
+	ledger := make(map[string]string, len(entries))
+	for _, e := range entries {
+		ledger[e.Path] = e.Digest
+	}
+
+	ctx := context.Background()
+	return verifyFiles(files, workers, func(path string) verifyResult {
+		return verifyOne(ctx, path, ledger, registry)
+	}), nil
+}
+
+// verifyFiles runs check on every file with a pool of workers, sorting the results by path
+func verifyFiles(files []string, workers int, check func(path string) verifyResult) []verifyResult {
+	if workers < 1 {
+		workers = 1
+	}
+	paths := make(chan string)
+	results := make([]verifyResult, 0, len(files))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				res := check(path)
+				mu.Lock()
+				results = append(results, res)
+				mu.Unlock()
+				fmt.Fprintln(os.Stderr, color.WhiteString("  checked %s: %s", filepath.Base(path), res.Status))
+			}
+		}()
+	}
+	for _, f := range files {
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			abs = f
+		}
+		paths <- abs
+	}
+	close(paths)
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Path < results[j].Path })
+	return results
+}
+
+var verifyAllCommand = &Command{
+	Name:    "verify-all",
+	Usage:   "DIR",
+	Summary: "Verify every .gguf file in a directory in parallel",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		workers := fs.Int("workers", runtime.NumCPU(), "Number of files to hash at once")
+		registry := fs.Bool("registry", false, "Cross-check each model:tag.gguf file against the digest the registry currently serves")
+		fs.BoolVar(&rehash, "rehash", false, "Hash every file again instead of trusting the checksum cache")
+		sums := fs.String("sums", "", "Verify against a SHA256SUMS-style checksum list or an Ollama manifest instead of the ledger")
+		return func(args []string) error {
+			if len(args) != 1 {
+				return errors.New("usage: verify-all [-workers N] [-registry | -sums FILE] DIR")
+			}
+			if *sums != "" && *registry {
+				return errors.New("-sums and -registry are mutually exclusive")
+			}
+			if info, err := os.Stat(args[0]); err != nil {
+				return err
+			} else if !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", args[0])
+			}
+
+			fmt.Fprintln(os.Stderr, color.CyanString("[INFO] Verifying models in %s with %d workers...", args[0], *workers))
+			var results []verifyResult
+			var err error
+			if *sums != "" {
+				var list *checksumList
+				if list, err = readChecksumList(*sums); err != nil {
+					return err
+				}
+				results, err = verifyAgainstList(args[0], list, *workers)
+			} else {
+				results, err = verifyAll(args[0], *workers, *registry)
+			}
+			if err != nil {
+				return err
+			}
+			if len(results) == 0 {
+				fmt.Fprintln(os.Stderr, color.YellowString("[WARN] No .gguf files found in %s", args[0]))
+				return nil
+			}
+
+			counts := make(map[string]int)
+			fmt.Println()
+			for _, r := range results {
+				counts[r.Status]++
+				var status string
+				switch r.Status {
+				case "pass":
+					status = color.GreenString("%-8s", "PASS")
+				case "fail":
+					status = color.RedString("%-8s", "FAIL")
+				case "missing":
+					status = color.RedString("%-8s", "MISSING")
+				default:
+					status = color.YellowString("%-8s", "UNKNOWN")
+				}
+				fmt.Printf("%s%s\n", status, filepath.Base(r.Path))
+				fmt.Println(color.WhiteString("        %s", r.Detail))
+			}
+
+			fmt.Println()
+			summary := fmt.Sprintf("%d passed, %d failed, %d without a digest", counts["pass"], counts["fail"], counts["unknown"])
+			if counts["missing"] > 0 {
+				summary += fmt.Sprintf(", %d missing", counts["missing"])
+			}
+			if counts["fail"] > 0 || counts["missing"] > 0 {
+				return errors.New(summary)
+			}
+			fmt.Fprintln(os.Stderr, color.GreenString("[SUCCESS] %s", summary))
+			return nil
+		}
+	},
+}
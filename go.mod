@@ -6,6 +6,9 @@ require (
 	github.com/PuerkitoBio/goquery v1.8.1
 	github.com/fatih/color v1.15.0
 	github.com/schollz/progressbar/v3 v3.13.1
+	golang.org/x/net v0.7.0
+	golang.org/x/sys v0.6.0
+	golang.org/x/term v0.6.0
 )
 
 require (
@@ -15,7 +18,4 @@ require (
 	github.com/mattn/go-runewidth v0.0.14 // indirect
 	github.com/mitchellh/colorstring v0.0.0-20190213212951-d06e56a500db // indirect
 	github.com/rivo/uniseg v0.2.0 // indirect
-	golang.org/x/net v0.7.0 // indirect
-	golang.org/x/sys v0.6.0 // indirect
-	golang.org/x/term v0.6.0 // indirect
 )
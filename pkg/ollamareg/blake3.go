@@ -0,0 +1,221 @@
+package ollamareg
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+// A straightforward BLAKE3 hasher after the reference implementation, so "blake3:"
+// digests can be verified without another dependency. It hashes one chunk at a time
+// with no SIMD, which is plenty for checking downloads.
+
+const (
+	blake3BlockLen = 64
+	blake3ChunkLen = 1024
+
+	blake3ChunkStart = 1 << 0
+	blake3ChunkEnd   = 1 << 1
+	blake3Parent     = 1 << 2
+	blake3Root       = 1 << 3
+)
+
+var blake3IV = [8]uint32{
+	0x6A09E667, 0xBB67AE85, 0x3C6EF372, 0xA54FF53A, 0x510E527F, 0x9B05688C, 0x1F83D9AB, 0x5BE0CD19,
+}
+
+var blake3Permutation = [16]int{2, 6, 3, 10, 7, 0, 4, 13, 1, 11, 12, 5, 9, 14, 15, 8}
+
+// blake3G is the quarter-round mixing function
+func blake3G(s *[16]uint32, a, b, c, d int, mx, my uint32) {
+	s[a] += s[b] + mx
+	s[d] = bits.RotateLeft32(s[d]^s[a], -16)
+	s[c] += s[d]
+	s[b] = bits.RotateLeft32(s[b]^s[c], -12)
+	s[a] += s[b] + my
+	s[d] = bits.RotateLeft32(s[d]^s[a], -8)
+	s[c] += s[d]
+	s[b] = bits.RotateLeft32(s[b]^s[c], -7)
+}
+
+// blake3Compress runs the compression function over one block
+func blake3Compress(cv *[8]uint32, block *[16]uint32, counter uint64, blockLen, flags uint32) [16]uint32 {
+	s := [16]uint32{
+		cv[0], cv[1], cv[2], cv[3], cv[4], cv[5], cv[6], cv[7],
+		blake3IV[0], blake3IV[1], blake3IV[2], blake3IV[3],
+		uint32(counter), uint32(counter >> 32), blockLen, flags,
+	}
+	m := *block
+	for round := 0; round < 7; round++ {
+		blake3G(&s, 0, 4, 8, 12, m[0], m[1])
+		blake3G(&s, 1, 5, 9, 13, m[2], m[3])
+		blake3G(&s, 2, 6, 10, 14, m[4], m[5])
+		blake3G(&s, 3, 7, 11, 15, m[6], m[7])
+		blake3G(&s, 0, 5, 10, 15, m[8], m[9])
+		blake3G(&s, 1, 6, 11, 12, m[10], m[11])
+		blake3G(&s, 2, 7, 8, 13, m[12], m[13])
+		blake3G(&s, 3, 4, 9, 14, m[14], m[15])
+		var permuted [16]uint32
+		for i, p := range blake3Permutation {
+			permuted[i] = m[p]
+		}
+		m = permuted
+	}
+	for i := 0; i < 8; i++ {
+		s[i] ^= s[i+8]
+		s[i+8] ^= cv[i]
+	}
+	return s
+}
+
+// blake3Words reads a block as little-endian words, zero-padding a short one
+func blake3Words(block []byte) [16]uint32 {
+	var padded [blake3BlockLen]byte
+	copy(padded[:], block)
+	var words [16]uint32
+	for i := range words {
+		words[i] = binary.LittleEndian.Uint32(padded[i*4:])
+	}
+	return words
+}
+
+// blake3Output is a node whose compression is still pending: either its chaining value
+// is needed by a parent, or it is the root and produces the hash
+type blake3Output struct {
+	cv       [8]uint32
+	block    [16]uint32
+	counter  uint64
+	blockLen uint32
+	flags    uint32
+}
+
+func (o *blake3Output) chainingValue() [8]uint32 {
+	s := blake3Compress(&o.cv, &o.block, o.counter, o.blockLen, o.flags)
+	var cv [8]uint32
+	copy(cv[:], s[:8])
+	return cv
+}
+
+func (o *blake3Output) rootBytes(out []byte) {
+	for counter := uint64(0); len(out) > 0; counter++ {
+		s := blake3Compress(&o.cv, &o.block, counter, o.blockLen, o.flags|blake3Root)
+		var block [blake3BlockLen]byte
+		for i, w := range s {
+			binary.LittleEndian.PutUint32(block[i*4:], w)
+		}
+		out = out[copy(out, block[:]):]
+	}
+}
+
+// blake3Chunk hashes the blocks of one 1 KiB chunk
+type blake3Chunk struct {
+	cv         [8]uint32
+	counter    uint64
+	block      [blake3BlockLen]byte
+	blockLen   int
+	compressed int
+}
+
+func (c *blake3Chunk) len() int {
+	return c.compressed*blake3BlockLen + c.blockLen
+}
+
+func (c *blake3Chunk) startFlag() uint32 {
+	if c.compressed == 0 {
+		return blake3ChunkStart
+	}
+	return 0
+}
+
+func (c *blake3Chunk) update(p []byte) {
+	for len(p) > 0 {
+		if c.blockLen == blake3BlockLen {
+			words := blake3Words(c.block[:])
+			s := blake3Compress(&c.cv, &words, c.counter, blake3BlockLen, c.startFlag())
+			copy(c.cv[:], s[:8])
+			c.compressed++
+			c.block = [blake3BlockLen]byte{}
+			c.blockLen = 0
+		}
+		n := copy(c.block[c.blockLen:], p)
+		c.blockLen += n
+		p = p[n:]
+	}
+}
+
+func (c *blake3Chunk) output() blake3Output {
+	return blake3Output{
+		cv: c.cv, block: blake3Words(c.block[:c.blockLen]), counter: c.counter,
+		blockLen: uint32(c.blockLen), flags: c.startFlag() | blake3ChunkEnd,
+	}
+}
+
+// blake3ParentOutput joins the chaining values of two subtrees
+func blake3ParentOutput(left, right [8]uint32) blake3Output {
+	o := blake3Output{cv: blake3IV, blockLen: blake3BlockLen, flags: blake3Parent}
+	copy(o.block[:8], left[:])
+	copy(o.block[8:], right[:])
+	return o
+}
+
+// blake3Hasher is a hash.Hash computing 32-byte BLAKE3 digests
+type blake3Hasher struct {
+	chunk   blake3Chunk
+	stack   [54][8]uint32
+	stackLn int
+}
+
+// newBlake3 returns a BLAKE3 hasher in its default (unkeyed) mode
+func newBlake3() hash.Hash {
+	h := &blake3Hasher{}
+	h.Reset()
+	return h
+}
+
+func (h *blake3Hasher) Reset() {
+	h.chunk = blake3Chunk{cv: blake3IV}
+	h.stackLn = 0
+}
+
+func (h *blake3Hasher) Size() int      { return 32 }
+func (h *blake3Hasher) BlockSize() int { return blake3BlockLen }
+
+// addChunkCV pushes a finished chunk, merging completed subtrees; the number of
+// trailing zero bits of total is how many subtrees it completes
+func (h *blake3Hasher) addChunkCV(cv [8]uint32, total uint64) {
+	for total&1 == 0 {
+		h.stackLn--
+		out := blake3ParentOutput(h.stack[h.stackLn], cv)
+		cv = out.chainingValue()
+		total >>= 1
+	}
+	h.stack[h.stackLn] = cv
+	h.stackLn++
+}
+
+func (h *blake3Hasher) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		// A full chunk is only finished once more input arrives, since the last one is the root
+		if h.chunk.len() == blake3ChunkLen {
+			out := h.chunk.output()
+			total := h.chunk.counter + 1
+			h.addChunkCV(out.chainingValue(), total)
+			h.chunk = blake3Chunk{cv: blake3IV, counter: total}
+		}
+		take := min(blake3ChunkLen-h.chunk.len(), len(p))
+		h.chunk.update(p[:take])
+		p = p[take:]
+	}
+	return n, nil
+}
+
+func (h *blake3Hasher) Sum(b []byte) []byte {
+	out := h.chunk.output()
+	for i := h.stackLn - 1; i >= 0; i-- {
+		out = blake3ParentOutput(h.stack[i], out.chainingValue())
+	}
+	var sum [32]byte
+	out.rootBytes(sum[:])
+	return append(b, sum[:]...)
+}
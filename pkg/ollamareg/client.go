@@ -0,0 +1,231 @@
+// Package ollamareg is a client for Ollama model registries: it resolves model tags to
+// manifests, reads their configs and downloads their blobs, resuming, splitting and
+// verifying them. It is the download engine of the ggufDownloader command.
+//
+//	client := ollamareg.NewClient()
+//	manifest, err := client.Download(ctx, "llama3", "8b", "llama3-8b.gguf", ollamareg.DownloadOptions{})
+package ollamareg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultHost is the public Ollama registry
+const DefaultHost = "registry.ollama.ai"
+
+// maxMetadataSize guards against a mislabeled config pulling gigabytes into memory
+const maxMetadataSize = 16 << 20
+
+// StatusError reports a registry response with an unexpected status
+type StatusError struct {
+	Op         string
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return "failed to " + e.Op + ": " + e.Status
+}
+
+// Client talks to one registry. The zero value is not usable; start from NewClient.
+type Client struct {
+	// Host is the registry's host and port, DefaultHost unless a mirror is used
+	Host string
+	// Scheme is "https", or "http" for a private registry reached over plain HTTP
+	Scheme string
+	// Namespace is the namespace of model names without one, "library" on the public registry
+	Namespace string
+	// Token is sent as a bearer token with every request to Host, if set
+	Token string
+	// Tokens are bearer tokens for other hosts downloads are made from, by host name
+	Tokens map[string]string
+	// UserAgent is sent with every request, if set
+	UserAgent string
+	// HTTPClient makes the requests; http.DefaultClient when nil
+	HTTPClient *http.Client
+
+	// IdleTimeout re-dials a download that has received nothing for this long; 0 waits
+	// on a silent connection forever
+	IdleTimeout time.Duration
+	// OnStall is called before a stalled download of filename is re-dialed for the
+	// redial-th time; nil logs it
+	OnStall func(filename string, err error, redial int)
+	// Connections and ChunkSize override the automatic choice of how many connections a
+	// large download is split over and how large the ranges they request are
+	Connections int
+	ChunkSize   int64
+	// Budget bounds the connections open at once across downloads; nil is unbounded
+	Budget *ConnectionBudget
+	// SingleStream, once set, keeps downloads to one stream that is neither resumed nor
+	// split. A registry download sets it when its response shows an intermediary
+	// rewriting bodies; it is a pointer so the clients of one process can share it.
+	SingleStream *atomic.Bool
+	// Throttle and ThrottleHashing wrap what downloads read from the network and from
+	// disk for hashing, such as to limit bandwidth; nil reads at full speed
+	Throttle        func(io.Reader) io.Reader
+	ThrottleHashing func(io.Reader) io.Reader
+	// OpenOutput opens the file a download writes; OpenOutput when nil
+	OpenOutput func(filename string, offset int64) (io.WriteCloser, error)
+	// NoSync skips syncing a finished download to stable storage
+	NoSync bool
+	// Hashed receives the SHA-256 digest of a download hashed on its way to disk, such as
+	// to cache it for later verification
+	Hashed func(filename, digest string)
+	// Logf reports problems a download recovers from; nil discards them
+	Logf func(format string, args ...any)
+	// Tracer receives manifest and config fetches, downloads and the ranges of split
+	// downloads as spans; nil traces nothing
+	Tracer Tracer
+}
+
+// NewClient returns a client of the public Ollama registry
+func NewClient() *Client {
+	return &Client{Host: DefaultHost, Scheme: "https", Namespace: "library", IdleTimeout: DefaultIdleTimeout}
+}
+
+// RepoPath returns the registry path of a model, adding the namespace to bare names
+func (c *Client) RepoPath(model string) string {
+	if strings.Contains(model, "/") {
+		return model
+	}
+	return c.Namespace + "/" + model
+}
+
+// URL returns the URL of a registry API path below /v2/
+func (c *Client) URL(path string) string {
+	return c.Scheme + "://" + c.Host + "/v2/" + path
+}
+
+// ManifestURL returns the URL of the manifest of model at ref, a tag or a digest
+func (c *Client) ManifestURL(model, ref string) string {
+	return c.URL(c.RepoPath(model) + "/manifests/" + ref)
+}
+
+// BlobURL returns the URL of a blob of model
+func (c *Client) BlobURL(model, digest string) string {
+	return c.URL(c.RepoPath(model) + "/blobs/" + digest)
+}
+
+// NewRequest builds a request carrying the client's user agent, and its token when the
+// request goes to the registry
+func (c *Client) NewRequest(ctx context.Context, method, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	// The HTTP client drops the header itself when the registry redirects to another host
+	if c.Token != "" && req.URL.Host == c.Host {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	} else if token := c.Tokens[req.URL.Host]; token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+// httpClient returns the HTTP client requests go through
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
+
+// do sends a request through the client's HTTP client
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	return c.httpClient().Do(req)
+}
+
+// logf reports a problem through Logf
+func (c *Client) logf(format string, args ...any) {
+	if c.Logf != nil {
+		c.Logf(format, args...)
+	}
+}
+
+// throttle applies Throttle to a download body
+func (c *Client) throttle(r io.Reader) io.Reader {
+	if c.Throttle == nil {
+		return r
+	}
+	return c.Throttle(r)
+}
+
+// throttleHashing applies ThrottleHashing to a file being hashed
+func (c *Client) throttleHashing(r io.Reader) io.Reader {
+	if c.ThrottleHashing == nil {
+		return r
+	}
+	return c.ThrottleHashing(r)
+}
+
+// openOutput opens a download's file through OpenOutput
+func (c *Client) openOutput(filename string, offset int64) (io.WriteCloser, error) {
+	if c.OpenOutput == nil {
+		return OpenOutput(filename, offset)
+	}
+	return c.OpenOutput(filename, offset)
+}
+
+// get fetches url, failing with a *StatusError for anything but 200 OK
+func (c *Client) get(ctx context.Context, op, url string) ([]byte, *http.Response, error) {
+	req, err := c.NewRequest(ctx, http.MethodGet, url)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp, &StatusError{Op: op, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	if resp.ContentLength > maxMetadataSize {
+		return nil, resp, fmt.Errorf("%s: response of %d bytes is too large", op, resp.ContentLength)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxMetadataSize))
+	return body, resp, err
+}
+
+// Manifest fetches the manifest of model at ref, a tag or a digest
+func (c *Client) Manifest(ctx context.Context, model, ref string) (manifest *Manifest, err error) {
+	ctx, span := c.startSpan(ctx, "ollamareg.Manifest", Attr{"ollamareg.model", model}, Attr{"ollamareg.ref", ref})
+	defer func() { span.End(err) }()
+	body, resp, err := c.get(ctx, "fetch manifest", c.ManifestURL(model, ref))
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckJSON(resp, body); err != nil {
+		return nil, err
+	}
+	return ParseManifest(body, resp.Header.Get("Docker-Content-Digest"))
+}
+
+// Config fetches and decodes the config blob of a manifest
+func (c *Client) Config(ctx context.Context, model string, manifest *Manifest) (config *ModelConfig, err error) {
+	if manifest.Config.Digest == "" {
+		return nil, errors.New("manifest has no config descriptor")
+	}
+	ctx, span := c.startSpan(ctx, "ollamareg.Config", Attr{"ollamareg.model", model}, Attr{"ollamareg.digest", manifest.Config.Digest})
+	defer func() { span.End(err) }()
+	body, _, err := c.get(ctx, "fetch config", c.BlobURL(model, manifest.Config.Digest))
+	if err != nil {
+		return nil, err
+	}
+	var cfg ModelConfig
+	if err := json.Unmarshal(body, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid model config: %w", err)
+	}
+	return &cfg, nil
+}
@@ -0,0 +1,65 @@
+package ollamareg
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// digestAlgorithms are the algorithms "algo:hex" digests may use; OCI registries use
+// sha256 almost everywhere, but sha512 and blake3 are registered algorithms too
+var digestAlgorithms = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+	"blake3": newBlake3,
+}
+
+// ParseDigest splits an "algo:hex" digest, checking that the algorithm is supported
+// and the hex has the length it produces
+func ParseDigest(digest string) (algo, encoded string, err error) {
+	algo, encoded, ok := strings.Cut(digest, ":")
+	if !ok || algo == "" {
+		return "", "", fmt.Errorf("invalid digest %q (expected algorithm:hex, e.g. sha256:<hex>)", digest)
+	}
+	newHash, ok := digestAlgorithms[algo]
+	if !ok {
+		return "", "", fmt.Errorf("unsupported digest algorithm %q in %q", algo, digest)
+	}
+	raw, err := hex.DecodeString(encoded)
+	if err != nil || len(raw) != newHash().Size() || strings.ToLower(encoded) != encoded {
+		return "", "", fmt.Errorf("invalid %s digest %q", algo, digest)
+	}
+	return algo, encoded, nil
+}
+
+// NewHash returns a hash computing digests of algo: sha256, sha384, sha512 or blake3
+func NewHash(algo string) (hash.Hash, error) {
+	newHash, ok := digestAlgorithms[algo]
+	if !ok {
+		return nil, fmt.Errorf("unsupported digest algorithm %q", algo)
+	}
+	return newHash(), nil
+}
+
+// FileDigest reads the file at path and returns the "algo:<hex>" digest of its contents
+func FileDigest(path, algo string) (string, error) {
+	h, err := NewHash(algo)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%x", algo, h.Sum(nil)), nil
+}
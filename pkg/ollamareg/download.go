@@ -0,0 +1,65 @@
+package ollamareg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Download saves the weights of model:tag to path, returning the manifest they came
+// from. The blob is written to path.part first and renamed once complete and verified;
+// an interrupted download leaves path.part behind and the next call resumes it.
+func (c *Client) Download(ctx context.Context, model, tag, path string, opts DownloadOptions) (manifest *Manifest, err error) {
+	ctx, span := c.startSpan(ctx, "ollamareg.Download", Attr{"ollamareg.model", model}, Attr{"ollamareg.ref", tag})
+	defer func() { span.End(err) }()
+	manifest, err = c.Manifest(ctx, model, tag)
+	if err != nil {
+		return nil, err
+	}
+	layer := manifest.ModelLayer()
+	if layer == nil {
+		return manifest, errors.New("manifest has no model layer")
+	}
+	if opts.Check == nil {
+		opts.Check = CheckGGUF
+	}
+	return manifest, c.DownloadBlob(ctx, model, *layer, path, opts)
+}
+
+// DownloadBlob saves one layer of model to path through path.part, continuing a
+// path.part an earlier call left behind, and verifies it against the layer's digest
+// before renaming it into place
+func (c *Client) DownloadBlob(ctx context.Context, model string, layer Layer, path string, opts DownloadOptions) (err error) {
+	ctx, span := c.startSpan(ctx, "ollamareg.DownloadBlob", Attr{"ollamareg.model", model}, Attr{"ollamareg.digest", layer.Digest})
+	defer func() { span.End(err) }()
+	algo, _, err := ParseDigest(layer.Digest)
+	if err != nil && !opts.NoVerify {
+		return err
+	}
+	url := c.BlobURL(model, layer.Digest)
+	part := path + ".part"
+	var offset int64
+	if info, err := os.Stat(part); err == nil && CanResume(part, url) && (layer.Size <= 0 || info.Size() <= layer.Size) {
+		offset = info.Size()
+	}
+	opts.Registry = true
+	digest, err := c.downloadFile(ctx, url, part, offset, opts)
+	if err != nil {
+		return err
+	}
+	if !opts.NoVerify {
+		// The digest hashed on the way to disk is SHA-256; other algorithms read the file
+		if digest == "" || algo != "sha256" {
+			if digest, err = FileDigest(part, algo); err != nil {
+				return err
+			}
+		}
+		if digest != layer.Digest {
+			os.Remove(part)
+			ClearResumeState(part)
+			return fmt.Errorf("digest mismatch: expected %s, got %s", layer.Digest, digest)
+		}
+	}
+	return os.Rename(part, path)
+}
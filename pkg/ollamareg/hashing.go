@@ -0,0 +1,251 @@
+package ollamareg
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// checkpointInterval is how many downloaded bytes pass between two hash checkpoints
+const checkpointInterval = 256 << 20
+
+// hashProgressThreshold is the size from which hashing a resumed prefix reports progress
+const hashProgressThreshold = 256 << 20
+
+// hashQueueDepth is how many written buffers may wait for the hashing goroutine before
+// the download waits for it in turn
+const hashQueueDepth = 64
+
+// hashCheckpoint is the SHA-256 state after the first Offset bytes of a partial download
+type hashCheckpoint struct {
+	Offset int64  `json:"offset"`
+	State  []byte `json:"state"`
+}
+
+// streamHash hashes a download as it is written, saving checkpoints in its resume
+// state, so the finished file's digest is known without reading it back
+type streamHash struct {
+	h        hash.Hash
+	written  int64
+	next     int64
+	state    *resumeState
+	filename string
+}
+
+// newStreamHash returns a streamHash for a download of filename that starts from the
+// beginning and has no resume state to checkpoint into
+func newStreamHash(filename string) *streamHash {
+	return &streamHash{h: sha256.New(), filename: filename, next: checkpointInterval}
+}
+
+// resumeHash returns a streamHash that has already hashed the first offset bytes of
+// filename, restoring the latest checkpoint at or before offset and reading only the
+// bytes after it. It returns nil when the prefix cannot be read; the file is then
+// verified in full afterwards as before.
+func (c *Client) resumeHash(s *resumeState, filename string, offset int64, opts DownloadOptions) *streamHash {
+	sh := &streamHash{h: sha256.New(), state: s, filename: filename}
+	var from int64
+	for i := len(s.Checkpoints) - 1; i >= 0; i-- {
+		cp := s.Checkpoints[i]
+		if cp.Offset > offset {
+			continue
+		}
+		if err := sh.h.(encoding.BinaryUnmarshaler).UnmarshalBinary(cp.State); err != nil {
+			sh.h.Reset()
+			break
+		}
+		from = cp.Offset
+		break
+	}
+	// Checkpoints past the end of the file describe bytes that are no longer there
+	kept := s.Checkpoints[:0]
+	for _, cp := range s.Checkpoints {
+		if cp.Offset <= from {
+			kept = append(kept, cp)
+		}
+	}
+	s.Checkpoints = kept
+
+	if from < offset {
+		f, err := os.Open(filename)
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+		var w io.Writer = sh.h
+		if offset-from >= hashProgressThreshold && opts.Progress != nil {
+			progress := opts.Progress("Hashing "+filepath.Base(filename), offset-from, 0)
+			defer progress.Finish()
+			w = io.MultiWriter(sh.h, progress)
+		}
+		if _, err := io.Copy(w, c.throttleHashing(io.NewSectionReader(f, from, offset-from))); err != nil {
+			return nil
+		}
+	}
+	sh.written = offset
+	sh.next = from + checkpointInterval
+	return sh
+}
+
+func (sh *streamHash) Write(p []byte) (int, error) {
+	sh.h.Write(p)
+	sh.written += int64(len(p))
+	if sh.written >= sh.next {
+		sh.checkpoint()
+		sh.next = sh.written + checkpointInterval
+	}
+	return len(p), nil
+}
+
+// checkpoint records the current hash state in the resume state; a failure only costs
+// re-reading more of the file after the next resume
+func (sh *streamHash) checkpoint() {
+	if sh.state == nil {
+		return
+	}
+	state, err := sh.h.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return
+	}
+	sh.state.Checkpoints = append(sh.state.Checkpoints, hashCheckpoint{Offset: sh.written, State: state})
+	sh.state.save(sh.filename)
+}
+
+// record returns the digest of the finished file, handing it to Client.Hashed, or ""
+// when the file on disk is not what was hashed
+func (c *Client) record(sh *streamHash) string {
+	info, err := os.Stat(sh.filename)
+	if err != nil || info.Size() != sh.written {
+		return ""
+	}
+	digest := fmt.Sprintf("sha256:%x", sh.h.Sum(nil))
+	if c.Hashed != nil {
+		c.Hashed(sh.filename, digest)
+	}
+	return digest
+}
+
+// backgroundWriter passes what is written to it on to w in its own goroutine, so a
+// slow writer such as a hash runs alongside the network reads instead of between them
+type backgroundWriter struct {
+	w     io.Writer
+	queue chan *[]byte
+	done  chan struct{}
+	pool  sync.Pool
+	once  sync.Once
+}
+
+func newBackgroundWriter(w io.Writer) *backgroundWriter {
+	b := &backgroundWriter{w: w, queue: make(chan *[]byte, hashQueueDepth), done: make(chan struct{})}
+	b.pool.New = func() any { return new([]byte) }
+	go func() {
+		defer close(b.done)
+		for buf := range b.queue {
+			b.w.Write(*buf)
+			b.pool.Put(buf)
+		}
+	}()
+	return b
+}
+
+// Write queues a copy of p, since the caller reuses its buffer
+func (b *backgroundWriter) Write(p []byte) (int, error) {
+	buf := b.pool.Get().(*[]byte)
+	*buf = append((*buf)[:0], p...)
+	b.queue <- buf
+	return len(p), nil
+}
+
+// Close waits until everything written has reached w; later calls return at once
+func (b *backgroundWriter) Close() error {
+	b.once.Do(func() { close(b.queue) })
+	<-b.done
+	return nil
+}
+
+// prefixHasher hashes a file that several connections write out of order, following
+// the part that is complete from the start. The bytes are read back while they are
+// still in the page cache, so the digest is ready soon after the last chunk lands
+// instead of after reading the whole file again.
+type prefixHasher struct {
+	sh       *streamHash
+	file     *os.File
+	throttle func(io.Reader) io.Reader
+	mu       sync.Mutex
+	ready    int64
+	wake     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+	// failed stops hashing after a read error; the file is then verified in full
+	failed bool
+}
+
+// newPrefixHasher starts hashing filename from where sh stands; it returns nil when
+// there is no hash to continue or the file cannot be read
+func (c *Client) newPrefixHasher(sh *streamHash, filename string) *prefixHasher {
+	if sh == nil {
+		return nil
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil
+	}
+	p := &prefixHasher{sh: sh, file: f, throttle: c.throttleHashing, ready: sh.written, wake: make(chan struct{}, 1), done: make(chan struct{})}
+	go func() {
+		defer close(p.done)
+		for {
+			_, ok := <-p.wake
+			p.catchUp()
+			if !ok {
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// advance reports that the first n bytes of the file are complete
+func (p *prefixHasher) advance(n int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.ready = max(p.ready, n)
+	p.mu.Unlock()
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// catchUp hashes the bytes completed since the last call
+func (p *prefixHasher) catchUp() {
+	p.mu.Lock()
+	ready := p.ready
+	p.mu.Unlock()
+	if p.failed || ready <= p.sh.written {
+		return
+	}
+	from := p.sh.written
+	if _, err := io.Copy(p.sh, p.throttle(io.NewSectionReader(p.file, from, ready-from))); err != nil {
+		p.failed = true
+	}
+}
+
+// finish hashes what is left of the complete prefix and stops; no advance may follow,
+// and later calls return at once
+func (p *prefixHasher) finish() {
+	if p == nil {
+		return
+	}
+	p.once.Do(func() {
+		close(p.wake)
+		<-p.done
+		p.file.Close()
+	})
+}
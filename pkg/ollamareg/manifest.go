@@ -0,0 +1,165 @@
+package ollamareg
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// MediaType identifies what a layer of an Ollama manifest holds
+type MediaType string
+
+// Media types of the layers found in Ollama manifests
+const (
+	MediaTypeModel     MediaType = "application/vnd.ollama.image.model"
+	MediaTypeTemplate  MediaType = "application/vnd.ollama.image.template"
+	MediaTypeParams    MediaType = "application/vnd.ollama.image.params"
+	MediaTypeSystem    MediaType = "application/vnd.ollama.image.system"
+	MediaTypeLicense   MediaType = "application/vnd.ollama.image.license"
+	MediaTypeMessages  MediaType = "application/vnd.ollama.image.messages"
+	MediaTypeProjector MediaType = "application/vnd.ollama.image.projector"
+	MediaTypeAdapter   MediaType = "application/vnd.ollama.image.adapter"
+)
+
+// IsModel reports whether the layer holds the model weights
+func (t MediaType) IsModel() bool { return t == MediaTypeModel }
+
+// IsAdapter reports whether the layer holds a LoRA adapter applied to the weights
+func (t MediaType) IsAdapter() bool { return t == MediaTypeAdapter }
+
+// IsProjector reports whether the layer holds the vision projector of a multimodal model
+func (t MediaType) IsProjector() bool { return t == MediaTypeProjector }
+
+// IsTemplate reports whether the layer holds the prompt template
+func (t MediaType) IsTemplate() bool { return t == MediaTypeTemplate }
+
+// IsMetadata reports whether the layer is small metadata rather than weights
+func (t MediaType) IsMetadata() bool {
+	_, ok := t.MetadataFile()
+	return ok
+}
+
+// metadataFiles maps the small, text-like layers to the file they are saved as
+var metadataFiles = map[MediaType]string{
+	MediaTypeTemplate: "template.txt",
+	MediaTypeParams:   "params.json",
+	MediaTypeSystem:   "system.txt",
+	MediaTypeLicense:  "license.txt",
+	MediaTypeMessages: "messages.json",
+}
+
+// MetadataFile returns the file name a metadata layer is saved as, such as
+// "template.txt", and false for layers that are not metadata
+func (t MediaType) MetadataFile() (string, bool) {
+	name, ok := metadataFiles[t]
+	return name, ok
+}
+
+// Layer is a blob a manifest references, the config included
+type Layer struct {
+	MediaType MediaType `json:"mediaType"`
+	Digest    string    `json:"digest"`
+	Size      int64     `json:"size"`
+}
+
+// Manifest lists the config and layers of one tag of a model
+type Manifest struct {
+	Config Layer   `json:"config"`
+	Layers []Layer `json:"layers"`
+
+	// Digest identifies the manifest itself, as reported by the registry or computed from its bytes
+	Digest string `json:"-"`
+	// Raw holds the bytes the manifest was parsed from, which Digest covers
+	Raw []byte `json:"-"`
+}
+
+// ParseManifest decodes a manifest. digest is the one the registry reported for it;
+// when empty, it is computed from data.
+func ParseManifest(data []byte, digest string) (*Manifest, error) {
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	manifest.Digest, manifest.Raw = digest, data
+	if manifest.Digest == "" {
+		manifest.Digest = fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+	}
+	return &manifest, nil
+}
+
+// ModelLayer returns the layer holding the model weights, or nil if the manifest has none
+func (m *Manifest) ModelLayer() *Layer {
+	for i := range m.Layers {
+		if m.Layers[i].MediaType.IsModel() {
+			return &m.Layers[i]
+		}
+	}
+	return nil
+}
+
+// LayersByType returns the layers of the manifest with the given media type, in
+// manifest order; a model may ship several, such as a license and a usage policy
+func (m *Manifest) LayersByType(t MediaType) []Layer {
+	var layers []Layer
+	for _, l := range m.Layers {
+		if l.MediaType == t {
+			layers = append(layers, l)
+		}
+	}
+	return layers
+}
+
+// ModelConfig is the config blob Ollama references from a manifest's config descriptor
+type ModelConfig struct {
+	ModelFormat   string   `json:"model_format"`
+	ModelFamily   string   `json:"model_family"`
+	ModelFamilies []string `json:"model_families,omitempty"`
+	ModelType     string   `json:"model_type"`
+	FileType      string   `json:"file_type"`
+	Architecture  string   `json:"architecture,omitempty"`
+	OS            string   `json:"os,omitempty"`
+	// ParameterSize and QuantizationLevel are the names "ollama show" gives model_type
+	// and file_type; some registries write the config with them instead
+	ParameterSize     string `json:"parameter_size,omitempty"`
+	QuantizationLevel string `json:"quantization_level,omitempty"`
+}
+
+// Family returns the model family, e.g. "llama"
+func (c *ModelConfig) Family() string {
+	if c.ModelFamily == "" && len(c.ModelFamilies) > 0 {
+		return c.ModelFamilies[0]
+	}
+	return c.ModelFamily
+}
+
+// Parameters returns the parameter size, e.g. "8.0B"
+func (c *ModelConfig) Parameters() string {
+	if c.ModelType == "" {
+		return c.ParameterSize
+	}
+	return c.ModelType
+}
+
+// Quantization returns the quantization level, e.g. "Q4_K_M"
+func (c *ModelConfig) Quantization() string {
+	if c.FileType == "" {
+		return c.QuantizationLevel
+	}
+	return c.FileType
+}
+
+// Summary renders the config as "family, parameter size, quantization (format)"
+func (c *ModelConfig) Summary() string {
+	var parts []string
+	for _, s := range []string{c.Family(), c.Parameters(), c.Quantization()} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	summary := strings.Join(parts, ", ")
+	if c.ModelFormat != "" {
+		summary += " (" + c.ModelFormat + ")"
+	}
+	return summary
+}
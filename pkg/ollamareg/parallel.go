@@ -0,0 +1,267 @@
+package ollamareg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ConnectionBudget bounds the transfer connections open at once across every download
+// that shares it; a nil budget leaves them unbounded
+type ConnectionBudget struct {
+	slots chan struct{}
+}
+
+// NewConnectionBudget allows n transfer connections at once; n <= 0 returns nil, no bound
+func NewConnectionBudget(n int) *ConnectionBudget {
+	if n <= 0 {
+		return nil
+	}
+	return &ConnectionBudget{slots: make(chan struct{}, n)}
+}
+
+// acquire waits for a slot; a download holds one for its first connection, so it never
+// starts without being able to make progress
+func (b *ConnectionBudget) acquire(ctx context.Context) (release func(), err error) {
+	if b == nil {
+		return func() {}, nil
+	}
+	select {
+	case b.slots <- struct{}{}:
+		return func() { <-b.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// tryAcquire takes a slot for an extra connection if one is free; a download short of
+// slots carries on with the connections it has
+func (b *ConnectionBudget) tryAcquire() (release func(), ok bool) {
+	if b == nil {
+		return func() {}, true
+	}
+	select {
+	case b.slots <- struct{}{}:
+		return func() { <-b.slots }, true
+	default:
+		return nil, false
+	}
+}
+
+// Limits of the automatic plan
+const (
+	parallelThreshold = 100 << 20 // smaller blobs download over one connection
+	maxConnections    = 8
+	minChunkSize      = 16 << 20
+	maxChunkSize      = 256 << 20
+	chunkAttempts     = 3
+)
+
+// planDownload picks the connections and chunk size for a blob of size bytes whose
+// first response took rtt to arrive. Large blobs get a connection per GiB on top of
+// two, and long round trips get more, since each TCP stream's throughput is bounded
+// by its window over the round trip. Chunks are small enough that fast connections
+// take over the work of slow ones.
+func (c *Client) planDownload(size int64, rtt time.Duration) (connections int, chunk int64) {
+	connections = c.Connections
+	if connections <= 0 {
+		switch {
+		case size < parallelThreshold:
+			connections = 1
+		default:
+			connections = 2 + int(size>>30)
+			if rtt >= 100*time.Millisecond {
+				connections += 2
+			}
+			if rtt >= 250*time.Millisecond {
+				connections += 2
+			}
+		}
+	}
+	connections = min(connections, maxConnections)
+	if connections <= 1 {
+		return 1, size
+	}
+	if c.ChunkSize > 0 {
+		return connections, min(c.ChunkSize, size)
+	}
+	chunk = min(max(size/int64(connections*4), minChunkSize), maxChunkSize)
+	return connections, chunk
+}
+
+// lockedWriter serializes writes to a progress renderer shared by several connections
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+// parallelDownload writes bytes offset to size of a blob into file over several
+// connections. The first response, already streaming from offset, serves the first
+// chunk; the others are fetched with range requests that insist on the same version of
+// the file. Completed chunks are hashed into hashed, when given, as soon as no gap
+// precedes them. On failure it returns how many leading bytes of the file are complete,
+// so the caller can keep a resumable prefix.
+func (c *Client) parallelDownload(ctx context.Context, url string, file *os.File, copies Copies, first *http.Response, body io.Reader,
+	offset, size int64, connections int, chunk int64, progress io.Writer, hashed *streamHash) (int64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	chunks := int((size - offset + chunk - 1) / chunk)
+	written := make([]int64, chunks)
+	bar := &lockedWriter{w: progress}
+
+	hasher := c.newPrefixHasher(hashed, file.Name())
+	defer hasher.finish()
+	var doneMu sync.Mutex
+	finished := make([]bool, chunks)
+	next := 0
+	// chunkDone hands the prefix without gaps to the hasher
+	chunkDone := func(i int) {
+		doneMu.Lock()
+		finished[i] = true
+		for next < chunks && finished[next] {
+			next++
+		}
+		prefix := min(offset+int64(next)*chunk, size)
+		doneMu.Unlock()
+		hasher.advance(prefix)
+	}
+
+	fetch := func(i int) (err error) {
+		start := offset + int64(i)*chunk
+		end := min(start+chunk, size)
+		ctx, span := c.startSpan(ctx, "ollamareg.range", Attr{"ollamareg.range.start", start}, Attr{"ollamareg.range.end", end - 1})
+		defer func() { span.End(err) }()
+		var lastErr error
+		for attempt := 0; attempt < chunkAttempts; attempt++ {
+			from := start + written[i]
+			if from == end {
+				return nil
+			}
+			var src io.Reader
+			if i == 0 && attempt == 0 {
+				src = io.LimitReader(body, end-offset)
+			} else {
+				resp, err := c.GetRange(ctx, url, from, end-1, first)
+				if err != nil {
+					lastErr = err
+					continue
+				}
+				if err := CheckContentRange(resp, from, end-1, size); err != nil {
+					resp.Body.Close()
+					// Server errors are often transient; a wrong answer is not
+					if resp.StatusCode >= 500 {
+						lastErr = err
+						continue
+					}
+					return err
+				}
+				defer resp.Body.Close()
+				src = c.throttle(resp.Body)
+			}
+			var dst io.Writer = io.NewOffsetWriter(file, from)
+			if copies != nil {
+				dst = io.MultiWriter(dst, io.NewOffsetWriter(copies, from))
+			}
+			n, err := io.Copy(io.MultiWriter(dst, bar), src)
+			written[i] += n
+			if err == nil && start+written[i] < end {
+				err = io.ErrUnexpectedEOF
+			}
+			if err == nil || ctx.Err() != nil {
+				return err
+			}
+			lastErr = err
+		}
+		return fmt.Errorf("bytes %d-%d: %w", start, end-1, lastErr)
+	}
+
+	jobs := make(chan int, chunks)
+	for i := 0; i < chunks; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	var once sync.Once
+	var firstErr error
+	var wg sync.WaitGroup
+	for n := 0; n < connections; n++ {
+		// The first connection is the download's own; the others come out of the budget
+		release := func() {}
+		if n > 0 {
+			var ok bool
+			if release, ok = c.Budget.tryAcquire(); !ok {
+				break
+			}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer release()
+			for i := range jobs {
+				if ctx.Err() != nil {
+					return
+				}
+				if err := fetch(i); err != nil {
+					once.Do(func() { firstErr = err; cancel() })
+					return
+				}
+				chunkDone(i)
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr == nil {
+		hasher.finish()
+		return size, nil
+	}
+
+	// Chunks finish out of order; only the bytes up to the first gap can be resumed
+	prefix := offset
+	for i := range written {
+		prefix += written[i]
+		if start := offset + int64(i)*chunk; start+written[i] < min(start+chunk, size) {
+			break
+		}
+	}
+	return prefix, firstErr
+}
+
+// GetRange requests bytes start-end of url. With version, an earlier response for the
+// same URL, the server is asked to refuse with 412 if the file no longer matches it.
+func (c *Client) GetRange(ctx context.Context, url string, start, end int64, version *http.Response) (*http.Response, error) {
+	req, err := c.NewRequest(ctx, http.MethodGet, url)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	if version != nil {
+		if state := newResumeState(url, version); state != nil {
+			state.setPreconditions(req)
+		}
+	}
+	return c.doWatched(req)
+}
+
+// CheckContentRange verifies that a range response holds exactly the bytes asked for
+// of a file of size bytes
+func CheckContentRange(resp *http.Response, start, end, size int64) error {
+	if resp.StatusCode != http.StatusPartialContent {
+		return &StatusError{Op: "download range", StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	want := fmt.Sprintf("bytes %d-%d/", start, end)
+	if got := resp.Header.Get("Content-Range"); !strings.HasPrefix(got, want) || (!strings.HasSuffix(got, "/*") && got != fmt.Sprintf("%s%d", want, size)) {
+		return fmt.Errorf("server answered range %d-%d with %q", start, end, got)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package ollamareg
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// resumeState remembers which version of a remote file a partial download holds, so a
+// resume can ask the server to refuse if the file has changed since
+type resumeState struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	// Checkpoints are hash states of the bytes written so far, so a resumed download's
+	// digest is known without reading the whole prefix back
+	Checkpoints []hashCheckpoint `json:"checkpoints,omitempty"`
+}
+
+// ResumeStatePath returns where the resume state of a partial download is kept
+func ResumeStatePath(filename string) string {
+	return filename + ".resume"
+}
+
+// CanResume reports whether filename holds a partial download of url that records
+// which version of the file it is, so DownloadFile can continue it
+func CanResume(filename, url string) bool {
+	return loadResumeState(filename, url) != nil
+}
+
+// ClearResumeState removes the resume state of a download that has completed or is
+// given up on
+func ClearResumeState(filename string) {
+	os.Remove(ResumeStatePath(filename))
+}
+
+// newResumeState captures the validators of a fresh download's response; weak ETags
+// are dropped because If-Match never matches them
+func newResumeState(url string, resp *http.Response) *resumeState {
+	state := &resumeState{URL: url, LastModified: resp.Header.Get("Last-Modified")}
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		state.ETag = etag
+	}
+	if state.ETag == "" && state.LastModified == "" {
+		return nil
+	}
+	return state
+}
+
+// loadResumeState returns the validators recorded for a partial download of url, or nil
+// when there are none or they belong to another URL, such as a different mirror
+func loadResumeState(filename, url string) *resumeState {
+	data, err := os.ReadFile(ResumeStatePath(filename))
+	if err != nil {
+		return nil
+	}
+	var state resumeState
+	if json.Unmarshal(data, &state) != nil || state.URL != url {
+		return nil
+	}
+	return &state
+}
+
+// save records the state next to the partial download
+func (s *resumeState) save(filename string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(ResumeStatePath(filename), append(data, '\n'))
+}
+
+// setPreconditions makes the request fail with 412 if the remote file no longer matches
+func (s *resumeState) setPreconditions(req *http.Request) {
+	if s.ETag != "" {
+		req.Header.Set("If-Match", s.ETag)
+	}
+	if s.LastModified != "" {
+		req.Header.Set("If-Unmodified-Since", s.LastModified)
+	}
+}
+
+// writeFileAtomic replaces path with data through a temporary file of its own in the
+// same directory
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,106 @@
+package ollamareg
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// SniffLength is how many leading bytes are inspected before trusting a response
+const SniffLength = 512
+
+// ggufMagic opens every GGUF file
+var ggufMagic = []byte("GGUF")
+
+// InterferenceError is returned when a response is not the kind of data the registry serves,
+// typically because a captive portal or proxy answered in its place
+type InterferenceError struct {
+	URL         string
+	Expected    string
+	ContentType string
+	Prefix      string
+}
+
+func (e *InterferenceError) Error() string {
+	return fmt.Sprintf("expected %s from %s but received %q content starting with %q; "+
+		"a captive portal or proxy is probably intercepting requests (sign in to the network or check proxy settings)",
+		e.Expected, e.URL, e.ContentType, e.Prefix)
+}
+
+// newInterferenceError builds the diagnostic from the response and the bytes seen
+func newInterferenceError(resp *http.Response, expected string, prefix []byte) *InterferenceError {
+	shown := prefix
+	if len(shown) > 64 {
+		shown = shown[:64]
+	}
+	text := string(shown)
+	if !utf8.ValidString(text) {
+		text = fmt.Sprintf("% x", shown)
+	}
+	return &InterferenceError{
+		URL:         resp.Request.URL.Host,
+		Expected:    expected,
+		ContentType: resp.Header.Get("Content-Type"),
+		Prefix:      strings.TrimSpace(text),
+	}
+}
+
+// strippedHeaders names the headers a registry always sends but a blob response lacks,
+// as happens behind SSL-inspecting proxies that buffer and re-encode bodies
+func strippedHeaders(resp *http.Response) []string {
+	var missing []string
+	if resp.ContentLength < 0 {
+		missing = append(missing, "Content-Length")
+	}
+	if resp.StatusCode == http.StatusOK && resp.Header.Get("Accept-Ranges") != "bytes" {
+		missing = append(missing, "Accept-Ranges")
+	}
+	return missing
+}
+
+// NoteStrippedHeaders switches the client to SingleStream, warning the first time, when
+// a registry blob response has lost its length or range headers
+func (c *Client) NoteStrippedHeaders(resp *http.Response) {
+	missing := strippedHeaders(resp)
+	if len(missing) == 0 || c.SingleStream == nil || c.SingleStream.Swap(true) {
+		return
+	}
+	c.logf("%s sent no %s; a proxy is probably rewriting downloads. "+
+		"Resuming is off for this run and downloads are verified against their digests", resp.Request.URL.Host, strings.Join(missing, " or "))
+}
+
+// singleStream reports whether downloads are kept to one unresumable stream
+func (c *Client) singleStream() bool {
+	return c.SingleStream != nil && c.SingleStream.Load()
+}
+
+// CheckGGUF verifies that a blob response starts with the GGUF magic
+func CheckGGUF(resp *http.Response, prefix []byte) error {
+	if bytes.HasPrefix(prefix, ggufMagic) {
+		return nil
+	}
+	return newInterferenceError(resp, "GGUF data", prefix)
+}
+
+// CheckSafetensors verifies that a response starts with a safetensors header: a
+// little-endian length followed by the JSON header itself
+func CheckSafetensors(resp *http.Response, prefix []byte) error {
+	if len(prefix) > 8 && prefix[8] == '{' {
+		return nil
+	}
+	return newInterferenceError(resp, "safetensors data", prefix)
+}
+
+// CheckJSON verifies that a response body looks like a JSON object
+func CheckJSON(resp *http.Response, body []byte) error {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		return nil
+	}
+	if len(body) > SniffLength {
+		body = body[:SniffLength]
+	}
+	return newInterferenceError(resp, "a JSON manifest", body)
+}
@@ -0,0 +1,107 @@
+package ollamareg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultIdleTimeout is how long NewClient lets a transfer receive nothing before it
+// is re-dialed
+const DefaultIdleTimeout = time.Minute
+
+// MaxStallRedials bounds how often one download is re-dialed after stalling
+const MaxStallRedials = 3
+
+// ErrStalled reports a transfer that stopped delivering data without failing
+var ErrStalled = errors.New("transfer stalled")
+
+// stallWatch cancels a request once neither its response nor its body has delivered
+// anything for the idle timeout
+type stallWatch struct {
+	timer   *time.Timer
+	idle    time.Duration
+	cancel  context.CancelFunc
+	stalled atomic.Bool
+}
+
+// doWatched sends req, failing the request or its body with ErrStalled when the
+// server goes silent, so a wedged connection is noticed instead of hanging forever
+func (c *Client) doWatched(req *http.Request) (*http.Response, error) {
+	if c.IdleTimeout <= 0 {
+		return c.do(req)
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	w := &stallWatch{cancel: cancel, idle: c.IdleTimeout}
+	w.timer = time.AfterFunc(w.idle, func() {
+		w.stalled.Store(true)
+		cancel()
+	})
+	resp, err := c.do(req.WithContext(ctx))
+	if err != nil {
+		w.stop()
+		return nil, w.err(err)
+	}
+	resp.Body = &stallBody{ReadCloser: resp.Body, watch: w}
+	return resp, nil
+}
+
+// stop releases the watch once the transfer is over
+func (w *stallWatch) stop() {
+	w.timer.Stop()
+	w.cancel()
+}
+
+// err replaces the cancellation error of a stalled transfer with ErrStalled
+func (w *stallWatch) err(err error) error {
+	if err != nil && w.stalled.Load() {
+		return fmt.Errorf("%w: nothing received for %s", ErrStalled, w.idle)
+	}
+	return err
+}
+
+// stallBody restarts its watch whenever data arrives
+type stallBody struct {
+	io.ReadCloser
+	watch *stallWatch
+}
+
+func (b *stallBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 && !b.watch.stalled.Load() {
+		b.watch.timer.Reset(b.watch.idle)
+	}
+	return n, b.watch.err(err)
+}
+
+func (b *stallBody) Close() error {
+	b.watch.stop()
+	return b.ReadCloser.Close()
+}
+
+// recoverFromStall prepares the next attempt of a stalled download of filename: fresh
+// connections, resuming where the file ends
+func (c *Client) recoverFromStall(url, filename string, err error, redial int) int64 {
+	if c.OnStall != nil {
+		c.OnStall(filename, err, redial)
+	} else {
+		c.logf("Download of %s stalled (%s); re-dialing (%d/%d)", filepath.Base(filename), err, redial, MaxStallRedials)
+	}
+	c.httpClient().CloseIdleConnections()
+
+	// Without validators the partial file is not trusted to resume, so start over
+	if loadResumeState(filename, url) == nil {
+		return 0
+	}
+	info, statErr := os.Stat(filename)
+	if statErr != nil {
+		return 0
+	}
+	return info.Size()
+}
@@ -0,0 +1,51 @@
+package ollamareg
+
+import (
+	"context"
+	"strings"
+)
+
+// Tracer receives the operations of a client as spans, so an embedder can trace them
+// with its own OpenTelemetry SDK or any other tracer. The requests an operation makes
+// carry the context Start returns, so a tracing transport in Client.HTTPClient, such as
+// otelhttp's, nests its request spans inside the operation.
+type Tracer interface {
+	// Start opens a span named name, a child of the span in ctx if there is one, and
+	// returns the context of the work inside it
+	Start(ctx context.Context, name string, attrs ...Attr) (context.Context, Span)
+}
+
+// Span is an operation in progress
+type Span interface {
+	// SetAttributes adds what is learned about the operation while it runs
+	SetAttributes(attrs ...Attr)
+	// End finishes the span, marking it failed when err is not nil
+	End(err error)
+}
+
+// Attr is a span attribute; Value is a string, an int64 or a bool
+type Attr struct {
+	Key   string
+	Value any
+}
+
+// noopSpan is the span of a client without a Tracer
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...Attr) {}
+
+func (noopSpan) End(error) {}
+
+// startSpan opens a span through the client's Tracer, or one that records nothing
+func (c *Client) startSpan(ctx context.Context, name string, attrs ...Attr) (context.Context, Span) {
+	if c.Tracer == nil {
+		return ctx, noopSpan{}
+	}
+	return c.Tracer.Start(ctx, name, attrs...)
+}
+
+// spanURL is url without its query, which for signed URLs holds credentials
+func spanURL(url string) string {
+	url, _, _ = strings.Cut(url, "?")
+	return url
+}
@@ -0,0 +1,359 @@
+package ollamareg
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Progress receives the bytes of a transfer as they arrive
+type Progress interface {
+	io.Writer
+	// Finish is called once the transfer is complete
+	Finish() error
+}
+
+// ProgressFunc adapts fn, called with the bytes present so far and the total (-1 when
+// unknown), for DownloadOptions.Progress
+func ProgressFunc(fn func(done, total int64)) func(label string, total, offset int64) Progress {
+	return func(_ string, total, offset int64) Progress {
+		return &funcProgress{fn: fn, done: offset, total: total}
+	}
+}
+
+// funcProgress reports every write to a callback
+type funcProgress struct {
+	fn    func(done, total int64)
+	done  int64
+	total int64
+}
+
+func (p *funcProgress) Write(b []byte) (int, error) {
+	p.done += int64(len(b))
+	p.fn(p.done, p.total)
+	return len(b), nil
+}
+
+func (p *funcProgress) Finish() error { return nil }
+
+// discardProgress is the progress of a download nobody watches
+type discardProgress struct{}
+
+func (discardProgress) Write(b []byte) (int, error) { return len(b), nil }
+
+func (discardProgress) Finish() error { return nil }
+
+// Transformer rewrites a blob while it is being downloaded, so the original and the
+// converted file never need to exist on disk at the same time
+type Transformer interface {
+	Transform(dst io.Writer, src io.Reader) error
+}
+
+// Copies receives a download's bytes for extra destinations as they arrive: appended
+// through Write, or at their offset through WriteAt when several connections share the
+// download
+type Copies interface {
+	io.Writer
+	io.WriterAt
+	// Truncate cuts the copies to the first n bytes, all the download keeps after a failure
+	Truncate(n int64)
+	// Discard removes the copies of a download whose own file is removed
+	Discard()
+	// Close finishes the copies once the download stops writing
+	Close()
+}
+
+// DownloadOptions control a download
+type DownloadOptions struct {
+	// Progress returns the renderer of a transfer of total bytes (-1 when unknown) that
+	// starts with offset bytes already present, labelled "Downloading" or, while the
+	// prefix of a resumed file is hashed, "Hashing NAME"; nil reports nothing
+	Progress func(label string, total, offset int64) Progress
+	// NoVerify skips checking a finished blob against its digest
+	NoVerify bool
+	// Check inspects the leading bytes of a fresh response before anything is written,
+	// rejecting a payload that is not what was asked for; nil accepts any payload
+	Check func(resp *http.Response, prefix []byte) error
+	// Transform rewrites the stream on its way to disk; a transformed download is
+	// neither resumed nor split over several connections, and no longer matches its
+	// digest, so DownloadBlob needs NoVerify with it
+	Transform Transformer
+	// Copies, when set, returns the extra destinations of a download into filename that
+	// starts at offset, or nil for none
+	Copies func(filename string, offset int64) Copies
+	// Registry marks the download of a registry blob, whose responses always carry their
+	// length and accept ranges; one that lacks them switches the client to SingleStream
+	// (see NoteStrippedHeaders). Other servers may simply not send them.
+	Registry bool
+}
+
+// progress returns the renderer opts asks for, or one that discards
+func (opts DownloadOptions) progress(label string, total, offset int64) Progress {
+	if opts.Progress == nil {
+		return discardProgress{}
+	}
+	return opts.Progress(label, total, offset)
+}
+
+// copies returns the copies opts asks for, or nil
+func (opts DownloadOptions) copies(filename string, offset int64) Copies {
+	if opts.Copies == nil {
+		return nil
+	}
+	return opts.Copies(filename, offset)
+}
+
+// OpenOutput opens filename for a download, truncating it or continuing at offset when
+// it is positive; it is what Client.OpenOutput defaults to
+func OpenOutput(filename string, offset int64) (io.WriteCloser, error) {
+	if offset > 0 {
+		// Positioned rather than appending, so a resume can be split over several connections
+		f, err := os.OpenFile(filename, os.O_WRONLY, 0)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return f, nil
+	}
+	return os.Create(filename)
+}
+
+// syncedOutput closes a download's output once, syncing it to stable storage first
+// unless the client says otherwise
+type syncedOutput struct {
+	io.WriteCloser
+	sync   bool
+	closed bool
+	err    error
+}
+
+// Close syncs and closes the output; later calls return the first call's result
+func (o *syncedOutput) Close() error {
+	if o.closed {
+		return o.err
+	}
+	o.closed = true
+	if s, ok := o.WriteCloser.(interface{ Sync() error }); ok && o.sync {
+		o.err = s.Sync()
+	}
+	if err := o.WriteCloser.Close(); o.err == nil {
+		o.err = err
+	}
+	return o.err
+}
+
+// DownloadFile downloads url into filename, continuing from offset when it is positive.
+// A fresh response whose leading bytes fail opts.Check is rejected before the file is
+// touched. A transfer that stalls is re-dialed and continues from the end of the file,
+// and one whose remote file changed since the partial download began starts over.
+func (c *Client) DownloadFile(ctx context.Context, url, filename string, offset int64, opts DownloadOptions) error {
+	_, err := c.downloadFile(ctx, url, filename, offset, opts)
+	return err
+}
+
+// downloadFile is DownloadFile returning the SHA-256 digest of the finished file when
+// it was hashed on its way to disk, or ""
+func (c *Client) downloadFile(ctx context.Context, url, filename string, offset int64, opts DownloadOptions) (digest string, err error) {
+	ctx, span := c.startSpan(ctx, "ollamareg.DownloadFile", Attr{"url.full", spanURL(url)}, Attr{"ollamareg.offset", offset})
+	defer func() { span.End(err) }()
+	for redial := 1; ; redial++ {
+		digest, err := c.downloadAttempt(ctx, url, filename, offset, opts)
+		// A transformed stream cannot be picked up in the middle
+		if !errors.Is(err, ErrStalled) || opts.Transform != nil || redial > MaxStallRedials {
+			return digest, err
+		}
+		span.SetAttributes(Attr{"ollamareg.redials", int64(redial)})
+		offset = c.recoverFromStall(url, filename, err, redial)
+	}
+}
+
+// downloadAttempt makes one request for downloadFile
+func (c *Client) downloadAttempt(ctx context.Context, url, filename string, offset int64, opts DownloadOptions) (string, error) {
+	var state *resumeState
+	if offset > 0 && c.singleStream() {
+		// A range request through a rewriting proxy may be answered with the wrong bytes
+		c.logf("Not resuming %s in single-stream mode; downloading it again", filepath.Base(filename))
+		offset = 0
+	}
+	var hashed *streamHash
+	if offset > 0 {
+		state = loadResumeState(filename, url)
+		if state != nil && opts.Transform == nil {
+			// Catch up on the prefix before asking, so the connection does not sit idle meanwhile
+			hashed = c.resumeHash(state, filename, offset, opts)
+		}
+	}
+	release, err := c.Budget.acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	release = sync.OnceFunc(release)
+	defer release()
+	requested := time.Now()
+	resp, err := c.getFrom(ctx, url, offset, state)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	rtt := time.Since(requested)
+
+	switch {
+	case offset > 0 && resp.StatusCode == http.StatusPreconditionFailed:
+		// Appending would stitch together bytes from two versions of the file
+		resp.Body.Close()
+		release()
+		c.logf("%s changed on the server since the download started; restarting it", filepath.Base(filename))
+		return c.downloadFile(ctx, url, filename, 0, opts)
+	case offset > 0 && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// Nothing is left past the end of the existing file
+		ClearResumeState(filename)
+		return "", nil
+	case offset > 0 && resp.StatusCode == http.StatusPartialContent:
+	case resp.StatusCode == http.StatusOK:
+		if offset > 0 {
+			c.logf("The server does not support resuming; restarting the download")
+			offset, hashed = 0, nil
+		}
+	default:
+		return "", &StatusError{Op: "download file", StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	if opts.Registry {
+		c.NoteStrippedHeaders(resp)
+	}
+
+	// Inspect the payload before touching the output file, so an intercepted
+	// response never overwrites a good model; a resumed body starts mid-file
+	// and is checked by the digest verification instead
+	body := bufio.NewReaderSize(c.throttle(resp.Body), SniffLength)
+	if offset == 0 && opts.Check != nil {
+		prefix, _ := body.Peek(SniffLength)
+		if err := opts.Check(resp, prefix); err != nil {
+			return "", err
+		}
+	}
+
+	totalSize := resp.ContentLength
+	if offset > 0 && totalSize >= 0 {
+		totalSize += offset
+	}
+	file, err := c.openOutput(filename, offset)
+	if err != nil {
+		return "", err
+	}
+	// Whatever happens, the bytes written so far are flushed and synced before the
+	// caller decides what becomes of the file
+	out := &syncedOutput{WriteCloser: file, sync: !c.NoSync}
+	defer out.Close()
+	copies := opts.copies(filename, offset)
+	if copies != nil {
+		defer copies.Close()
+	}
+
+	bar := opts.progress("Downloading", totalSize, offset)
+	if opts.Transform == nil {
+		// Remember which version of the file this is, so a later resume can insist on
+		// it; without reliable lengths a partial file cannot be trusted to resume
+		fresh := newResumeState(url, resp)
+		if offset == 0 && fresh != nil && !c.singleStream() {
+			fresh.save(filename)
+			hashed = c.resumeHash(fresh, filename, 0, opts)
+		}
+		// Extra connections also need the validators, so every range comes from one version;
+		// a resumed download splits what is left of the blob the same way
+		if f, ok := file.(*os.File); ok && resp.ContentLength > 0 && fresh != nil && !c.singleStream() && (offset == 0 || state != nil) {
+			if connections, chunk := c.planDownload(resp.ContentLength, rtt); connections > 1 {
+				done, err := c.parallelDownload(ctx, url, f, copies, resp, body, offset, offset+resp.ContentLength, connections, chunk, bar, hashed)
+				if err != nil {
+					f.Truncate(done)
+					if copies != nil {
+						copies.Truncate(done)
+					}
+					return "", err
+				}
+				if err := out.Close(); err != nil {
+					return "", err
+				}
+				var digest string
+				if hashed != nil {
+					digest = c.record(hashed)
+				}
+				ClearResumeState(filename)
+				return digest, bar.Finish()
+			}
+		}
+		if hashed == nil && offset == 0 {
+			// Every download is checked against its digest, and hashing the bytes on their
+			// way to disk spares reading the file back afterwards
+			hashed = newStreamHash(filename)
+		}
+		writers := []io.Writer{file, bar}
+		var background *backgroundWriter
+		if hashed != nil {
+			background = newBackgroundWriter(hashed)
+			defer background.Close()
+			writers = append(writers, background)
+		}
+		if copies != nil {
+			writers = append(writers, copies)
+		}
+		if _, err = io.Copy(io.MultiWriter(writers...), body); err != nil {
+			return "", err
+		}
+		// Direct I/O writes the last partial block on close, so its error matters
+		if err := out.Close(); err != nil {
+			return "", err
+		}
+		var digest string
+		if hashed != nil {
+			background.Close()
+			digest = c.record(hashed)
+		}
+		ClearResumeState(filename)
+		return digest, bar.Finish()
+	}
+
+	// Progress tracks bytes received from the network, not bytes the transform emits
+	var dst io.Writer = file
+	if copies != nil {
+		dst = io.MultiWriter(file, copies)
+	}
+	if err := opts.Transform.Transform(dst, io.TeeReader(body, bar)); err != nil {
+		out.Close()
+		os.Remove(filename)
+		if copies != nil {
+			copies.Discard()
+		}
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		return "", err
+	}
+	return "", bar.Finish()
+}
+
+// getFrom issues a GET request for the bytes of url from offset onwards; with a resume
+// state the server is asked to refuse with 412 if the file has changed since
+func (c *Client) getFrom(ctx context.Context, url string, offset int64, state *resumeState) (*http.Response, error) {
+	req, err := c.NewRequest(ctx, http.MethodGet, url)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		if state != nil {
+			state.setPreconditions(req)
+		}
+	}
+	return c.doWatched(req)
+}